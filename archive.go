@@ -0,0 +1,124 @@
+package pivnet
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"time"
+)
+
+// maxArchiveMemberSize bounds the size of a member extracted into memory by
+// RemoteArchive.ReadFile.
+const maxArchiveMemberSize = 64 * 1024 * 1024
+
+type ArchiveEntry struct {
+	Name             string
+	CompressedSize   int64
+	UncompressedSize int64
+	Modified         time.Time
+}
+
+// RemoteArchive lists and extracts the members of a zip archive, such as a
+// .pivotal tile, that is still on the download server. Only the central
+// directory and the members that are read are fetched.
+type RemoteArchive struct {
+	reader *zip.Reader
+}
+
+// OpenArchive reads the central directory of a zip product file with HTTP
+// range requests.
+func (p ProductFilesService) OpenArchive(
+	ctx context.Context,
+	productSlug string,
+	releaseID int,
+	productFileID int,
+) (RemoteArchive, error) {
+	pf, err := p.GetForRelease(productSlug, releaseID, productFileID)
+	if err != nil {
+		return RemoteArchive{}, err
+	}
+
+	downloadLink, err := pf.DownloadLink()
+	if err != nil {
+		return RemoteArchive{}, err
+	}
+
+	readerAt, err := p.client.downloader.NewRangeReaderAt(
+		ctx,
+		NewProductFileLinkFetcher(downloadLink, p.client),
+	)
+	if err != nil {
+		return RemoteArchive{}, err
+	}
+
+	reader, err := zip.NewReader(readerAt, readerAt.Size())
+	if err != nil {
+		return RemoteArchive{}, fmt.Errorf("failed to read archive: %s", err)
+	}
+
+	return RemoteArchive{reader: reader}, nil
+}
+
+func (a RemoteArchive) Entries() []ArchiveEntry {
+	entries := make([]ArchiveEntry, 0, len(a.reader.File))
+	for _, f := range a.reader.File {
+		entries = append(entries, ArchiveEntry{
+			Name:             f.Name,
+			CompressedSize:   int64(f.CompressedSize64),
+			UncompressedSize: int64(f.UncompressedSize64),
+			Modified:         f.Modified,
+		})
+	}
+
+	return entries
+}
+
+// Match returns the entries whose names match pattern, as understood by
+// path.Match, e.g. "metadata/*.yml".
+func (a RemoteArchive) Match(pattern string) ([]ArchiveEntry, error) {
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %s", pattern, err)
+	}
+
+	var matched []ArchiveEntry
+	for _, entry := range a.Entries() {
+		// Pattern has been validated, so errors cannot occur
+		ok, _ := path.Match(pattern, entry.Name)
+		if ok {
+			matched = append(matched, entry)
+		}
+	}
+
+	return matched, nil
+}
+
+// ReadFile fetches and decompresses a single member of the archive.
+func (a RemoteArchive) ReadFile(name string) ([]byte, error) {
+	for _, f := range a.reader.File {
+		if f.Name != name {
+			continue
+		}
+
+		if f.UncompressedSize64 > maxArchiveMemberSize {
+			return nil, fmt.Errorf(
+				"%s is %d bytes, more than the %d bytes that can be extracted",
+				name,
+				f.UncompressedSize64,
+				maxArchiveMemberSize,
+			)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		return ioutil.ReadAll(rc)
+	}
+
+	return nil, fmt.Errorf("%s not found in archive", name)
+}
@@ -0,0 +1,193 @@
+package pivnet_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - remote archives", func() {
+	var (
+		server     *ghttp.Server
+		cloudfront *ghttp.Server
+		client     pivnet.Client
+
+		fakeLogger logger.Logger
+
+		releaseID     int
+		productFileID int
+
+		archive     []byte
+		metadata    []byte
+		servedBytes int64
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		cloudfront = ghttp.NewServer()
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, fakeLogger)
+
+		releaseID = 1234
+		productFileID = 100
+		servedBytes = 0
+
+		metadata = []byte("product_version: 1.2.3\nstemcell_criteria:\n  version: '3421'\n")
+
+		// A large incompressible member so that reading the whole archive
+		// would be noticeable
+		releaseBits := make([]byte, 4*1024*1024)
+		rand.New(rand.NewSource(1)).Read(releaseBits)
+
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+
+		for _, member := range []struct {
+			name    string
+			content []byte
+		}{
+			{name: "releases/some-release.tgz", content: releaseBits},
+			{name: "metadata/some-product.yml", content: metadata},
+			{name: "migrations/v1/201701010000_noop.js", content: []byte("noop")},
+		} {
+			f, err := w.CreateHeader(&zip.FileHeader{Name: member.name, Method: zip.Deflate})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = f.Write(member.content)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		err := w.Close()
+		Expect(err).NotTo(HaveOccurred())
+
+		archive = buf.Bytes()
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, releaseID, productFileID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{
+				ProductFile: pivnet.ProductFile{
+					ID: productFileID,
+					Links: &pivnet.Links{
+						Download: map[string]string{
+							"href": fmt.Sprintf("%s%s/products/%s/product_files/%d/download", server.URL(), apiPrefix, productSlug, productFileID),
+						},
+					},
+				},
+			}),
+		)
+
+		server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/product_files/%d/download", apiPrefix, productSlug, productFileID),
+			ghttp.RespondWith(http.StatusFound, []byte(`{}`), http.Header{
+				"Location": []string{cloudfront.URL() + "/some-product.pivotal"},
+			}),
+		)
+
+		cloudfront.RouteToHandler("HEAD", "/some-product.pivotal",
+			ghttp.RespondWith(http.StatusOK, nil, http.Header{
+				"Content-Length": []string{strconv.Itoa(len(archive))},
+			}),
+		)
+
+		cloudfront.RouteToHandler("GET", "/some-product.pivotal", ghttp.CombineHandlers(
+			func(w http.ResponseWriter, req *http.Request) {
+				start, end := requestedRange(req)
+				atomic.AddInt64(&servedBytes, int64(end-start+1))
+			},
+			serveRanges(archive),
+		))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		cloudfront.Close()
+	})
+
+	It("lists the entries and extracts members without fetching the whole archive", func() {
+		remoteArchive, err := client.ProductFiles.OpenArchive(context.Background(), productSlug, releaseID, productFileID)
+		Expect(err).NotTo(HaveOccurred())
+
+		var names []string
+		for _, entry := range remoteArchive.Entries() {
+			names = append(names, entry.Name)
+		}
+		Expect(names).To(Equal([]string{
+			"releases/some-release.tgz",
+			"metadata/some-product.yml",
+			"migrations/v1/201701010000_noop.js",
+		}))
+		Expect(remoteArchive.Entries()[0].UncompressedSize).To(Equal(int64(4 * 1024 * 1024)))
+
+		matched, err := remoteArchive.Match("metadata/*.yml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matched).To(HaveLen(1))
+
+		content, err := remoteArchive.ReadFile(matched[0].Name)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(content).To(Equal(metadata))
+
+		Expect(atomic.LoadInt64(&servedBytes)).To(BeNumerically("<", len(archive)/4))
+	})
+
+	Context("when the member does not exist", func() {
+		It("returns an error", func() {
+			remoteArchive, err := client.ProductFiles.OpenArchive(context.Background(), productSlug, releaseID, productFileID)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remoteArchive.ReadFile("metadata/other.yml")
+			Expect(err).To(MatchError("metadata/other.yml not found in archive"))
+		})
+	})
+
+	Context("when the pattern is invalid", func() {
+		It("returns an error", func() {
+			remoteArchive, err := client.ProductFiles.OpenArchive(context.Background(), productSlug, releaseID, productFileID)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = remoteArchive.Match("[")
+			Expect(err).To(MatchError(ContainSubstring(`invalid pattern "["`)))
+		})
+	})
+
+	Context("when the product file is not a zip archive", func() {
+		BeforeEach(func() {
+			archive = []byte("not a zip archive")
+		})
+
+		It("returns an error", func() {
+			_, err := client.ProductFiles.OpenArchive(context.Background(), productSlug, releaseID, productFileID)
+			Expect(err).To(MatchError(ContainSubstring("failed to read archive")))
+		})
+	})
+
+	Context("when getting the product file fails", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, releaseID, productFileID),
+				ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+			)
+		})
+
+		It("forwards the error", func() {
+			_, err := client.ProductFiles.OpenArchive(context.Background(), productSlug, releaseID, productFileID)
+			Expect(err).To(MatchError(ContainSubstring("foo message")))
+		})
+	})
+})
@@ -0,0 +1,209 @@
+package pivnet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet/download"
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const concurrentFileDownloads = 4
+
+// DownloadOptions tune downloads of several product files at once.
+type DownloadOptions struct {
+	// ConcurrentFiles is the number of product files downloaded at the same
+	// time. Defaults to 4 when unset.
+	ConcurrentFiles int
+
+	// Connections caps the number of byte ranges fetched at the same time
+	// across all product files. Defaults to the client's download
+	// concurrency when unset.
+	Connections int
+
+	// NewProgressListener builds the listener that receives progress events
+	// for each product file. Progress is not reported when unset.
+	NewProgressListener func(productFile ProductFile) download.ProgressListener
+
+	// ManifestPath is where a manifest of the downloaded product files is
+	// written, as YAML if it ends in .yml or .yaml and as JSON otherwise.
+	ManifestPath string
+}
+
+type DownloadReport struct {
+	Succeeded []DownloadedProductFile
+	Skipped   []ProductFile
+	Failed    []FailedProductFile
+}
+
+type DownloadedProductFile struct {
+	ProductFile ProductFile
+	Path        string
+}
+
+type FailedProductFile struct {
+	ProductFile ProductFile
+	Path        string
+	Err         error
+}
+
+type downloadResult struct {
+	sourceHost   string
+	downloadedAt time.Time
+	err          error
+}
+
+type downloadJob struct {
+	productSlug string
+	releaseID   int
+	fileGroup   string
+	productFile ProductFile
+	path        string
+}
+
+// downloadAll downloads each job to its path, several at a time, returning
+// the result for each job in the same order as the jobs.
+func (p ProductFilesService) downloadAll(jobs []downloadJob, options DownloadOptions) []downloadResult {
+	downloader := p.client.downloader
+	if options.Connections > 0 {
+		downloader.ConnectionLimit = download.NewConnectionLimit(options.Connections)
+	} else {
+		downloader.ConnectionLimit = download.NewConnectionLimit(downloader.Concurrency)
+	}
+
+	concurrentFiles := options.ConcurrentFiles
+	if concurrentFiles < 1 {
+		concurrentFiles = concurrentFileDownloads
+	}
+	semaphore := make(chan struct{}, concurrentFiles)
+
+	results := make([]downloadResult, len(jobs))
+
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		i, job := i, job
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			var listener download.ProgressListener = download.SilentListener{}
+			if options.NewProgressListener != nil {
+				listener = options.NewProgressListener(job.productFile)
+			}
+
+			sourceHost, err := p.downloadJob(job, downloader, listener)
+			if err != nil {
+				p.client.logger.Info("Failed to download product file", logger.Data{
+					"productFileID": job.productFile.ID,
+					"error":         err.Error(),
+				})
+			}
+
+			results[i] = downloadResult{
+				sourceHost:   sourceHost,
+				downloadedAt: time.Now().UTC(),
+				err:          err,
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// downloadJob downloads a listed product file to the job's path. Listed
+// product files are expected to carry their download link, but the product
+// file is fetched individually if it does not.
+func (p ProductFilesService) downloadJob(
+	job downloadJob,
+	downloader download.Client,
+	listener download.ProgressListener,
+) (string, error) {
+	pf := job.productFile
+	if pf.Links == nil {
+		var err error
+		if job.releaseID != 0 {
+			pf, err = p.GetForRelease(job.productSlug, job.releaseID, pf.ID)
+		} else {
+			pf, err = p.Get(job.productSlug, pf.ID)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err := os.MkdirAll(filepath.Dir(job.path), 0755)
+	if err != nil {
+		return "", err
+	}
+
+	return p.downloadToPath(
+		context.Background(),
+		job.path,
+		job.productSlug,
+		job.releaseID,
+		pf,
+		downloader,
+		listener,
+	)
+}
+
+// add records the outcome of each job in the report, returning an
+// error if any of them failed.
+func (r *DownloadReport) add(jobs []downloadJob, results []downloadResult) error {
+	failed := 0
+	for i, job := range jobs {
+		if results[i].err != nil {
+			failed++
+			r.Failed = append(r.Failed, FailedProductFile{
+				ProductFile: job.productFile,
+				Path:        job.path,
+				Err:         results[i].err,
+			})
+			continue
+		}
+
+		r.Succeeded = append(r.Succeeded, DownloadedProductFile{
+			ProductFile: job.productFile,
+			Path:        job.path,
+		})
+	}
+
+	if failed > 0 {
+		return fmt.Errorf(
+			"%d of %d product files failed to download",
+			failed,
+			len(jobs),
+		)
+	}
+
+	return nil
+}
+
+// disambiguatePaths prefixes the base name of every job whose path is shared
+// with another job with its product file ID, e.g. when two AWS object keys
+// have the same base name under different prefixes, so that no download
+// replaces another.
+func disambiguatePaths(jobs []downloadJob) {
+	count := make(map[string]int)
+	for _, job := range jobs {
+		count[job.path]++
+	}
+
+	for i, job := range jobs {
+		if count[job.path] < 2 {
+			continue
+		}
+
+		dir, base := filepath.Split(job.path)
+		jobs[i].path = filepath.Join(dir, prefixWithID(job.productFile.ID, base))
+	}
+}
@@ -0,0 +1,145 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const (
+	metadataName = "bundle.json"
+	filesDir     = "files"
+)
+
+// Metadata describes a release independently of the host it was exported
+// from. Product files are identified by their AWS object key and releases by
+// their version, as IDs differ between hosts.
+type Metadata struct {
+	ProductSlug string         `json:"product_slug"`
+	Release     pivnet.Release `json:"release"`
+	EULASlug    string         `json:"eula_slug,omitempty"`
+
+	ProductFiles []File `json:"product_files"`
+
+	// ReleaseProductFiles are the AWS object keys of the product files
+	// added to the release directly rather than through a file group.
+	ReleaseProductFiles []string `json:"release_product_files"`
+
+	FileGroups           []FileGroup           `json:"file_groups,omitempty"`
+	Dependencies         []Dependency          `json:"dependencies,omitempty"`
+	DependencySpecifiers []DependencySpecifier `json:"dependency_specifiers,omitempty"`
+
+	// UpgradePaths are the versions of the product's releases that can be
+	// upgraded to this release.
+	UpgradePaths []string `json:"upgrade_paths,omitempty"`
+
+	UserGroups []string `json:"user_groups,omitempty"`
+}
+
+type File struct {
+	ProductFile pivnet.ProductFile `json:"product_file"`
+
+	// Path is the location of the file contents in the bundle, or empty if
+	// the contents were not exported.
+	Path string `json:"path,omitempty"`
+}
+
+type FileGroup struct {
+	Name string `json:"name"`
+
+	// ProductFiles are AWS object keys
+	ProductFiles []string `json:"product_files"`
+}
+
+type Dependency struct {
+	ProductSlug string `json:"product_slug"`
+	Version     string `json:"version"`
+}
+
+type DependencySpecifier struct {
+	ProductSlug string `json:"product_slug"`
+	Specifier   string `json:"specifier"`
+}
+
+// Bundler exports releases into gzipped tarballs and imports them into
+// another host.
+type Bundler struct {
+	client pivnet.Client
+	logger logger.Logger
+}
+
+func New(client pivnet.Client, logger logger.Logger) Bundler {
+	return Bundler{
+		client: client,
+		logger: logger,
+	}
+}
+
+// Read returns the metadata of the bundle at bundlePath.
+func Read(bundlePath string) (Metadata, error) {
+	var metadata Metadata
+	err := walk(bundlePath, func(header *tar.Header, r io.Reader) error {
+		if header.Name != metadataName {
+			return nil
+		}
+
+		err := json.NewDecoder(r).Decode(&metadata)
+		if err != nil {
+			return fmt.Errorf("failed to parse bundle metadata: %s", err)
+		}
+
+		return errStopWalk
+	})
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	if metadata.ProductSlug == "" {
+		return Metadata{}, fmt.Errorf("%s is not a release bundle: %s not found", bundlePath, metadataName)
+	}
+
+	return metadata, nil
+}
+
+var errStopWalk = fmt.Errorf("stop walk")
+
+// walk calls fn with each entry of the bundle until fn returns an error.
+// Returning errStopWalk ends the walk without an error.
+func walk(bundlePath string, fn func(header *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gzipReader, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %s", err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read bundle: %s", err)
+		}
+
+		err = fn(header, tarReader)
+		if err == errStopWalk {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+type ExportConfig struct {
+	ProductSlug string
+	ReleaseID   int
+
+	// Path is where the bundle is written
+	Path string
+
+	// IncludeFiles adds the contents of every product file to the bundle.
+	// Otherwise only their metadata and checksums are exported.
+	IncludeFiles bool
+
+	// ProgressWriter receives the progress of each download when
+	// IncludeFiles is set. Progress is discarded when unset.
+	ProgressWriter io.Writer
+}
+
+// Export writes a bundle of the release and everything attached to it.
+func (b Bundler) Export(config ExportConfig) (Metadata, error) {
+	metadata, err := b.metadata(config.ProductSlug, config.ReleaseID)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	if config.IncludeFiles {
+		for i, file := range metadata.ProductFiles {
+			metadata.ProductFiles[i].Path = path.Join(filesDir, path.Clean("/" + file.ProductFile.AWSObjectKey)[1:])
+		}
+	}
+
+	dir, base := filepath.Split(config.Path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+base+".tmp-")
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	gzipWriter := gzip.NewWriter(tmp)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	b.logger.Info("Exporting release", logger.Data{"product": config.ProductSlug, "version": metadata.Release.Version})
+
+	err = writeMetadata(tarWriter, metadata)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	if config.IncludeFiles {
+		err = b.writeFiles(tarWriter, config, metadata)
+		if err != nil {
+			return Metadata{}, err
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	err = gzipWriter.Close()
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	err = os.Rename(tmp.Name(), config.Path)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	succeeded = true
+
+	return metadata, nil
+}
+
+func (b Bundler) metadata(productSlug string, releaseID int) (Metadata, error) {
+	release, err := b.client.Releases.Get(productSlug, releaseID)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	metadata := Metadata{
+		ProductSlug: productSlug,
+		Release:     release,
+	}
+	metadata.Release.Links = nil
+
+	if release.EULA != nil {
+		metadata.EULASlug = release.EULA.Slug
+	}
+
+	releaseProductFiles, err := b.client.ProductFiles.ListForRelease(productSlug, releaseID)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	fileGroups, err := b.client.FileGroups.ListForRelease(productSlug, releaseID)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	seen := make(map[int]bool)
+	addFile := func(pf pivnet.ProductFile) error {
+		if seen[pf.ID] {
+			return nil
+		}
+		seen[pf.ID] = true
+
+		// Listed product files may omit fields such as checksums
+		full, err := b.client.ProductFiles.GetForRelease(productSlug, releaseID, pf.ID)
+		if err != nil {
+			return err
+		}
+		full.Links = nil
+
+		metadata.ProductFiles = append(metadata.ProductFiles, File{ProductFile: full})
+		return nil
+	}
+
+	metadata.ReleaseProductFiles = []string{}
+	for _, pf := range releaseProductFiles {
+		err = addFile(pf)
+		if err != nil {
+			return Metadata{}, err
+		}
+
+		metadata.ReleaseProductFiles = append(metadata.ReleaseProductFiles, pf.AWSObjectKey)
+	}
+
+	for _, fileGroup := range fileGroups {
+		group := FileGroup{Name: fileGroup.Name, ProductFiles: []string{}}
+		for _, pf := range fileGroup.ProductFiles {
+			err = addFile(pf)
+			if err != nil {
+				return Metadata{}, err
+			}
+
+			group.ProductFiles = append(group.ProductFiles, pf.AWSObjectKey)
+		}
+
+		metadata.FileGroups = append(metadata.FileGroups, group)
+	}
+
+	dependencies, err := b.client.ReleaseDependencies.List(productSlug, releaseID)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	for _, dependency := range dependencies {
+		metadata.Dependencies = append(metadata.Dependencies, Dependency{
+			ProductSlug: dependency.Release.Product.Slug,
+			Version:     dependency.Release.Version,
+		})
+	}
+
+	specifiers, err := b.client.DependencySpecifiers.List(productSlug, releaseID)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	for _, specifier := range specifiers {
+		metadata.DependencySpecifiers = append(metadata.DependencySpecifiers, DependencySpecifier{
+			ProductSlug: specifier.Product.Slug,
+			Specifier:   specifier.Specifier,
+		})
+	}
+
+	upgradePaths, err := b.client.ReleaseUpgradePaths.Get(productSlug, releaseID)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	for _, upgradePath := range upgradePaths {
+		metadata.UpgradePaths = append(metadata.UpgradePaths, upgradePath.Release.Version)
+	}
+
+	userGroups, err := b.client.UserGroups.ListForRelease(productSlug, releaseID)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	for _, userGroup := range userGroups {
+		metadata.UserGroups = append(metadata.UserGroups, userGroup.Name)
+	}
+
+	return metadata, nil
+}
+
+func writeMetadata(tarWriter *tar.Writer, metadata Metadata) error {
+	b, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling a known-good body
+		return err
+	}
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name: metadataName,
+		Mode: 0644,
+		Size: int64(len(b)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tarWriter.Write(b)
+	return err
+}
+
+// writeFiles downloads each product file, verifying it against its
+// checksums, and copies it into the bundle.
+func (b Bundler) writeFiles(tarWriter *tar.Writer, config ExportConfig, metadata Metadata) error {
+	progressWriter := config.ProgressWriter
+	if progressWriter == nil {
+		progressWriter = ioutil.Discard
+	}
+
+	dir, err := ioutil.TempDir("", "release-bundle")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, file := range metadata.ProductFiles {
+		filePath := filepath.Join(dir, "product-file")
+
+		err = b.client.ProductFiles.DownloadForReleaseToPath(
+			context.Background(),
+			filePath,
+			config.ProductSlug,
+			config.ReleaseID,
+			file.ProductFile.ID,
+			progressWriter,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = writeFile(tarWriter, file.Path, filePath)
+		if err != nil {
+			return err
+		}
+
+		err = os.Remove(filePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeFile(tarWriter *tar.Writer, name string, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: stat.Size(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tarWriter, f)
+	return err
+}
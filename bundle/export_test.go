@@ -0,0 +1,165 @@
+package bundle_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/bundle"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Export", func() {
+	var (
+		source  *fakeHost
+		b       bundle.Bundler
+		release pivnet.Release
+
+		dir        string
+		bundlePath string
+	)
+
+	readEntries := func(path string) map[string][]byte {
+		f, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close()
+
+		gzipReader, err := gzip.NewReader(f)
+		Expect(err).NotTo(HaveOccurred())
+
+		entries := make(map[string][]byte)
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			header, err := tarReader.Next()
+			if err != nil {
+				break
+			}
+
+			contents, err := ioutil.ReadAll(tarReader)
+			Expect(err).NotTo(HaveOccurred())
+
+			entries[header.Name] = contents
+		}
+
+		return entries
+	}
+
+	BeforeEach(func() {
+		source = newFakeHost()
+		release = source.AddFixtureRelease()
+
+		client := pivnet.NewClient(pivnet.ClientConfig{
+			Host:  source.server.URL(),
+			Token: "my-auth-token",
+		}, &loggerfakes.FakeLogger{})
+		b = bundle.New(client, &loggerfakes.FakeLogger{})
+
+		var err error
+		dir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		bundlePath = filepath.Join(dir, "release.tgz")
+	})
+
+	AfterEach(func() {
+		source.Close()
+
+		err := os.RemoveAll(dir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("writes the release and everything attached to it", func() {
+		metadata, err := b.Export(bundle.ExportConfig{
+			ProductSlug: productSlug,
+			ReleaseID:   release.ID,
+			Path:        bundlePath,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(metadata.ProductSlug).To(Equal(productSlug))
+		Expect(metadata.Release.Version).To(Equal("1.0.0"))
+		Expect(metadata.EULASlug).To(Equal("some-eula"))
+
+		Expect(metadata.ProductFiles).To(HaveLen(3))
+		for _, file := range metadata.ProductFiles {
+			Expect(file.ProductFile.SHA256).NotTo(BeEmpty())
+			Expect(file.ProductFile.Links).To(BeNil())
+			Expect(file.Path).To(BeEmpty())
+		}
+
+		Expect(metadata.ReleaseProductFiles).To(Equal([]string{"product-files/some-product/product-1.0.pivotal"}))
+		Expect(metadata.FileGroups).To(Equal([]bundle.FileGroup{{
+			Name: "CLIs",
+			ProductFiles: []string{
+				"product-files/some-product/cli-linux-1.0",
+				"product-files/some-product/cli-windows-1.0.exe",
+			},
+		}}))
+		Expect(metadata.Dependencies).To(Equal([]bundle.Dependency{{ProductSlug: dependentProductSlug, Version: "2.0.0"}}))
+		Expect(metadata.DependencySpecifiers).To(Equal([]bundle.DependencySpecifier{{ProductSlug: dependentProductSlug, Specifier: "~> 2.0"}}))
+		Expect(metadata.UpgradePaths).To(Equal([]string{"0.9.0"}))
+		Expect(metadata.UserGroups).To(Equal([]string{"partners"}))
+
+		read, err := bundle.Read(bundlePath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal(metadata))
+
+		Expect(readEntries(bundlePath)).To(HaveLen(1))
+	})
+
+	Context("when file contents are included", func() {
+		It("adds the contents of every product file to the bundle", func() {
+			metadata, err := b.Export(bundle.ExportConfig{
+				ProductSlug:  productSlug,
+				ReleaseID:    release.ID,
+				Path:         bundlePath,
+				IncludeFiles: true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			entries := readEntries(bundlePath)
+			Expect(entries).To(HaveLen(4))
+
+			for _, file := range metadata.ProductFiles {
+				Expect(file.Path).To(Equal("files/" + file.ProductFile.AWSObjectKey))
+				Expect(entries[file.Path]).To(Equal(fixtureContents[file.ProductFile.Name]))
+			}
+		})
+	})
+
+	Context("when the release cannot be found", func() {
+		It("returns an error without writing a bundle", func() {
+			_, err := b.Export(bundle.ExportConfig{
+				ProductSlug: productSlug,
+				ReleaseID:   1,
+				Path:        bundlePath,
+			})
+			Expect(err).To(HaveOccurred())
+
+			_, err = os.Stat(bundlePath)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("Read", func() {
+	It("returns an error when the file is not a bundle", func() {
+		f, err := ioutil.TempFile("", "")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(f.Name())
+
+		gzipWriter := gzip.NewWriter(f)
+		Expect(tar.NewWriter(gzipWriter).Close()).To(Succeed())
+		Expect(gzipWriter.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		_, err = bundle.Read(f.Name())
+		Expect(err).To(MatchError(ContainSubstring("is not a release bundle")))
+	})
+})
@@ -0,0 +1,551 @@
+package bundle_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+
+	. "github.com/onsi/gomega"
+)
+
+// fakeHost is a stateful Pivnet host serving the endpoints used to export and
+// import a release.
+type fakeHost struct {
+	server *ghttp.Server
+
+	mu     sync.Mutex
+	nextID int
+
+	releases     map[string][]pivnet.Release
+	releaseState map[int]*fakeRelease
+	productFiles map[int]pivnet.ProductFile
+	fileGroups   map[int]pivnet.FileGroup
+	userGroups   []pivnet.UserGroup
+	contents     map[int][]byte
+
+	mutations []string
+
+	// failures are the methods and path suffixes of requests to fail once
+	failures [][2]string
+}
+
+type fakeRelease struct {
+	productFiles []int
+	fileGroups   []int
+	dependencies []pivnet.ReleaseDependency
+	specifiers   []pivnet.DependencySpecifier
+	upgradePaths []pivnet.ReleaseUpgradePath
+	userGroups   []int
+}
+
+type fakeRoute struct {
+	method  string
+	pattern *regexp.Regexp
+	handle  func(w http.ResponseWriter, req *http.Request, params []string)
+}
+
+func newFakeHost() *fakeHost {
+	h := &fakeHost{
+		server:       ghttp.NewServer(),
+		nextID:       1000,
+		releases:     make(map[string][]pivnet.Release),
+		releaseState: make(map[int]*fakeRelease),
+		productFiles: make(map[int]pivnet.ProductFile),
+		fileGroups:   make(map[int]pivnet.FileGroup),
+		contents:     make(map[int][]byte),
+	}
+
+	for _, route := range h.routes() {
+		r := route
+		h.server.RouteToHandler(r.method, r.pattern, func(w http.ResponseWriter, req *http.Request) {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			for i, failure := range h.failures {
+				if req.Method == failure[0] && strings.HasSuffix(req.URL.Path, failure[1]) {
+					h.failures = append(h.failures[:i], h.failures[i+1:]...)
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					return
+				}
+			}
+
+			if req.Method != "GET" && req.Method != "HEAD" {
+				h.mutations = append(h.mutations, req.Method+" "+req.URL.Path)
+			}
+
+			r.handle(w, req, r.pattern.FindStringSubmatch(req.URL.Path)[1:])
+		})
+	}
+
+	return h
+}
+
+func (h *fakeHost) Close() {
+	h.server.Close()
+}
+
+func (h *fakeHost) Mutations() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]string(nil), h.mutations...)
+}
+
+// FailOnce makes the next request with method whose path ends with suffix
+// fail.
+func (h *fakeHost) FailOnce(method string, suffix string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.failures = append(h.failures, [2]string{method, suffix})
+}
+
+func (h *fakeHost) FileGroupNames() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	names := []string{}
+	for _, fg := range h.fileGroups {
+		names = append(names, fg.Name)
+	}
+
+	return names
+}
+
+func (h *fakeHost) id() int {
+	h.nextID++
+	return h.nextID
+}
+
+func (h *fakeHost) AddRelease(productSlug string, release pivnet.Release) pivnet.Release {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	release.ID = h.id()
+	h.releases[productSlug] = append(h.releases[productSlug], release)
+	h.releaseState[release.ID] = &fakeRelease{}
+
+	return release
+}
+
+func (h *fakeHost) AddProductFile(releaseID int, pf pivnet.ProductFile, contents []byte) pivnet.ProductFile {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	pf.ID = h.id()
+	h.productFiles[pf.ID] = pf
+	h.contents[pf.ID] = contents
+	if releaseID != 0 {
+		h.releaseState[releaseID].productFiles = append(h.releaseState[releaseID].productFiles, pf.ID)
+	}
+
+	return pf
+}
+
+func (h *fakeHost) AddFileGroup(releaseID int, name string, productFiles ...pivnet.ProductFile) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fileGroup := pivnet.FileGroup{ID: h.id(), Name: name, ProductFiles: productFiles}
+	h.fileGroups[fileGroup.ID] = fileGroup
+	h.releaseState[releaseID].fileGroups = append(h.releaseState[releaseID].fileGroups, fileGroup.ID)
+}
+
+func (h *fakeHost) AddDependency(releaseID int, productSlug string, dependency pivnet.Release) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.releaseState[releaseID].dependencies = append(h.releaseState[releaseID].dependencies, pivnet.ReleaseDependency{
+		Release: pivnet.DependentRelease{ID: dependency.ID, Version: dependency.Version, Product: pivnet.Product{Slug: productSlug}},
+	})
+}
+
+func (h *fakeHost) AddDependencySpecifier(releaseID int, productSlug string, specifier string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.releaseState[releaseID].specifiers = append(h.releaseState[releaseID].specifiers, pivnet.DependencySpecifier{
+		ID:        h.id(),
+		Product:   pivnet.Product{Slug: productSlug},
+		Specifier: specifier,
+	})
+}
+
+func (h *fakeHost) AddUpgradePath(releaseID int, previous pivnet.Release) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.releaseState[releaseID].upgradePaths = append(h.releaseState[releaseID].upgradePaths, pivnet.ReleaseUpgradePath{
+		Release: pivnet.UpgradePathRelease{ID: previous.ID, Version: previous.Version},
+	})
+}
+
+func (h *fakeHost) AddUserGroup(releaseID int, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	userGroup := pivnet.UserGroup{ID: h.id(), Name: name}
+	h.userGroups = append(h.userGroups, userGroup)
+	if releaseID != 0 {
+		h.releaseState[releaseID].userGroups = append(h.releaseState[releaseID].userGroups, userGroup.ID)
+	}
+}
+
+func (h *fakeHost) routes() []fakeRoute {
+	respond := func(w http.ResponseWriter, status int, body interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if body != nil {
+			Expect(json.NewEncoder(w).Encode(body)).To(Succeed())
+		}
+	}
+
+	decode := func(req *http.Request, v interface{}) {
+		Expect(json.NewDecoder(req.Body).Decode(v)).To(Succeed())
+	}
+
+	atoi := func(s string) int {
+		i, err := strconv.Atoi(s)
+		Expect(err).NotTo(HaveOccurred())
+		return i
+	}
+
+	route := func(method string, pattern string, handle func(w http.ResponseWriter, req *http.Request, params []string)) fakeRoute {
+		return fakeRoute{
+			method:  method,
+			pattern: regexp.MustCompile("^" + pattern + "$"),
+			handle:  handle,
+		}
+	}
+
+	release := apiPrefix + `/products/([^/]+)/releases/(\d+)`
+
+	return []fakeRoute{
+		route("GET", apiPrefix+`/products/([^/]+)/releases`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			respond(w, http.StatusOK, pivnet.ReleasesResponse{Releases: h.releases[params[0]]})
+		}),
+
+		route("POST", apiPrefix+`/products/([^/]+)/releases`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			var body struct {
+				Release pivnet.Release `json:"release"`
+			}
+			decode(req, &body)
+
+			r := body.Release
+			r.ID = h.id()
+			h.releases[params[0]] = append(h.releases[params[0]], r)
+			h.releaseState[r.ID] = &fakeRelease{}
+
+			respond(w, http.StatusCreated, pivnet.CreateReleaseResponse{Release: r})
+		}),
+
+		route("GET", release, func(w http.ResponseWriter, req *http.Request, params []string) {
+			for _, r := range h.releases[params[0]] {
+				if r.ID == atoi(params[1]) {
+					respond(w, http.StatusOK, r)
+					return
+				}
+			}
+
+			respond(w, http.StatusNotFound, nil)
+		}),
+
+		route("GET", apiPrefix+`/products/([^/]+)/product_files`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			productFiles := []pivnet.ProductFile{}
+			for _, pf := range h.productFiles {
+				productFiles = append(productFiles, pf)
+			}
+
+			respond(w, http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: productFiles})
+		}),
+
+		route("POST", apiPrefix+`/products/([^/]+)/product_files`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			var body struct {
+				ProductFile pivnet.ProductFile `json:"product_file"`
+			}
+			decode(req, &body)
+
+			pf := body.ProductFile
+			pf.ID = h.id()
+			h.productFiles[pf.ID] = pf
+
+			respond(w, http.StatusCreated, pivnet.ProductFileResponse{ProductFile: pf})
+		}),
+
+		route("GET", release+`/product_files`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			productFiles := []pivnet.ProductFile{}
+			for _, id := range h.releaseState[atoi(params[1])].productFiles {
+				productFiles = append(productFiles, h.productFiles[id])
+			}
+
+			respond(w, http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: productFiles})
+		}),
+
+		route("GET", release+`/product_files/(\d+)`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			pf := h.productFiles[atoi(params[2])]
+			pf.Links = &pivnet.Links{
+				Download: map[string]string{
+					"href": fmt.Sprintf("%s%s/products/%s/product_files/%d/download", h.server.URL(), apiPrefix, params[0], pf.ID),
+				},
+			}
+
+			respond(w, http.StatusOK, pivnet.ProductFileResponse{ProductFile: pf})
+		}),
+
+		route("POST", apiPrefix+`/products/([^/]+)/product_files/(\d+)/download`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			w.Header().Set("Location", fmt.Sprintf("%s/content/%s", h.server.URL(), params[1]))
+			w.WriteHeader(http.StatusFound)
+		}),
+
+		route("HEAD", `/content/(\d+)`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(h.contents[atoi(params[0])])))
+			w.WriteHeader(http.StatusOK)
+		}),
+
+		route("GET", `/content/(\d+)`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			content := h.contents[atoi(params[0])]
+			matches := regexp.MustCompile(`bytes=(\d+)-(\d+)`).FindStringSubmatch(req.Header.Get("Range"))
+
+			w.WriteHeader(http.StatusPartialContent)
+			_, err := w.Write(content[atoi(matches[1]) : atoi(matches[2])+1])
+			Expect(err).NotTo(HaveOccurred())
+		}),
+
+		route("PATCH", release+`/add_product_file`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			var body struct {
+				ProductFile pivnet.ProductFile `json:"product_file"`
+			}
+			decode(req, &body)
+
+			state := h.releaseState[atoi(params[1])]
+			state.productFiles = append(state.productFiles, body.ProductFile.ID)
+
+			respond(w, http.StatusNoContent, nil)
+		}),
+
+		route("GET", release+`/file_groups`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			fileGroups := []pivnet.FileGroup{}
+			for _, id := range h.releaseState[atoi(params[1])].fileGroups {
+				fileGroups = append(fileGroups, h.fileGroups[id])
+			}
+
+			respond(w, http.StatusOK, pivnet.FileGroupsResponse{FileGroups: fileGroups})
+		}),
+
+		route("GET", apiPrefix+`/products/([^/]+)/file_groups`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			fileGroups := []pivnet.FileGroup{}
+			for _, fg := range h.fileGroups {
+				fileGroups = append(fileGroups, fg)
+			}
+
+			sort.Slice(fileGroups, func(i, j int) bool {
+				return fileGroups[i].ID < fileGroups[j].ID
+			})
+
+			respond(w, http.StatusOK, pivnet.FileGroupsResponse{FileGroups: fileGroups})
+		}),
+
+		route("POST", apiPrefix+`/products/([^/]+)/file_groups`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			var body struct {
+				FileGroup pivnet.FileGroup `json:"file_group"`
+			}
+			decode(req, &body)
+
+			fileGroup := pivnet.FileGroup{ID: h.id(), Name: body.FileGroup.Name}
+			h.fileGroups[fileGroup.ID] = fileGroup
+
+			respond(w, http.StatusCreated, fileGroup)
+		}),
+
+		route("PATCH", release+`/add_file_group`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			var body struct {
+				FileGroup pivnet.FileGroup `json:"file_group"`
+			}
+			decode(req, &body)
+
+			state := h.releaseState[atoi(params[1])]
+			state.fileGroups = append(state.fileGroups, body.FileGroup.ID)
+
+			respond(w, http.StatusNoContent, nil)
+		}),
+
+		route("PATCH", apiPrefix+`/products/([^/]+)/file_groups/(\d+)/add_product_file`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			var body struct {
+				ProductFile pivnet.ProductFile `json:"product_file"`
+			}
+			decode(req, &body)
+
+			fileGroup := h.fileGroups[atoi(params[1])]
+			fileGroup.ProductFiles = append(fileGroup.ProductFiles, h.productFiles[body.ProductFile.ID])
+			h.fileGroups[fileGroup.ID] = fileGroup
+
+			respond(w, http.StatusNoContent, nil)
+		}),
+
+		route("GET", release+`/dependencies`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			respond(w, http.StatusOK, pivnet.ReleaseDependenciesResponse{ReleaseDependencies: h.releaseState[atoi(params[1])].dependencies})
+		}),
+
+		route("PATCH", release+`/add_dependency`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			var body struct {
+				Dependency struct {
+					ReleaseID int `json:"release_id"`
+				} `json:"dependency"`
+			}
+			decode(req, &body)
+
+			for slug, releases := range h.releases {
+				for _, r := range releases {
+					if r.ID == body.Dependency.ReleaseID {
+						state := h.releaseState[atoi(params[1])]
+						state.dependencies = append(state.dependencies, pivnet.ReleaseDependency{
+							Release: pivnet.DependentRelease{ID: r.ID, Version: r.Version, Product: pivnet.Product{Slug: slug}},
+						})
+					}
+				}
+			}
+
+			respond(w, http.StatusNoContent, nil)
+		}),
+
+		route("GET", release+`/dependency_specifiers`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			respond(w, http.StatusOK, pivnet.DependencySpecifiersResponse{DependencySpecifiers: h.releaseState[atoi(params[1])].specifiers})
+		}),
+
+		route("POST", release+`/dependency_specifiers`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			var body struct {
+				DependencySpecifier struct {
+					ProductSlug string `json:"product_slug"`
+					Specifier   string `json:"specifier"`
+				} `json:"dependency_specifier"`
+			}
+			decode(req, &body)
+
+			specifier := pivnet.DependencySpecifier{
+				ID:        h.id(),
+				Product:   pivnet.Product{Slug: body.DependencySpecifier.ProductSlug},
+				Specifier: body.DependencySpecifier.Specifier,
+			}
+			state := h.releaseState[atoi(params[1])]
+			state.specifiers = append(state.specifiers, specifier)
+
+			respond(w, http.StatusCreated, pivnet.DependencySpecifierResponse{DependencySpecifier: specifier})
+		}),
+
+		route("GET", release+`/upgrade_paths`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			respond(w, http.StatusOK, pivnet.ReleaseUpgradePathsResponse{ReleaseUpgradePaths: h.releaseState[atoi(params[1])].upgradePaths})
+		}),
+
+		route("PATCH", release+`/add_upgrade_path`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			var body struct {
+				UpgradePath struct {
+					ReleaseID int `json:"release_id"`
+				} `json:"upgrade_path"`
+			}
+			decode(req, &body)
+
+			for _, r := range h.releases[params[0]] {
+				if r.ID == body.UpgradePath.ReleaseID {
+					state := h.releaseState[atoi(params[1])]
+					state.upgradePaths = append(state.upgradePaths, pivnet.ReleaseUpgradePath{
+						Release: pivnet.UpgradePathRelease{ID: r.ID, Version: r.Version},
+					})
+				}
+			}
+
+			respond(w, http.StatusNoContent, nil)
+		}),
+
+		route("GET", apiPrefix+`/user_groups`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			respond(w, http.StatusOK, pivnet.UserGroupsResponse{UserGroups: h.userGroups})
+		}),
+
+		route("GET", release+`/user_groups`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			userGroups := []pivnet.UserGroup{}
+			for _, id := range h.releaseState[atoi(params[1])].userGroups {
+				for _, g := range h.userGroups {
+					if g.ID == id {
+						userGroups = append(userGroups, g)
+					}
+				}
+			}
+
+			respond(w, http.StatusOK, pivnet.UserGroupsResponse{UserGroups: userGroups})
+		}),
+
+		route("PATCH", release+`/add_user_group`, func(w http.ResponseWriter, req *http.Request, params []string) {
+			var body struct {
+				UserGroup pivnet.UserGroup `json:"user_group"`
+			}
+			decode(req, &body)
+
+			state := h.releaseState[atoi(params[1])]
+			state.userGroups = append(state.userGroups, body.UserGroup.ID)
+
+			respond(w, http.StatusNoContent, nil)
+		}),
+	}
+}
+
+const (
+	productSlug          = "some-product"
+	dependentProductSlug = "other-product"
+)
+
+var fixtureContents = map[string][]byte{
+	"product-1.0.pivotal": []byte("some tile contents"),
+	"cli-linux-1.0":       []byte("some linux cli contents"),
+	"cli-windows-1.0.exe": []byte("some windows cli contents"),
+}
+
+// AddFixtureRelease adds a release of productSlug with a product file, a file
+// group, a dependency, a dependency specifier, an upgrade path and a user
+// group.
+func (h *fakeHost) AddFixtureRelease() pivnet.Release {
+	dependency := h.AddRelease(dependentProductSlug, pivnet.Release{Version: "2.0.0"})
+	previous := h.AddRelease(productSlug, pivnet.Release{Version: "0.9.0"})
+
+	release := h.AddRelease(productSlug, pivnet.Release{
+		Version:     "1.0.0",
+		ReleaseType: "Major Release",
+		EULA:        &pivnet.EULA{Slug: "some-eula"},
+		Description: "some description",
+	})
+
+	var productFiles []pivnet.ProductFile
+	for _, name := range []string{"product-1.0.pivotal", "cli-linux-1.0", "cli-windows-1.0.exe"} {
+		contents := fixtureContents[name]
+		releaseID := 0
+		if name == "product-1.0.pivotal" {
+			releaseID = release.ID
+		}
+
+		productFiles = append(productFiles, h.AddProductFile(releaseID, pivnet.ProductFile{
+			Name:         name,
+			AWSObjectKey: "product-files/" + productSlug + "/" + name,
+			FileType:     "Software",
+			FileVersion:  "1.0",
+			SHA256:       fmt.Sprintf("%x", sha256.Sum256(contents)),
+			MD5:          fmt.Sprintf("%x", md5.Sum(contents)),
+			Size:         len(contents),
+		}, contents))
+	}
+
+	h.AddFileGroup(release.ID, "CLIs", productFiles[1], productFiles[2])
+	h.AddDependency(release.ID, dependentProductSlug, dependency)
+	h.AddDependencySpecifier(release.ID, dependentProductSlug, "~> 2.0")
+	h.AddUpgradePath(release.ID, previous)
+	h.AddUserGroup(release.ID, "partners")
+
+	return release
+}
@@ -0,0 +1,631 @@
+package bundle
+
+import (
+	"archive/tar"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+type ImportConfig struct {
+	// Path is the bundle to import
+	Path string
+
+	// ProductSlug, when set, imports the release into this product instead
+	// of the one it was exported from.
+	ProductSlug string
+
+	// UploadFile stores the contents of a product file under its AWS object
+	// key on the target host. It is only called for product files that do
+	// not yet exist and whose contents are included in the bundle. When
+	// unset, the objects are assumed to have been uploaded already.
+	UploadFile func(awsObjectKey string, contents io.Reader, size int64) error
+}
+
+type ImportReport struct {
+	Release pivnet.Release
+
+	// Changes describes everything created or attached on the target host.
+	// It is empty when the bundle had already been imported.
+	Changes []string
+
+	// Unresolved describes the dependencies, upgrade paths and user groups
+	// that do not exist on the target host and so could not be attached.
+	Unresolved []string
+}
+
+// Import recreates the release in the bundle, along with its product files,
+// file groups, dependencies, dependency specifiers, upgrade paths and user
+// groups. Anything that already exists is left as is, so an interrupted
+// import can be re-run.
+func (b Bundler) Import(config ImportConfig) (ImportReport, error) {
+	metadata, err := Read(config.Path)
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	productSlug := config.ProductSlug
+	if productSlug == "" {
+		productSlug = metadata.ProductSlug
+	}
+
+	i := &importer{
+		client:      b.client,
+		logger:      b.logger,
+		config:      config,
+		metadata:    metadata,
+		productSlug: productSlug,
+		releases:    make(map[string][]pivnet.Release),
+	}
+
+	b.logger.Info("Importing release", logger.Data{"product": productSlug, "version": metadata.Release.Version})
+
+	steps := []func() error{
+		i.importRelease,
+		i.importProductFiles,
+		i.importFileGroups,
+		i.importDependencies,
+		i.importDependencySpecifiers,
+		i.importUpgradePaths,
+		i.importUserGroups,
+	}
+
+	for _, step := range steps {
+		err = step()
+		if err != nil {
+			return i.report, err
+		}
+	}
+
+	return i.report, nil
+}
+
+type importer struct {
+	client      pivnet.Client
+	logger      logger.Logger
+	config      ImportConfig
+	metadata    Metadata
+	productSlug string
+
+	report ImportReport
+
+	// productFiles are the target host's product files by AWS object key
+	productFiles map[string]pivnet.ProductFile
+
+	// releases caches the target host's releases by product slug
+	releases map[string][]pivnet.Release
+}
+
+func (i *importer) changed(format string, a ...interface{}) {
+	change := fmt.Sprintf(format, a...)
+	i.logger.Debug("Imported", logger.Data{"change": change})
+	i.report.Changes = append(i.report.Changes, change)
+}
+
+func (i *importer) unresolved(format string, a ...interface{}) {
+	unresolved := fmt.Sprintf(format, a...)
+	i.logger.Info("Could not resolve", logger.Data{"unresolved": unresolved})
+	i.report.Unresolved = append(i.report.Unresolved, unresolved)
+}
+
+func (i *importer) findRelease(productSlug string, version string) (pivnet.Release, bool, error) {
+	releases, ok := i.releases[productSlug]
+	if !ok {
+		var err error
+		releases, err = i.client.Releases.List(productSlug)
+		if err != nil {
+			return pivnet.Release{}, false, err
+		}
+
+		i.releases[productSlug] = releases
+	}
+
+	for _, release := range releases {
+		if release.Version == version {
+			return release, true, nil
+		}
+	}
+
+	return pivnet.Release{}, false, nil
+}
+
+func (i *importer) importRelease() error {
+	release, found, err := i.findRelease(i.productSlug, i.metadata.Release.Version)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		r := i.metadata.Release
+		release, err = i.client.Releases.Create(pivnet.CreateReleaseConfig{
+			ProductSlug:           i.productSlug,
+			Version:               r.Version,
+			ReleaseType:           r.ReleaseType,
+			ReleaseDate:           r.ReleaseDate,
+			EULASlug:              i.metadata.EULASlug,
+			Description:           r.Description,
+			ReleaseNotesURL:       r.ReleaseNotesURL,
+			Controlled:            r.Controlled,
+			ECCN:                  r.ECCN,
+			LicenseException:      r.LicenseException,
+			EndOfSupportDate:      r.EndOfSupportDate,
+			EndOfGuidanceDate:     r.EndOfGuidanceDate,
+			EndOfAvailabilityDate: r.EndOfAvailabilityDate,
+		})
+		if err != nil {
+			return err
+		}
+
+		i.changed("created release %s", release.Version)
+	}
+
+	i.report.Release = release
+
+	return nil
+}
+
+func (i *importer) importProductFiles() error {
+	existing, err := i.client.ProductFiles.List(i.productSlug)
+	if err != nil {
+		return err
+	}
+
+	i.productFiles = make(map[string]pivnet.ProductFile)
+	for _, pf := range existing {
+		i.productFiles[pf.AWSObjectKey] = pf
+	}
+
+	uploads := make(map[string]File)
+	for _, file := range i.metadata.ProductFiles {
+		_, ok := i.productFiles[file.ProductFile.AWSObjectKey]
+		if !ok && file.Path != "" && i.config.UploadFile != nil {
+			uploads[file.Path] = file
+		}
+	}
+
+	if len(uploads) > 0 {
+		err = i.upload(uploads)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range i.metadata.ProductFiles {
+		pf := file.ProductFile
+		if _, ok := i.productFiles[pf.AWSObjectKey]; ok {
+			continue
+		}
+
+		created, err := i.client.ProductFiles.Create(pivnet.CreateProductFileConfig{
+			ProductSlug:        i.productSlug,
+			AWSObjectKey:       pf.AWSObjectKey,
+			Description:        pf.Description,
+			DocsURL:            pf.DocsURL,
+			FileType:           pf.FileType,
+			FileVersion:        pf.FileVersion,
+			IncludedFiles:      pf.IncludedFiles,
+			MD5:                pf.MD5,
+			SHA256:             pf.SHA256,
+			Name:               pf.Name,
+			Platforms:          pf.Platforms,
+			ReleasedAt:         pf.ReleasedAt,
+			SystemRequirements: pf.SystemRequirements,
+		})
+		if err != nil {
+			return err
+		}
+
+		i.productFiles[pf.AWSObjectKey] = created
+		i.changed("created product file %s", pf.AWSObjectKey)
+	}
+
+	inRelease, err := i.client.ProductFiles.ListForRelease(i.productSlug, i.report.Release.ID)
+	if err != nil {
+		return err
+	}
+
+	added := make(map[string]bool)
+	for _, pf := range inRelease {
+		added[pf.AWSObjectKey] = true
+	}
+
+	for _, key := range i.metadata.ReleaseProductFiles {
+		if added[key] {
+			continue
+		}
+
+		err = i.client.ProductFiles.AddToRelease(i.productSlug, i.report.Release.ID, i.productFiles[key].ID)
+		if err != nil {
+			return err
+		}
+
+		i.changed("added product file %s to release", key)
+	}
+
+	return nil
+}
+
+// upload passes the contents of each file in uploads, keyed by their path in
+// the bundle, to UploadFile. The contents are first verified against the
+// exported checksums in a separate pass over the bundle, so that nothing is
+// uploaded from a corrupt bundle.
+func (i *importer) upload(uploads map[string]File) error {
+	err := i.verify(uploads)
+	if err != nil {
+		return err
+	}
+
+	return walk(i.config.Path, func(header *tar.Header, r io.Reader) error {
+		file, ok := uploads[header.Name]
+		if !ok {
+			return nil
+		}
+
+		pf := file.ProductFile
+
+		i.logger.Info("Uploading product file", logger.Data{"awsObjectKey": pf.AWSObjectKey, "size": header.Size})
+
+		err := i.config.UploadFile(pf.AWSObjectKey, r, header.Size)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %s", pf.AWSObjectKey, err)
+		}
+
+		i.changed("uploaded product file %s", pf.AWSObjectKey)
+
+		return nil
+	})
+}
+
+// verify checks that each file in uploads is in the bundle and matches its
+// exported checksum.
+func (i *importer) verify(uploads map[string]File) error {
+	remaining := make(map[string]bool)
+	for name := range uploads {
+		remaining[name] = true
+	}
+
+	err := walk(i.config.Path, func(header *tar.Header, r io.Reader) error {
+		file, ok := uploads[header.Name]
+		if !ok {
+			return nil
+		}
+		delete(remaining, header.Name)
+
+		pf := file.ProductFile
+		md5Hash := md5.New()
+		sha256Hash := sha256.New()
+
+		_, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s from bundle: %s", header.Name, err)
+		}
+
+		if pf.SHA256 != "" {
+			actual := hex.EncodeToString(sha256Hash.Sum(nil))
+			if actual != pf.SHA256 {
+				return fmt.Errorf("sha256 of %s in bundle does not match: expected %s, got %s", header.Name, pf.SHA256, actual)
+			}
+		} else if pf.MD5 != "" {
+			actual := hex.EncodeToString(md5Hash.Sum(nil))
+			if actual != pf.MD5 {
+				return fmt.Errorf("md5 of %s in bundle does not match: expected %s, got %s", header.Name, pf.MD5, actual)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for name := range remaining {
+		return fmt.Errorf("%s not found in bundle", name)
+	}
+
+	return nil
+}
+
+func (i *importer) importFileGroups() error {
+	if len(i.metadata.FileGroups) == 0 {
+		return nil
+	}
+
+	existing, err := i.client.FileGroups.ListForRelease(i.productSlug, i.report.Release.ID)
+	if err != nil {
+		return err
+	}
+
+	// unattached lists the product's file groups and is only fetched when a
+	// file group is missing from the release
+	var unattached []pivnet.FileGroup
+
+	for _, group := range i.metadata.FileGroups {
+		var fileGroup pivnet.FileGroup
+		found := false
+		for _, fg := range existing {
+			if fg.Name == group.Name {
+				fileGroup = fg
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			if unattached == nil {
+				unattached, err = i.unattachedFileGroups(existing)
+				if err != nil {
+					return err
+				}
+			}
+
+			// A group left behind by an interrupted import is reused
+			// rather than created again
+			for _, fg := range unattached {
+				if fg.Name == group.Name && onlyContains(fg, group.ProductFiles) {
+					fileGroup = fg
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				fileGroup, err = i.client.FileGroups.Create(i.productSlug, group.Name)
+				if err != nil {
+					return err
+				}
+
+				i.changed("created file group %s", group.Name)
+			}
+
+			err = i.client.FileGroups.AddToRelease(i.productSlug, i.report.Release.ID, fileGroup.ID)
+			if err != nil {
+				return err
+			}
+
+			i.changed("added file group %s to release", group.Name)
+		}
+
+		members := make(map[string]bool)
+		for _, pf := range fileGroup.ProductFiles {
+			members[pf.AWSObjectKey] = true
+		}
+
+		for _, key := range group.ProductFiles {
+			if members[key] {
+				continue
+			}
+
+			err = i.client.ProductFiles.AddToFileGroup(i.productSlug, fileGroup.ID, i.productFiles[key].ID)
+			if err != nil {
+				return err
+			}
+
+			i.changed("added product file %s to file group %s", key, group.Name)
+		}
+	}
+
+	return nil
+}
+
+// unattachedFileGroups returns the product's file groups other than those in
+// the release.
+func (i *importer) unattachedFileGroups(inRelease []pivnet.FileGroup) ([]pivnet.FileGroup, error) {
+	all, err := i.client.FileGroups.List(i.productSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	attached := make(map[int]bool)
+	for _, fg := range inRelease {
+		attached[fg.ID] = true
+	}
+
+	unattached := []pivnet.FileGroup{}
+	for _, fg := range all {
+		if !attached[fg.ID] {
+			unattached = append(unattached, fg)
+		}
+	}
+
+	return unattached, nil
+}
+
+// onlyContains reports whether every product file in fileGroup has one of
+// the AWS object keys, so that a group with the same name belonging to
+// another release is not mistaken for one this import created.
+func onlyContains(fileGroup pivnet.FileGroup, awsObjectKeys []string) bool {
+	keys := make(map[string]bool)
+	for _, key := range awsObjectKeys {
+		keys[key] = true
+	}
+
+	for _, pf := range fileGroup.ProductFiles {
+		if !keys[pf.AWSObjectKey] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (i *importer) importDependencies() error {
+	if len(i.metadata.Dependencies) == 0 {
+		return nil
+	}
+
+	existing, err := i.client.ReleaseDependencies.List(i.productSlug, i.report.Release.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, dependency := range i.metadata.Dependencies {
+		found := false
+		for _, d := range existing {
+			if d.Release.Product.Slug == dependency.ProductSlug && d.Release.Version == dependency.Version {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		release, ok, err := i.findRelease(dependency.ProductSlug, dependency.Version)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			i.unresolved("dependency on %s %s", dependency.ProductSlug, dependency.Version)
+			continue
+		}
+
+		err = i.client.ReleaseDependencies.Add(i.productSlug, i.report.Release.ID, release.ID)
+		if err != nil {
+			return err
+		}
+
+		i.changed("added dependency on %s %s", dependency.ProductSlug, dependency.Version)
+	}
+
+	return nil
+}
+
+func (i *importer) importDependencySpecifiers() error {
+	if len(i.metadata.DependencySpecifiers) == 0 {
+		return nil
+	}
+
+	existing, err := i.client.DependencySpecifiers.List(i.productSlug, i.report.Release.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, specifier := range i.metadata.DependencySpecifiers {
+		found := false
+		for _, s := range existing {
+			if s.Product.Slug == specifier.ProductSlug && s.Specifier == specifier.Specifier {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		_, err = i.client.DependencySpecifiers.Create(i.productSlug, i.report.Release.ID, specifier.ProductSlug, specifier.Specifier)
+		if err != nil {
+			return err
+		}
+
+		i.changed("created dependency specifier %s %s", specifier.ProductSlug, specifier.Specifier)
+	}
+
+	return nil
+}
+
+func (i *importer) importUpgradePaths() error {
+	if len(i.metadata.UpgradePaths) == 0 {
+		return nil
+	}
+
+	existing, err := i.client.ReleaseUpgradePaths.Get(i.productSlug, i.report.Release.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range i.metadata.UpgradePaths {
+		found := false
+		for _, u := range existing {
+			if u.Release.Version == version {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		release, ok, err := i.findRelease(i.productSlug, version)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			i.unresolved("upgrade path from %s", version)
+			continue
+		}
+
+		err = i.client.ReleaseUpgradePaths.Add(i.productSlug, i.report.Release.ID, release.ID)
+		if err != nil {
+			return err
+		}
+
+		i.changed("added upgrade path from %s", version)
+	}
+
+	return nil
+}
+
+func (i *importer) importUserGroups() error {
+	if len(i.metadata.UserGroups) == 0 {
+		return nil
+	}
+
+	existing, err := i.client.UserGroups.ListForRelease(i.productSlug, i.report.Release.ID)
+	if err != nil {
+		return err
+	}
+
+	var all []pivnet.UserGroup
+	for _, name := range i.metadata.UserGroups {
+		found := false
+		for _, g := range existing {
+			if g.Name == name {
+				found = true
+				break
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		if all == nil {
+			all, err = i.client.UserGroups.List()
+			if err != nil {
+				return err
+			}
+		}
+
+		userGroupID := 0
+		for _, g := range all {
+			if g.Name == name {
+				userGroupID = g.ID
+				break
+			}
+		}
+
+		if userGroupID == 0 {
+			i.unresolved("user group %s", name)
+			continue
+		}
+
+		err = i.client.UserGroups.AddToRelease(i.productSlug, i.report.Release.ID, userGroupID)
+		if err != nil {
+			return err
+		}
+
+		i.changed("added user group %s", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,277 @@
+package bundle_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/bundle"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Import", func() {
+	var (
+		source *fakeHost
+		target *fakeHost
+		b      bundle.Bundler
+
+		dir          string
+		bundlePath   string
+		includeFiles bool
+
+		uploaded   map[string][]byte
+		uploadFile func(awsObjectKey string, contents io.Reader, size int64) error
+	)
+
+	newBundler := func(host *fakeHost) bundle.Bundler {
+		client := pivnet.NewClient(pivnet.ClientConfig{
+			Host:  host.server.URL(),
+			Token: "my-auth-token",
+		}, &loggerfakes.FakeLogger{})
+
+		return bundle.New(client, &loggerfakes.FakeLogger{})
+	}
+
+	importBundle := func() (bundle.ImportReport, error) {
+		return b.Import(bundle.ImportConfig{
+			Path:       bundlePath,
+			UploadFile: uploadFile,
+		})
+	}
+
+	BeforeEach(func() {
+		source = newFakeHost()
+		target = newFakeHost()
+		b = newBundler(target)
+
+		var err error
+		dir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		bundlePath = filepath.Join(dir, "release.tgz")
+		includeFiles = true
+
+		uploaded = make(map[string][]byte)
+		uploadFile = func(awsObjectKey string, contents io.Reader, size int64) error {
+			b, err := ioutil.ReadAll(contents)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b).To(HaveLen(int(size)))
+
+			uploaded[awsObjectKey] = b
+			return nil
+		}
+	})
+
+	JustBeforeEach(func() {
+		release := source.AddFixtureRelease()
+
+		_, err := newBundler(source).Export(bundle.ExportConfig{
+			ProductSlug:  productSlug,
+			ReleaseID:    release.ID,
+			Path:         bundlePath,
+			IncludeFiles: includeFiles,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		source.Close()
+		target.Close()
+
+		err := os.RemoveAll(dir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("when the target host has the dependencies, previous release and user group", func() {
+		BeforeEach(func() {
+			target.AddRelease(dependentProductSlug, pivnet.Release{Version: "2.0.0"})
+			target.AddRelease(productSlug, pivnet.Release{Version: "0.9.0"})
+			target.AddUserGroup(0, "partners")
+		})
+
+		It("recreates the release and everything attached to it", func() {
+			report, err := importBundle()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Release.Version).To(Equal("1.0.0"))
+			Expect(report.Unresolved).To(BeEmpty())
+			Expect(report.Changes).To(ContainElement("created release 1.0.0"))
+
+			Expect(uploaded).To(HaveLen(3))
+			for key, contents := range uploaded {
+				Expect(contents).To(Equal(fixtureContents[filepath.Base(key)]))
+			}
+
+			exportedPath := filepath.Join(dir, "exported.tgz")
+			_, err = newBundler(target).Export(bundle.ExportConfig{
+				ProductSlug: productSlug,
+				ReleaseID:   report.Release.ID,
+				Path:        exportedPath,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			expected, err := bundle.Read(bundlePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			actual, err := bundle.Read(exportedPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(actual.Release.Version).To(Equal(expected.Release.Version))
+			Expect(actual.Release.Description).To(Equal(expected.Release.Description))
+			Expect(actual.ReleaseProductFiles).To(Equal(expected.ReleaseProductFiles))
+			Expect(actual.FileGroups).To(Equal(expected.FileGroups))
+			Expect(actual.Dependencies).To(Equal(expected.Dependencies))
+			Expect(actual.DependencySpecifiers).To(Equal(expected.DependencySpecifiers))
+			Expect(actual.UpgradePaths).To(Equal(expected.UpgradePaths))
+			Expect(actual.UserGroups).To(Equal(expected.UserGroups))
+
+			checksums := func(metadata bundle.Metadata) map[string]string {
+				m := make(map[string]string)
+				for _, file := range metadata.ProductFiles {
+					m[file.ProductFile.AWSObjectKey] = file.ProductFile.MD5
+				}
+				return m
+			}
+			Expect(checksums(actual)).To(Equal(checksums(expected)))
+		})
+
+		It("changes nothing when re-run", func() {
+			_, err := importBundle()
+			Expect(err).NotTo(HaveOccurred())
+
+			mutations := target.Mutations()
+			uploaded = make(map[string][]byte)
+
+			report, err := importBundle()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Release.Version).To(Equal("1.0.0"))
+			Expect(report.Changes).To(BeEmpty())
+			Expect(report.Unresolved).To(BeEmpty())
+			Expect(uploaded).To(BeEmpty())
+			Expect(target.Mutations()).To(Equal(mutations))
+		})
+
+		Context("when adding a file group to the release fails", func() {
+			BeforeEach(func() {
+				target.FailOnce("PATCH", "/add_file_group")
+			})
+
+			It("reuses the file group when re-run", func() {
+				_, err := importBundle()
+				Expect(err).To(HaveOccurred())
+
+				report, err := importBundle()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(report.Changes).To(ContainElement("added file group CLIs to release"))
+				Expect(report.Changes).NotTo(ContainElement("created file group CLIs"))
+				Expect(target.FileGroupNames()).To(Equal([]string{"CLIs"}))
+			})
+		})
+
+		Context("when file contents are not included", func() {
+			BeforeEach(func() {
+				includeFiles = false
+			})
+
+			It("creates the product files without uploading", func() {
+				report, err := importBundle()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(uploaded).To(BeEmpty())
+				Expect(report.Changes).To(ContainElement("created product file product-files/some-product/product-1.0.pivotal"))
+			})
+		})
+	})
+
+	Context("when the target host lacks the dependencies, previous release and user group", func() {
+		It("imports the rest and reports what could not be resolved", func() {
+			report, err := importBundle()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Unresolved).To(ConsistOf(
+				"dependency on other-product 2.0.0",
+				"upgrade path from 0.9.0",
+				"user group partners",
+			))
+			Expect(report.Changes).To(ContainElement("created dependency specifier other-product ~> 2.0"))
+		})
+	})
+
+	Context("when a product file in the bundle is corrupt", func() {
+		JustBeforeEach(func() {
+			corruptBundleMember(bundlePath, "cli-linux-1.0")
+		})
+
+		It("returns an error without uploading anything", func() {
+			_, err := importBundle()
+			Expect(err).To(MatchError(ContainSubstring("in bundle does not match")))
+
+			Expect(uploaded).To(BeEmpty())
+		})
+	})
+
+	Context("when uploading a product file fails", func() {
+		BeforeEach(func() {
+			uploadFile = func(awsObjectKey string, contents io.Reader, size int64) error {
+				return io.ErrUnexpectedEOF
+			}
+		})
+
+		It("returns an error without creating the product file", func() {
+			report, err := importBundle()
+			Expect(err).To(MatchError(ContainSubstring("failed to upload")))
+
+			Expect(report.Changes).To(Equal([]string{"created release 1.0.0"}))
+		})
+	})
+})
+
+// corruptBundleMember rewrites the bundle at path, flipping the first byte of
+// the member with the given base name.
+func corruptBundleMember(path string, baseName string) {
+	f, err := os.Open(path)
+	Expect(err).NotTo(HaveOccurred())
+
+	gzipReader, err := gzip.NewReader(f)
+	Expect(err).NotTo(HaveOccurred())
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := ioutil.ReadAll(tarReader)
+		Expect(err).NotTo(HaveOccurred())
+
+		if len(contents) > 0 && filepath.Base(header.Name) == baseName {
+			contents[0] ^= 0xff
+		}
+
+		Expect(tarWriter.WriteHeader(header)).To(Succeed())
+		_, err = tarWriter.Write(contents)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	Expect(tarWriter.Close()).To(Succeed())
+	Expect(gzipWriter.Close()).To(Succeed())
+	Expect(f.Close()).To(Succeed())
+
+	Expect(ioutil.WriteFile(path, buf.Bytes(), 0644)).To(Succeed())
+}
@@ -0,0 +1,340 @@
+package cache
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	AlgorithmSHA256 = "sha256"
+	AlgorithmMD5    = "md5"
+
+	// AlgorithmNone identifies keys that are not derived from the content,
+	// e.g. a product file ID and its last update time. Entries with such keys
+	// cannot be verified against their key.
+	AlgorithmNone = "none"
+)
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// Key identifies a cache entry. For checksum algorithms the value is the
+// hex-encoded checksum of the content. Size, if greater than zero, is the
+// expected length of the content; it is checked on every read and write and
+// is the only check made for AlgorithmNone keys.
+type Key struct {
+	Algorithm string
+	Value     string
+	Size      int64
+}
+
+func (k Key) fileName() string {
+	return fmt.Sprintf(
+		"%s-%s",
+		k.Algorithm,
+		unsafeNameChars.ReplaceAllString(strings.ToLower(k.Value), "_"),
+	)
+}
+
+func (k Key) newHash() hash.Hash {
+	switch k.Algorithm {
+	case AlgorithmSHA256:
+		return sha256.New()
+	case AlgorithmMD5:
+		return md5.New()
+	default:
+		return nil
+	}
+}
+
+func (k Key) matchesSize(size int64) bool {
+	return k.Size < 1 || k.Size == size
+}
+
+func (k Key) matches(h hash.Hash) bool {
+	if h == nil {
+		return true
+	}
+
+	return hex.EncodeToString(h.Sum(nil)) == strings.ToLower(k.Value)
+}
+
+// Cache is a directory of downloaded files addressed by Key. It is safe for
+// concurrent use by multiple goroutines and processes sharing the directory.
+// Once the entries exceed the maximum size the least recently used entries
+// are evicted.
+type Cache struct {
+	dir     string
+	maxSize int64
+}
+
+// New returns a Cache rooted at dir. A maxSize less than one disables
+// eviction. The directory is created on first use.
+func New(dir string, maxSize int64) Cache {
+	return Cache{
+		dir:     dir,
+		maxSize: maxSize,
+	}
+}
+
+// Get copies the entry for key into dst, replacing its contents. It returns
+// false if there is no entry or if the entry does not match the size or
+// checksum in its key, in which case the entry is removed and dst is left
+// empty.
+func (c Cache) Get(key Key, dst *os.File) (bool, error) {
+	entry, err := c.open(key)
+	if err != nil {
+		return false, err
+	}
+
+	if entry == nil {
+		return false, nil
+	}
+	defer entry.Close()
+
+	stat, err := entry.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	if !key.matchesSize(stat.Size()) {
+		return false, c.remove(key)
+	}
+
+	err = truncate(dst)
+	if err != nil {
+		return false, err
+	}
+
+	h := key.newHash()
+
+	var w io.Writer = dst
+	if h != nil {
+		w = io.MultiWriter(dst, h)
+	}
+
+	_, err = io.Copy(w, entry)
+	if err != nil {
+		return false, err
+	}
+
+	if !key.matches(h) {
+		err = c.remove(key)
+		if err != nil {
+			return false, err
+		}
+
+		return false, truncate(dst)
+	}
+
+	return true, nil
+}
+
+// Put stores the contents of src under key. The contents must match the
+// size and checksum in the key.
+func (c Cache) Put(key Key, src *os.File) error {
+	err := os.MkdirAll(c.tmpDir(), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(c.entriesDir(), 0755)
+	if err != nil {
+		return err
+	}
+
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	if !key.matchesSize(stat.Size()) {
+		return fmt.Errorf("content is %d bytes, expected %d", stat.Size(), key.Size)
+	}
+
+	tmp, err := ioutil.TempFile(c.tmpDir(), "entry-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := key.newHash()
+
+	var w io.Writer = tmp
+	if h != nil {
+		w = io.MultiWriter(tmp, h)
+	}
+
+	_, err = io.Copy(w, io.NewSectionReader(src, 0, stat.Size()))
+	if err != nil {
+		return err
+	}
+
+	if !key.matches(h) {
+		return fmt.Errorf("content does not match %s checksum %s", key.Algorithm, key.Value)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = os.Rename(tmp.Name(), c.entryPath(key))
+	if err != nil {
+		return err
+	}
+
+	return c.evict(key)
+}
+
+// open returns the entry for key, or nil if there is none, marking it as
+// recently used. The open file remains readable if the entry is evicted.
+func (c Cache) open(key Key) (*os.File, error) {
+	unlock, err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entry, err := os.Open(c.entryPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	now := time.Now()
+	err = os.Chtimes(entry.Name(), now, now)
+	if err != nil {
+		entry.Close()
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (c Cache) remove(key Key) error {
+	unlock, err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = os.Remove(c.entryPath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// evict removes the least recently used entries other than keep until the
+// cache fits within its maximum size. It must be called with the lock held.
+func (c Cache) evict(keep Key) error {
+	if c.maxSize < 1 {
+		return nil
+	}
+
+	infos, err := ioutil.ReadDir(c.entriesDir())
+	if err != nil {
+		return err
+	}
+
+	sort.Sort(byModTime(infos))
+
+	var total int64
+	for _, info := range infos {
+		total += info.Size()
+	}
+
+	for _, info := range infos {
+		if total <= c.maxSize {
+			break
+		}
+
+		if info.Name() == keep.fileName() {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(c.entriesDir(), info.Name()))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		total -= info.Size()
+	}
+
+	return nil
+}
+
+// lock takes an exclusive lock on the cache directory that is honoured by
+// other processes, returning a function that releases it.
+func (c Cache) lock() (func(), error) {
+	err := os.MkdirAll(c.dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(c.dir, "lock"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = lockFile(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func (c Cache) entriesDir() string {
+	return filepath.Join(c.dir, "entries")
+}
+
+func (c Cache) tmpDir() string {
+	return filepath.Join(c.dir, "tmp")
+}
+
+func (c Cache) entryPath(key Key) string {
+	return filepath.Join(c.entriesDir(), key.fileName())
+}
+
+func truncate(f *os.File) error {
+	err := f.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+type byModTime []os.FileInfo
+
+func (b byModTime) Len() int           { return len(b) }
+func (b byModTime) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byModTime) Less(i, j int) bool { return b[i].ModTime().Before(b[j].ModTime()) }
@@ -0,0 +1,198 @@
+package cache_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet/cache"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		dir     string
+		content []byte
+		key     cache.Key
+		c       cache.Cache
+	)
+
+	newFile := func(content []byte) *os.File {
+		f, err := ioutil.TempFile("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = f.Write(content)
+		Expect(err).NotTo(HaveOccurred())
+
+		return f
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		content = []byte("some file contents")
+
+		sum := sha256.Sum256(content)
+		key = cache.Key{
+			Algorithm: cache.AlgorithmSHA256,
+			Value:     hex.EncodeToString(sum[:]),
+		}
+
+		c = cache.New(dir, 0)
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(dir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("Get", func() {
+		It("copies a stored entry into the destination", func() {
+			err := c.Put(key, newFile(content))
+			Expect(err).NotTo(HaveOccurred())
+
+			dst := newFile([]byte("some stale contents that are longer"))
+
+			found, err := c.Get(key, dst)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeTrue())
+
+			contents, err := ioutil.ReadFile(dst.Name())
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(contents).To(Equal(content))
+		})
+
+		It("reports a miss when there is no entry", func() {
+			found, err := c.Get(key, newFile(nil))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		Context("when the entry has been corrupted", func() {
+			It("removes the entry and reports a miss", func() {
+				err := c.Put(key, newFile(content))
+				Expect(err).NotTo(HaveOccurred())
+
+				entries, err := filepath.Glob(filepath.Join(dir, "entries", "*"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entries).To(HaveLen(1))
+
+				err = ioutil.WriteFile(entries[0], []byte("corrupted"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				dst := newFile(nil)
+
+				found, err := c.Get(key, dst)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeFalse())
+
+				stat, err := dst.Stat()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(stat.Size()).To(BeZero())
+
+				_, err = os.Stat(entries[0])
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Put", func() {
+		Context("when the content does not match the key", func() {
+			It("returns an error", func() {
+				err := c.Put(key, newFile([]byte("other contents")))
+				Expect(err).To(MatchError(ContainSubstring("content does not match sha256 checksum")))
+			})
+		})
+
+		Context("when the key is not a checksum", func() {
+			It("stores the content without verification", func() {
+				key = cache.Key{Algorithm: cache.AlgorithmNone, Value: "1234-2017-01-01T00:00:00Z"}
+
+				err := c.Put(key, newFile(content))
+				Expect(err).NotTo(HaveOccurred())
+
+				found, err := c.Get(key, newFile(nil))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+			})
+
+			It("checks the size of the content", func() {
+				key = cache.Key{
+					Algorithm: cache.AlgorithmNone,
+					Value:     "1234-2017-01-01T00:00:00Z",
+					Size:      int64(len(content)),
+				}
+
+				err := c.Put(key, newFile(content[1:]))
+				Expect(err).To(MatchError(ContainSubstring("expected 18")))
+
+				err = c.Put(key, newFile(content))
+				Expect(err).NotTo(HaveOccurred())
+
+				entries, err := filepath.Glob(filepath.Join(dir, "entries", "*"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entries).To(HaveLen(1))
+
+				err = ioutil.WriteFile(entries[0], content[1:], 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				found, err := c.Get(key, newFile(nil))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeFalse())
+
+				_, err = os.Stat(entries[0])
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		Context("when the cache exceeds its maximum size", func() {
+			It("evicts the least recently used entries", func() {
+				c = cache.New(dir, int64(2*len(content)))
+
+				keys := make([]cache.Key, 3)
+				for i, s := range []string{"aaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbb", "cccccccccccccccccc"} {
+					sum := md5.Sum([]byte(s))
+					keys[i] = cache.Key{
+						Algorithm: cache.AlgorithmMD5,
+						Value:     hex.EncodeToString(sum[:]),
+					}
+
+					err := c.Put(keys[i], newFile([]byte(s)))
+					Expect(err).NotTo(HaveOccurred())
+
+					// Ensure distinct modification times
+					time.Sleep(10 * time.Millisecond)
+
+					if i == 1 {
+						found, err := c.Get(keys[0], newFile(nil))
+						Expect(err).NotTo(HaveOccurred())
+						Expect(found).To(BeTrue())
+
+						time.Sleep(10 * time.Millisecond)
+					}
+				}
+
+				found, err := c.Get(keys[0], newFile(nil))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+
+				found, err = c.Get(keys[1], newFile(nil))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeFalse())
+
+				found, err = c.Get(keys[2], newFile(nil))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(BeTrue())
+			})
+		})
+	})
+})
@@ -0,0 +1,35 @@
+package download
+
+// ConnectionLimit caps the number of ranges fetched at the same time across
+// every download sharing it.
+type ConnectionLimit struct {
+	slots chan struct{}
+}
+
+// NewConnectionLimit returns a ConnectionLimit allowing n connections.
+// A limit less than one allows unlimited connections.
+func NewConnectionLimit(n int) *ConnectionLimit {
+	if n < 1 {
+		return nil
+	}
+
+	return &ConnectionLimit{
+		slots: make(chan struct{}, n),
+	}
+}
+
+func (l *ConnectionLimit) acquire() {
+	if l == nil {
+		return
+	}
+
+	l.slots <- struct{}{}
+}
+
+func (l *ConnectionLimit) release() {
+	if l == nil {
+		return
+	}
+
+	<-l.slots
+}
@@ -1,16 +1,30 @@
 package download
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 )
 
+const (
+	DefaultMaxRetries   = 5
+	DefaultRetryBackoff = time.Second
+
+	maxRetryBackoff = 30 * time.Second
+
+	// copyBufferSize is the size of the buffer each range streams through on
+	// its way to disk, bounding memory use independently of the file size.
+	copyBufferSize = 32 * 1024
+)
+
 //go:generate counterfeiter -o ./fakes/ranger.go --fake-name Ranger . ranger
 type ranger interface {
 	BuildRange(contentLength int64) ([]Range, error)
@@ -21,119 +35,382 @@ type httpClient interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
-//go:generate counterfeiter -o ./fakes/bar.go --fake-name Bar . bar
-type bar interface {
-	SetTotal(contentLength int64)
-	SetOutput(output io.Writer)
-	Add(totalWritten int) int
-	Kickoff()
-	Finish()
+//go:generate counterfeiter -o ./fakes/download_link_fetcher.go --fake-name DownloadLinkFetcher . downloadLinkFetcher
+type downloadLinkFetcher interface {
+	NewDownloadLink() (string, error)
 }
 
 type Client struct {
 	httpClient httpClient
 	ranger     ranger
-	bar        bar
+
+	// Concurrency is the maximum number of ranges fetched at the same time.
+	// A value less than one fetches every range at once.
+	Concurrency int
+
+	// ConnectionLimit, when set, caps the number of ranges fetched at once
+	// across every download sharing it, in addition to Concurrency.
+	ConnectionLimit *ConnectionLimit
+
+	// Throttle, when set, caps the combined transfer rate of every range.
+	// Share one Throttle between clients to cap their total bandwidth.
+	Throttle *Throttle
+
+	// MaxRetries is the number of times a single range is retried after a
+	// transient failure before the download is abandoned.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry of a range. It doubles
+	// with every subsequent retry, up to a maximum of 30 seconds.
+	RetryBackoff time.Duration
+
+	// Preflight, when set, is called with the destination and the content
+	// length reported by the server before any bytes are fetched. An error
+	// abandons the download and is returned unwrapped.
+	Preflight func(location *os.File, contentLength int64) error
 }
 
-func New(httpClient httpClient, ranger ranger, bar bar) Client {
+func New(httpClient httpClient, ranger ranger) Client {
 	return Client{
-		httpClient: httpClient,
-		ranger:     ranger,
-		bar:        bar,
+		httpClient:   httpClient,
+		ranger:       ranger,
+		MaxRetries:   DefaultMaxRetries,
+		RetryBackoff: DefaultRetryBackoff,
 	}
 }
 
 func (c Client) Get(
 	location *os.File,
-	contentURL string,
-	progressWriter io.Writer,
+	downloadLinkFetcher downloadLinkFetcher,
+	listener ProgressListener,
 ) error {
+	return c.GetWithContext(context.Background(), location, downloadLinkFetcher, listener)
+}
+
+// GetWithContext behaves like Get, abandoning the download when ctx is done.
+func (c Client) GetWithContext(
+	ctx context.Context,
+	location *os.File,
+	downloadLinkFetcher downloadLinkFetcher,
+	listener ProgressListener,
+) error {
+	if listener == nil {
+		listener = SilentListener{}
+	}
+
+	// Errors from the fetcher are returned unwrapped so that callers can
+	// act on their type, e.g. an unaccepted EULA.
+	contentURL, err := downloadLinkFetcher.NewDownloadLink()
+	if err != nil {
+		return err
+	}
+
 	req, err := http.NewRequest("HEAD", contentURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to construct HEAD request: %s", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to make HEAD request: %s", err)
 	}
 
-	contentURL = resp.Request.URL.String()
+	if c.Preflight != nil {
+		err = c.Preflight(location, resp.ContentLength)
+		if err != nil {
+			return err
+		}
+	}
+
+	link := &downloadLink{
+		fetcher: downloadLinkFetcher,
+		url:     resp.Request.URL.String(),
+	}
 
 	ranges, err := c.ranger.BuildRange(resp.ContentLength)
 	if err != nil {
 		return fmt.Errorf("failed to construct range: %s", err)
 	}
 
-	c.bar.SetOutput(progressWriter)
-	c.bar.SetTotal(resp.ContentLength)
-	c.bar.Kickoff()
+	progress := &transferProgress{
+		listener:    listener,
+		totalBytes:  resp.ContentLength,
+		totalRanges: len(ranges),
+	}
+
+	listener.Started(StartedEvent{
+		TotalBytes:  resp.ContentLength,
+		TotalRanges: len(ranges),
+	})
+	startTime := time.Now()
+
+	err = c.getRanges(ctx, location, link, ranges, progress)
 
-	defer c.bar.Finish()
+	listener.Finished(FinishedEvent{
+		TransferredBytes: progress.transferred(),
+		TotalBytes:       resp.ContentLength,
+		Duration:         time.Since(startTime),
+		Err:              err,
+	})
+
+	return err
+}
+
+func (c Client) getRanges(
+	ctx context.Context,
+	location io.WriterAt,
+	link *downloadLink,
+	ranges []Range,
+	progress *transferProgress,
+) error {
+	concurrency := c.Concurrency
+	if concurrency < 1 || concurrency > len(ranges) {
+		concurrency = len(ranges)
+	}
+	semaphore := make(chan struct{}, concurrency)
 
 	var g errgroup.Group
 	for _, r := range ranges {
 		byteRange := r
 		g.Go(func() error {
-			respBytes, err := c.retryableRequest(contentURL, byteRange.HTTPHeader)
-			if err != nil {
-				return fmt.Errorf("failed during retryable request: %s", err)
-			}
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			c.ConnectionLimit.acquire()
+			defer c.ConnectionLimit.release()
 
-			bytesWritten, err := location.WriteAt(respBytes, byteRange.Lower)
+			err := c.retryableRequest(ctx, link, byteRange, location, progress)
 			if err != nil {
-				return fmt.Errorf("failed to write file: %s", err)
+				return fmt.Errorf("failed during retryable request: %s", err)
 			}
 
-			c.bar.Add(bytesWritten)
+			progress.rangeCompleted(byteRange)
 
 			return nil
 		})
 	}
 
-	if err := g.Wait(); err != nil {
-		return err
+	return g.Wait()
+}
+
+// retryableRequest streams the given range into location at its offset.
+// Transient failures are retried with exponential backoff, requesting only
+// the bytes not yet written. An expired download link is replaced with a
+// fresh one before retrying.
+func (c Client) retryableRequest(
+	ctx context.Context,
+	link *downloadLink,
+	byteRange Range,
+	location io.WriterAt,
+	progress *transferProgress,
+) error {
+	writer := &offsetWriter{
+		writerAt: location,
+		offset:   byteRange.Lower,
 	}
+	buf := make([]byte, copyBufferSize)
+	backoff := c.RetryBackoff
 
-	return nil
+	for retries := 0; ; retries++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		url := link.current()
+
+		err := c.fetchRange(ctx, url, byteRange.Upper, writer, buf, progress)
+		if err == nil {
+			return nil
+		}
+
+		tempErr, ok := err.(temporaryError)
+		if !ok {
+			return err
+		}
+
+		if retries >= c.MaxRetries {
+			return fmt.Errorf("giving up after %d retries: %s", retries, tempErr.err)
+		}
+
+		progress.listener.Retrying(RetryEvent{
+			Range:   byteRange,
+			Attempt: retries + 1,
+			Err:     tempErr.err,
+		})
+
+		if tempErr.linkExpired {
+			err = link.refresh(url)
+			if err != nil {
+				return fmt.Errorf("failed to refresh download link: %s", err)
+			}
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
 }
 
-func (c Client) retryableRequest(url string, rangeHeader http.Header) ([]byte, error) {
+func (c Client) fetchRange(
+	ctx context.Context,
+	url string,
+	upper int64,
+	writer *offsetWriter,
+	buf []byte,
+	progress *transferProgress,
+) error {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return []byte{}, err
+		return err
 	}
 
-	req.Header = rangeHeader
+	req.Header = NewRange(writer.offset, upper).HTTPHeader
 
-Retry:
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		if netErr, ok := err.(net.Error); ok {
 			if netErr.Temporary() {
-				goto Retry
+				return temporaryError{err: err}
 			}
 		}
 
-		return []byte{}, err
+		return err
 	}
-
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusForbidden {
+		return temporaryError{
+			err:         fmt.Errorf("during GET unexpected status code was returned: %d", resp.StatusCode),
+			linkExpired: true,
+		}
+	}
+
 	if resp.StatusCode != http.StatusPartialContent {
-		return []byte{}, fmt.Errorf("during GET unexpected status code was returned: %d", resp.StatusCode)
+		return fmt.Errorf("during GET unexpected status code was returned: %d", resp.StatusCode)
 	}
 
-	var respBytes []byte
-	respBytes, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		if err == io.ErrUnexpectedEOF {
-			goto Retry
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			c.Throttle.Wait(n)
+
+			_, err = writer.Write(buf[:n])
+			if err != nil {
+				return fmt.Errorf("failed to write file: %s", err)
+			}
+
+			progress.bytesTransferred(n)
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+
+		if readErr == io.ErrUnexpectedEOF {
+			return temporaryError{err: readErr}
+		}
+
+		if readErr != nil {
+			return readErr
 		}
+	}
+}
+
+// transferProgress accumulates the totals reported to a ProgressListener
+// across all ranges of a download.
+type transferProgress struct {
+	transferredBytes int64
+	completedRanges  int32
+
+	listener    ProgressListener
+	totalBytes  int64
+	totalRanges int
+}
+
+func (p *transferProgress) bytesTransferred(n int) {
+	transferred := atomic.AddInt64(&p.transferredBytes, int64(n))
+
+	p.listener.BytesTransferred(BytesTransferredEvent{
+		Bytes:            n,
+		TransferredBytes: transferred,
+		TotalBytes:       p.totalBytes,
+	})
+}
+
+func (p *transferProgress) rangeCompleted(byteRange Range) {
+	completed := atomic.AddInt32(&p.completedRanges, 1)
+
+	p.listener.RangeCompleted(RangeCompletedEvent{
+		Range:           byteRange,
+		CompletedRanges: int(completed),
+		TotalRanges:     p.totalRanges,
+	})
+}
+
+func (p *transferProgress) transferred() int64 {
+	return atomic.LoadInt64(&p.transferredBytes)
+}
+
+// temporaryError marks a failure that may succeed if the range is retried.
+type temporaryError struct {
+	err         error
+	linkExpired bool
+}
+
+func (e temporaryError) Error() string {
+	return e.err.Error()
+}
+
+// downloadLink holds the signed URL shared by every range of a download so
+// that an expired link is only refreshed once.
+type downloadLink struct {
+	mu      sync.Mutex
+	fetcher downloadLinkFetcher
+	url     string
+}
 
-		return []byte{}, err
+func (d *downloadLink) current() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.url
+}
+
+func (d *downloadLink) refresh(expiredURL string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.url != expiredURL {
+		return nil
+	}
+
+	url, err := d.fetcher.NewDownloadLink()
+	if err != nil {
+		return err
 	}
 
-	return respBytes, err
+	d.url = url
+
+	return nil
+}
+
+// offsetWriter writes sequentially into an io.WriterAt starting at offset.
+type offsetWriter struct {
+	writerAt io.WriterAt
+	offset   int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.writerAt.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
 }
@@ -1,13 +1,18 @@
 package download_test
 
 import (
+	"context"
 	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 
 	"github.com/pivotal-cf/go-pivnet/download"
 	"github.com/pivotal-cf/go-pivnet/download/fakes"
@@ -22,6 +27,20 @@ func (e EOFReader) Read(p []byte) (int, error) {
 	return 0, io.ErrUnexpectedEOF
 }
 
+type PartialReader struct {
+	content string
+	read    bool
+}
+
+func (p *PartialReader) Read(b []byte) (int, error) {
+	if p.read {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	p.read = true
+	return copy(b, p.content), nil
+}
+
 type NetError struct {
 	error
 }
@@ -38,13 +57,16 @@ var _ = Describe("Downloader", func() {
 	var (
 		httpClient *fakes.HTTPClient
 		ranger     *fakes.Ranger
-		bar        *fakes.Bar
+		listener   *fakes.ProgressListener
+		fetcher    *fakes.DownloadLinkFetcher
 	)
 
 	BeforeEach(func() {
 		httpClient = &fakes.HTTPClient{}
 		ranger = &fakes.Ranger{}
-		bar = &fakes.Bar{}
+		listener = &fakes.ProgressListener{}
+		fetcher = &fakes.DownloadLinkFetcher{}
+		fetcher.NewDownloadLinkReturns("https://example.com/some-file", nil)
 	})
 
 	Describe("Get", func() {
@@ -93,16 +115,14 @@ var _ = Describe("Downloader", func() {
 						},
 					}, nil
 				}
-
-				return nil, nil
 			}
 
-			downloader := download.New(httpClient, ranger, bar)
+			downloader := download.New(httpClient, ranger)
 
 			tmpFile, err := ioutil.TempFile("", "")
 			Expect(err).NotTo(HaveOccurred())
 
-			err = downloader.Get(tmpFile, "https://example.com/some-file", GinkgoWriter)
+			err = downloader.Get(tmpFile, fetcher, listener)
 			Expect(err).NotTo(HaveOccurred())
 
 			content, err := ioutil.ReadAll(tmpFile)
@@ -113,8 +133,11 @@ var _ = Describe("Downloader", func() {
 			Expect(ranger.BuildRangeCallCount()).To(Equal(1))
 			Expect(ranger.BuildRangeArgsForCall(0)).To(Equal(int64(10)))
 
-			Expect(bar.SetTotalArgsForCall(0)).To(Equal(int64(10)))
-			Expect(bar.KickoffCallCount()).To(Equal(1))
+			Expect(listener.StartedCallCount()).To(Equal(1))
+			Expect(listener.StartedArgsForCall(0)).To(Equal(download.StartedEvent{
+				TotalBytes:  10,
+				TotalRanges: 2,
+			}))
 
 			Expect(httpClient.DoCallCount()).To(Equal(3))
 
@@ -137,10 +160,169 @@ var _ = Describe("Downloader", func() {
 			Expect(urls).To(ConsistOf([]string{"https://example.com/some-file", "https://example.com/some-file", "https://example.com/some-file"}))
 			Expect(headers).To(ConsistOf([]string{"bytes=0-9", "bytes=10-19"}))
 
-			Expect(bar.AddArgsForCall(0)).To(Equal(10))
-			Expect(bar.AddArgsForCall(1)).To(Equal(10))
+			Expect(listener.BytesTransferredCallCount()).To(Equal(2))
+			Expect(listener.BytesTransferredArgsForCall(0).Bytes).To(Equal(10))
+			Expect(listener.BytesTransferredArgsForCall(1).Bytes).To(Equal(10))
+
+			Expect(listener.RangeCompletedCallCount()).To(Equal(2))
+			Expect(listener.RangeCompletedArgsForCall(1).CompletedRanges).To(Equal(2))
+
+			Expect(listener.FinishedCallCount()).To(Equal(1))
+			Expect(listener.FinishedArgsForCall(0).TransferredBytes).To(Equal(int64(20)))
+			Expect(listener.FinishedArgsForCall(0).Err).NotTo(HaveOccurred())
+		})
+
+		It("limits the transfer rate when a throttle is set", func() {
+			ranger.BuildRangeReturns([]download.Range{
+				download.NewRange(0, 9),
+				download.NewRange(10, 19),
+			}, nil)
+
+			httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+				if req.Method == "HEAD" {
+					return &http.Response{
+						StatusCode:    http.StatusOK,
+						ContentLength: 20,
+						Request:       req,
+					}, nil
+				}
+
+				return &http.Response{
+					StatusCode: http.StatusPartialContent,
+					Body:       ioutil.NopCloser(strings.NewReader("0123456789")),
+				}, nil
+			}
+
+			downloader := download.New(httpClient, ranger)
+			downloader.Throttle = download.NewThrottle(100)
+
+			tmpFile, err := ioutil.TempFile("", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			start := time.Now()
+
+			err = downloader.Get(tmpFile, fetcher, listener)
+			Expect(err).NotTo(HaveOccurred())
 
-			Expect(bar.FinishCallCount()).To(Equal(1))
+			Expect(time.Since(start)).To(BeNumerically(">=", 150*time.Millisecond))
+		})
+
+		It("fetches no more ranges at once than the configured concurrency", func() {
+			ranger.BuildRangeReturns([]download.Range{
+				download.NewRange(0, 4),
+				download.NewRange(5, 9),
+				download.NewRange(10, 14),
+			}, nil)
+
+			var (
+				m        = &sync.Mutex{}
+				inFlight int
+				maxSeen  int
+			)
+			httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+				if req.Method == "HEAD" {
+					return &http.Response{
+						StatusCode:    http.StatusOK,
+						ContentLength: 15,
+						Request:       req,
+					}, nil
+				}
+
+				m.Lock()
+				inFlight++
+				if inFlight > maxSeen {
+					maxSeen = inFlight
+				}
+				m.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				m.Lock()
+				inFlight--
+				m.Unlock()
+
+				return &http.Response{
+					StatusCode: http.StatusPartialContent,
+					Body:       ioutil.NopCloser(strings.NewReader("abcde")),
+				}, nil
+			}
+
+			downloader := download.New(httpClient, ranger)
+			downloader.Concurrency = 1
+
+			tmpFile, err := ioutil.TempFile("", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			err = downloader.Get(tmpFile, fetcher, listener)
+			Expect(err).NotTo(HaveOccurred())
+
+			content, err := ioutil.ReadAll(tmpFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(content)).To(Equal("abcdeabcdeabcde"))
+			Expect(maxSeen).To(Equal(1))
+		})
+
+		It("shares the connection limit between downloads", func() {
+			ranger.BuildRangeReturns([]download.Range{
+				download.NewRange(0, 4),
+				download.NewRange(5, 9),
+			}, nil)
+
+			var (
+				m        = &sync.Mutex{}
+				inFlight int
+				maxSeen  int
+			)
+			httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+				if req.Method == "HEAD" {
+					return &http.Response{
+						StatusCode:    http.StatusOK,
+						ContentLength: 10,
+						Request:       req,
+					}, nil
+				}
+
+				m.Lock()
+				inFlight++
+				if inFlight > maxSeen {
+					maxSeen = inFlight
+				}
+				m.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				m.Lock()
+				inFlight--
+				m.Unlock()
+
+				return &http.Response{
+					StatusCode: http.StatusPartialContent,
+					Body:       ioutil.NopCloser(strings.NewReader("abcde")),
+				}, nil
+			}
+
+			connectionLimit := download.NewConnectionLimit(1)
+
+			var g errgroup.Group
+			for i := 0; i < 2; i++ {
+				g.Go(func() error {
+					downloader := download.New(httpClient, ranger)
+					downloader.ConnectionLimit = connectionLimit
+
+					tmpFile, err := ioutil.TempFile("", "")
+					if err != nil {
+						return err
+					}
+
+					return downloader.Get(tmpFile, fetcher, nil)
+				})
+			}
+
+			err := g.Wait()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(maxSeen).To(Equal(1))
 		})
 	})
 
@@ -175,12 +357,13 @@ var _ = Describe("Downloader", func() {
 
 				ranger.BuildRangeReturns([]download.Range{{Lower: 0, Upper: 15}}, nil)
 
-				downloader := download.New(httpClient, ranger, bar)
+				downloader := download.New(httpClient, ranger)
+				downloader.RetryBackoff = time.Millisecond
 
 				tmpFile, err := ioutil.TempFile("", "")
 				Expect(err).NotTo(HaveOccurred())
 
-				err = downloader.Get(tmpFile, "https://example.com/some-file", GinkgoWriter)
+				err = downloader.Get(tmpFile, fetcher, listener)
 				Expect(err).NotTo(HaveOccurred())
 
 				stats, err := tmpFile.Stat()
@@ -190,6 +373,48 @@ var _ = Describe("Downloader", func() {
 			})
 		})
 
+		Context("when the connection drops part way through a range", func() {
+			It("resumes the range from the last written byte", func() {
+				var rangeHeaders []string
+				httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+					if req.Method == "HEAD" {
+						return &http.Response{Request: req}, nil
+					}
+
+					rangeHeaders = append(rangeHeaders, req.Header.Get("Range"))
+
+					if len(rangeHeaders) == 1 {
+						return &http.Response{
+							StatusCode: http.StatusPartialContent,
+							Body:       ioutil.NopCloser(&PartialReader{content: "fake "}),
+						}, nil
+					}
+
+					return &http.Response{
+						StatusCode: http.StatusPartialContent,
+						Body:       ioutil.NopCloser(strings.NewReader("product")),
+					}, nil
+				}
+
+				ranger.BuildRangeReturns([]download.Range{download.NewRange(0, 11)}, nil)
+
+				downloader := download.New(httpClient, ranger)
+				downloader.RetryBackoff = time.Millisecond
+
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = downloader.Get(tmpFile, fetcher, listener)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := ioutil.ReadAll(tmpFile)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(string(content)).To(Equal("fake product"))
+				Expect(rangeHeaders).To(Equal([]string{"bytes=0-11", "bytes=5-11"}))
+			})
+		})
+
 		Context("when there is a temporary network error", func() {
 			It("successfully retries the download", func() {
 				responses := []*http.Response{
@@ -219,12 +444,13 @@ var _ = Describe("Downloader", func() {
 
 				ranger.BuildRangeReturns([]download.Range{{Lower: 0, Upper: 15}}, nil)
 
-				downloader := download.New(httpClient, ranger, bar)
+				downloader := download.New(httpClient, ranger)
+				downloader.RetryBackoff = time.Millisecond
 
 				tmpFile, err := ioutil.TempFile("", "")
 				Expect(err).NotTo(HaveOccurred())
 
-				err = downloader.Get(tmpFile, "https://example.com/some-file", GinkgoWriter)
+				err = downloader.Get(tmpFile, fetcher, listener)
 				Expect(err).NotTo(HaveOccurred())
 
 				stats, err := tmpFile.Stat()
@@ -233,25 +459,123 @@ var _ = Describe("Downloader", func() {
 				Expect(stats.Size()).To(BeNumerically(">", 0))
 			})
 		})
+
+		Context("when the retries are exhausted", func() {
+			It("returns an error", func() {
+				httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+					if req.Method == "HEAD" {
+						return &http.Response{Request: req}, nil
+					}
+
+					return &http.Response{
+						StatusCode: http.StatusPartialContent,
+						Body:       ioutil.NopCloser(EOFReader{}),
+					}, nil
+				}
+
+				ranger.BuildRangeReturns([]download.Range{{Lower: 0, Upper: 15}}, nil)
+
+				downloader := download.New(httpClient, ranger)
+				downloader.MaxRetries = 2
+				downloader.RetryBackoff = time.Millisecond
+
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = downloader.Get(tmpFile, fetcher, listener)
+				Expect(err).To(MatchError("failed during retryable request: giving up after 2 retries: unexpected EOF"))
+
+				Expect(listener.RetryingCallCount()).To(Equal(2))
+				Expect(listener.RetryingArgsForCall(1).Attempt).To(Equal(2))
+				Expect(listener.FinishedCallCount()).To(Equal(1))
+				Expect(listener.FinishedArgsForCall(0).Err).To(Equal(err))
+
+				Expect(httpClient.DoCallCount()).To(Equal(4))
+			})
+		})
+
+		Context("when the download link has expired", func() {
+			It("fetches a new download link and continues", func() {
+				links := []string{
+					"https://example.com/some-file?signature=old",
+					"https://example.com/some-file?signature=new",
+				}
+				fetcher.NewDownloadLinkStub = func() (string, error) {
+					return links[fetcher.NewDownloadLinkCallCount()-1], nil
+				}
+
+				var getURLs []string
+				httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+					if req.Method == "HEAD" {
+						return &http.Response{Request: req}, nil
+					}
+
+					getURLs = append(getURLs, req.URL.String())
+
+					if req.URL.Query().Get("signature") == "old" {
+						return &http.Response{
+							StatusCode: http.StatusForbidden,
+							Body:       ioutil.NopCloser(strings.NewReader("")),
+						}, nil
+					}
+
+					return &http.Response{
+						StatusCode: http.StatusPartialContent,
+						Body:       ioutil.NopCloser(strings.NewReader("something")),
+					}, nil
+				}
+
+				ranger.BuildRangeReturns([]download.Range{download.NewRange(0, 8)}, nil)
+
+				downloader := download.New(httpClient, ranger)
+
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = downloader.Get(tmpFile, fetcher, listener)
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := ioutil.ReadAll(tmpFile)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(string(content)).To(Equal("something"))
+				Expect(fetcher.NewDownloadLinkCallCount()).To(Equal(2))
+				Expect(getURLs).To(Equal(links))
+			})
+		})
 	})
 
 	Context("when an error occurs", func() {
 		Context("when the HEAD request cannot be constucted", func() {
 			It("returns an error", func() {
-				downloader := download.New(nil, nil, nil)
+				fetcher.NewDownloadLinkReturns("%%%", nil)
+
+				downloader := download.New(nil, nil)
 
-				err := downloader.Get(nil, "%%%", GinkgoWriter)
+				err := downloader.Get(nil, fetcher, listener)
 				Expect(err).To(MatchError(ContainSubstring("failed to construct HEAD request")))
 			})
 		})
 
+		Context("when fetching the download link fails", func() {
+			It("returns the error", func() {
+				fetcher.NewDownloadLinkReturns("", errors.New("failed fetch"))
+
+				downloader := download.New(httpClient, ranger)
+
+				err := downloader.Get(nil, fetcher, listener)
+				Expect(err).To(MatchError("failed fetch"))
+				Expect(httpClient.DoCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when the HEAD has an error", func() {
 			It("returns an error", func() {
 				httpClient.DoReturns(&http.Response{}, errors.New("failed request"))
 
-				downloader := download.New(httpClient, nil, nil)
+				downloader := download.New(httpClient, nil)
 
-				err := downloader.Get(nil, "https://example.com/some-file", GinkgoWriter)
+				err := downloader.Get(nil, fetcher, listener)
 				Expect(err).To(MatchError("failed to make HEAD request: failed request"))
 			})
 		})
@@ -269,13 +593,35 @@ var _ = Describe("Downloader", func() {
 
 				ranger.BuildRangeReturns([]download.Range{}, errors.New("failed range build"))
 
-				downloader := download.New(httpClient, ranger, nil)
+				downloader := download.New(httpClient, ranger)
 
-				err := downloader.Get(nil, "https://example.com/some-file", GinkgoWriter)
+				err := downloader.Get(nil, fetcher, listener)
 				Expect(err).To(MatchError("failed to construct range: failed range build"))
 			})
 		})
 
+		Context("when the HEAD does not report a content length", func() {
+			It("returns an error without downloading anything", func() {
+				httpClient.DoReturns(&http.Response{
+					StatusCode:    http.StatusOK,
+					ContentLength: -1,
+					Request: &http.Request{
+						URL: &url.URL{
+							Scheme: "https",
+							Host:   "example.com",
+							Path:   "some-file",
+						},
+					},
+				}, nil)
+
+				downloader := download.New(httpClient, download.NewRanger(4))
+
+				err := downloader.Get(nil, fetcher, listener)
+				Expect(err).To(MatchError("failed to construct range: content length is unknown"))
+				Expect(httpClient.DoCallCount()).To(Equal(1))
+			})
+		})
+
 		Context("when the GET fails", func() {
 			It("returns an error", func() {
 				responses := []*http.Response{
@@ -299,9 +645,9 @@ var _ = Describe("Downloader", func() {
 
 				ranger.BuildRangeReturns([]download.Range{{}}, nil)
 
-				downloader := download.New(httpClient, ranger, bar)
+				downloader := download.New(httpClient, ranger)
 
-				err := downloader.Get(nil, "https://example.com/some-file", GinkgoWriter)
+				err := downloader.Get(nil, fetcher, listener)
 				Expect(err).To(MatchError("failed during retryable request: failed GET"))
 			})
 		})
@@ -332,13 +678,59 @@ var _ = Describe("Downloader", func() {
 
 				ranger.BuildRangeReturns([]download.Range{{}}, nil)
 
-				downloader := download.New(httpClient, ranger, bar)
+				downloader := download.New(httpClient, ranger)
 
-				err := downloader.Get(nil, "https://example.com/some-file", GinkgoWriter)
+				err := downloader.Get(nil, fetcher, listener)
 				Expect(err).To(MatchError("failed during retryable request: during GET unexpected status code was returned: 500"))
 			})
 		})
 
+		Context("when the preflight check fails", func() {
+			It("returns the error without fetching any ranges", func() {
+				httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+					return &http.Response{Request: req, ContentLength: 10}, nil
+				}
+
+				downloader := download.New(httpClient, ranger)
+
+				var preflightLength int64
+				downloader.Preflight = func(location *os.File, contentLength int64) error {
+					preflightLength = contentLength
+					return errors.New("no room")
+				}
+
+				err := downloader.Get(nil, fetcher, listener)
+				Expect(err).To(MatchError("no room"))
+
+				Expect(preflightLength).To(Equal(int64(10)))
+				Expect(httpClient.DoCallCount()).To(Equal(1))
+				Expect(ranger.BuildRangeCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when the context is cancelled", func() {
+			It("abandons the download", func() {
+				httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+					return &http.Response{Request: req}, nil
+				}
+
+				ranger.BuildRangeReturns([]download.Range{download.NewRange(0, 9)}, nil)
+
+				downloader := download.New(httpClient, ranger)
+
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				err = downloader.GetWithContext(ctx, tmpFile, fetcher, listener)
+				Expect(err).To(MatchError("failed during retryable request: context canceled"))
+
+				Expect(httpClient.DoCallCount()).To(Equal(1))
+			})
+		})
+
 		Context("when the file cannot be written to", func() {
 			It("returns an error", func() {
 				responses := []*http.Response{
@@ -365,7 +757,7 @@ var _ = Describe("Downloader", func() {
 
 				ranger.BuildRangeReturns([]download.Range{{Lower: 0, Upper: 15}}, nil)
 
-				downloader := download.New(httpClient, ranger, bar)
+				downloader := download.New(httpClient, ranger)
 
 				closedFile, err := ioutil.TempFile("", "")
 				Expect(err).NotTo(HaveOccurred())
@@ -373,7 +765,7 @@ var _ = Describe("Downloader", func() {
 				err = closedFile.Close()
 				Expect(err).NotTo(HaveOccurred())
 
-				err = downloader.Get(closedFile, "https://example.com/some-file", GinkgoWriter)
+				err = downloader.Get(closedFile, fetcher, listener)
 				Expect(err).To(MatchError(ContainSubstring("failed to write file")))
 			})
 		})
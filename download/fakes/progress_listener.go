@@ -0,0 +1,188 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/pivotal-cf/go-pivnet/download"
+)
+
+type ProgressListener struct {
+	StartedStub        func(event download.StartedEvent)
+	startedMutex       sync.RWMutex
+	startedArgsForCall []struct {
+		event download.StartedEvent
+	}
+	BytesTransferredStub        func(event download.BytesTransferredEvent)
+	bytesTransferredMutex       sync.RWMutex
+	bytesTransferredArgsForCall []struct {
+		event download.BytesTransferredEvent
+	}
+	RangeCompletedStub        func(event download.RangeCompletedEvent)
+	rangeCompletedMutex       sync.RWMutex
+	rangeCompletedArgsForCall []struct {
+		event download.RangeCompletedEvent
+	}
+	RetryingStub        func(event download.RetryEvent)
+	retryingMutex       sync.RWMutex
+	retryingArgsForCall []struct {
+		event download.RetryEvent
+	}
+	FinishedStub        func(event download.FinishedEvent)
+	finishedMutex       sync.RWMutex
+	finishedArgsForCall []struct {
+		event download.FinishedEvent
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *ProgressListener) Started(event download.StartedEvent) {
+	fake.startedMutex.Lock()
+	fake.startedArgsForCall = append(fake.startedArgsForCall, struct {
+		event download.StartedEvent
+	}{event})
+	fake.recordInvocation("Started", []interface{}{event})
+	fake.startedMutex.Unlock()
+	if fake.StartedStub != nil {
+		fake.StartedStub(event)
+	}
+}
+
+func (fake *ProgressListener) StartedCallCount() int {
+	fake.startedMutex.RLock()
+	defer fake.startedMutex.RUnlock()
+	return len(fake.startedArgsForCall)
+}
+
+func (fake *ProgressListener) StartedArgsForCall(i int) download.StartedEvent {
+	fake.startedMutex.RLock()
+	defer fake.startedMutex.RUnlock()
+	return fake.startedArgsForCall[i].event
+}
+
+func (fake *ProgressListener) BytesTransferred(event download.BytesTransferredEvent) {
+	fake.bytesTransferredMutex.Lock()
+	fake.bytesTransferredArgsForCall = append(fake.bytesTransferredArgsForCall, struct {
+		event download.BytesTransferredEvent
+	}{event})
+	fake.recordInvocation("BytesTransferred", []interface{}{event})
+	fake.bytesTransferredMutex.Unlock()
+	if fake.BytesTransferredStub != nil {
+		fake.BytesTransferredStub(event)
+	}
+}
+
+func (fake *ProgressListener) BytesTransferredCallCount() int {
+	fake.bytesTransferredMutex.RLock()
+	defer fake.bytesTransferredMutex.RUnlock()
+	return len(fake.bytesTransferredArgsForCall)
+}
+
+func (fake *ProgressListener) BytesTransferredArgsForCall(i int) download.BytesTransferredEvent {
+	fake.bytesTransferredMutex.RLock()
+	defer fake.bytesTransferredMutex.RUnlock()
+	return fake.bytesTransferredArgsForCall[i].event
+}
+
+func (fake *ProgressListener) RangeCompleted(event download.RangeCompletedEvent) {
+	fake.rangeCompletedMutex.Lock()
+	fake.rangeCompletedArgsForCall = append(fake.rangeCompletedArgsForCall, struct {
+		event download.RangeCompletedEvent
+	}{event})
+	fake.recordInvocation("RangeCompleted", []interface{}{event})
+	fake.rangeCompletedMutex.Unlock()
+	if fake.RangeCompletedStub != nil {
+		fake.RangeCompletedStub(event)
+	}
+}
+
+func (fake *ProgressListener) RangeCompletedCallCount() int {
+	fake.rangeCompletedMutex.RLock()
+	defer fake.rangeCompletedMutex.RUnlock()
+	return len(fake.rangeCompletedArgsForCall)
+}
+
+func (fake *ProgressListener) RangeCompletedArgsForCall(i int) download.RangeCompletedEvent {
+	fake.rangeCompletedMutex.RLock()
+	defer fake.rangeCompletedMutex.RUnlock()
+	return fake.rangeCompletedArgsForCall[i].event
+}
+
+func (fake *ProgressListener) Retrying(event download.RetryEvent) {
+	fake.retryingMutex.Lock()
+	fake.retryingArgsForCall = append(fake.retryingArgsForCall, struct {
+		event download.RetryEvent
+	}{event})
+	fake.recordInvocation("Retrying", []interface{}{event})
+	fake.retryingMutex.Unlock()
+	if fake.RetryingStub != nil {
+		fake.RetryingStub(event)
+	}
+}
+
+func (fake *ProgressListener) RetryingCallCount() int {
+	fake.retryingMutex.RLock()
+	defer fake.retryingMutex.RUnlock()
+	return len(fake.retryingArgsForCall)
+}
+
+func (fake *ProgressListener) RetryingArgsForCall(i int) download.RetryEvent {
+	fake.retryingMutex.RLock()
+	defer fake.retryingMutex.RUnlock()
+	return fake.retryingArgsForCall[i].event
+}
+
+func (fake *ProgressListener) Finished(event download.FinishedEvent) {
+	fake.finishedMutex.Lock()
+	fake.finishedArgsForCall = append(fake.finishedArgsForCall, struct {
+		event download.FinishedEvent
+	}{event})
+	fake.recordInvocation("Finished", []interface{}{event})
+	fake.finishedMutex.Unlock()
+	if fake.FinishedStub != nil {
+		fake.FinishedStub(event)
+	}
+}
+
+func (fake *ProgressListener) FinishedCallCount() int {
+	fake.finishedMutex.RLock()
+	defer fake.finishedMutex.RUnlock()
+	return len(fake.finishedArgsForCall)
+}
+
+func (fake *ProgressListener) FinishedArgsForCall(i int) download.FinishedEvent {
+	fake.finishedMutex.RLock()
+	defer fake.finishedMutex.RUnlock()
+	return fake.finishedArgsForCall[i].event
+}
+
+func (fake *ProgressListener) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.startedMutex.RLock()
+	defer fake.startedMutex.RUnlock()
+	fake.bytesTransferredMutex.RLock()
+	defer fake.bytesTransferredMutex.RUnlock()
+	fake.rangeCompletedMutex.RLock()
+	defer fake.rangeCompletedMutex.RUnlock()
+	fake.retryingMutex.RLock()
+	defer fake.retryingMutex.RUnlock()
+	fake.finishedMutex.RLock()
+	defer fake.finishedMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *ProgressListener) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ download.ProgressListener = new(ProgressListener)
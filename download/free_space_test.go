@@ -0,0 +1,30 @@
+package download_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/pivotal-cf/go-pivnet/download"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FreeSpace", func() {
+	It("returns the space available on the filesystem holding the path", func() {
+		dir, err := ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		available, err := download.FreeSpace(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(available).To(BeNumerically(">", 0))
+	})
+
+	Context("when the path does not exist", func() {
+		It("returns an error", func() {
+			_, err := download.FreeSpace("/path/that/does/not/exist")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
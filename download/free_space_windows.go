@@ -0,0 +1,34 @@
+package download
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32            = syscall.NewLazyDLL("kernel32.dll")
+	procGetDiskFreeSpaceEx = modkernel32.NewProc("GetDiskFreeSpaceExW")
+)
+
+// FreeSpace returns the number of bytes available to the current user on
+// the volume holding path.
+func FreeSpace(path string) (int64, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var freeBytesAvailable int64
+
+	r1, _, err := procGetDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&freeBytesAvailable)),
+		0,
+		0,
+	)
+	if r1 == 0 {
+		return 0, err
+	}
+
+	return freeBytesAvailable, nil
+}
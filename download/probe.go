@@ -0,0 +1,71 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+var contentRangeTotal = regexp.MustCompile(`^bytes \d+-\d+/(\d+)$`)
+
+// Probe returns the URL that contentURL finally resolves to after redirects
+// and the length of its content, without fetching the content. The length
+// comes from a HEAD request or, when the server refuses HEAD or does not
+// report a length, from the Content-Range of a single byte GET. It is -1 if
+// neither reveals it.
+func (c Client) Probe(ctx context.Context, contentURL string) (string, int64, error) {
+	req, err := http.NewRequest("HEAD", contentURL, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to construct HEAD request: %s", err)
+	}
+
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to make HEAD request: %s", err)
+	}
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+
+	if resp.StatusCode == http.StatusOK && resp.ContentLength >= 0 {
+		return resp.Request.URL.String(), resp.ContentLength, nil
+	}
+
+	// Signed URLs are often only valid for GET, so fall back to requesting
+	// the first byte and reading the total from the Content-Range.
+	req, err = http.NewRequest("GET", contentURL, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to construct GET request: %s", err)
+	}
+	req.Header = NewRange(0, 0).HTTPHeader
+
+	resp, err = c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to make GET request: %s", err)
+	}
+	if resp.Body != nil {
+		// The body is deliberately left unread
+		resp.Body.Close()
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		matches := contentRangeTotal.FindStringSubmatch(resp.Header.Get("Content-Range"))
+		if matches == nil {
+			return resp.Request.URL.String(), -1, nil
+		}
+
+		contentLength, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to parse Content-Range: %s", err)
+		}
+
+		return resp.Request.URL.String(), contentLength, nil
+	case http.StatusOK:
+		return resp.Request.URL.String(), resp.ContentLength, nil
+	default:
+		return "", 0, fmt.Errorf("during GET unexpected status code was returned: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,120 @@
+package download_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/pivotal-cf/go-pivnet/download"
+	"github.com/pivotal-cf/go-pivnet/download/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Probe", func() {
+	var (
+		httpClient *fakes.HTTPClient
+		downloader download.Client
+
+		redirectedURL *url.URL
+	)
+
+	BeforeEach(func() {
+		httpClient = &fakes.HTTPClient{}
+		downloader = download.New(httpClient, &fakes.Ranger{})
+
+		var err error
+		redirectedURL, err = url.Parse("https://cdn.example.com/some-file")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns the redirected URL and the content length from a HEAD request", func() {
+		httpClient.DoReturns(&http.Response{
+			StatusCode:    http.StatusOK,
+			ContentLength: 1024,
+			Request:       &http.Request{URL: redirectedURL},
+		}, nil)
+
+		location, contentLength, err := downloader.Probe(context.Background(), "https://example.com/some-file")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(location).To(Equal("https://cdn.example.com/some-file"))
+		Expect(contentLength).To(Equal(int64(1024)))
+		Expect(httpClient.DoCallCount()).To(Equal(1))
+	})
+
+	Context("when the HEAD request is refused", func() {
+		BeforeEach(func() {
+			httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+				if req.Method == "HEAD" {
+					return &http.Response{StatusCode: http.StatusForbidden, Request: req}, nil
+				}
+
+				Expect(req.Header.Get("Range")).To(Equal("bytes=0-0"))
+
+				return &http.Response{
+					StatusCode: http.StatusPartialContent,
+					Header:     http.Header{"Content-Range": []string{"bytes 0-0/2048"}},
+					Request:    &http.Request{URL: redirectedURL},
+				}, nil
+			}
+		})
+
+		It("reads the content length from the Content-Range of a single byte", func() {
+			location, contentLength, err := downloader.Probe(context.Background(), "https://example.com/some-file")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(location).To(Equal("https://cdn.example.com/some-file"))
+			Expect(contentLength).To(Equal(int64(2048)))
+		})
+	})
+
+	Context("when the total length is not reported", func() {
+		BeforeEach(func() {
+			httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+				if req.Method == "HEAD" {
+					return &http.Response{StatusCode: http.StatusOK, ContentLength: -1, Request: req}, nil
+				}
+
+				return &http.Response{
+					StatusCode: http.StatusPartialContent,
+					Header:     http.Header{"Content-Range": []string{"bytes 0-0/*"}},
+					Request:    req,
+				}, nil
+			}
+		})
+
+		It("returns a content length of -1", func() {
+			_, contentLength, err := downloader.Probe(context.Background(), "https://example.com/some-file")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(contentLength).To(Equal(int64(-1)))
+		})
+	})
+
+	Context("when both requests are refused", func() {
+		BeforeEach(func() {
+			httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusForbidden, Request: req}, nil
+			}
+		})
+
+		It("returns an error", func() {
+			_, _, err := downloader.Probe(context.Background(), "https://example.com/some-file")
+			Expect(err).To(MatchError("during GET unexpected status code was returned: 403"))
+		})
+	})
+
+	Context("when the HEAD request fails", func() {
+		BeforeEach(func() {
+			httpClient.DoReturns(nil, errors.New("connection refused"))
+		})
+
+		It("returns an error", func() {
+			_, _, err := downloader.Probe(context.Background(), "https://example.com/some-file")
+			Expect(err).To(MatchError("failed to make HEAD request: connection refused"))
+		})
+	})
+})
@@ -1,11 +1,58 @@
 package download
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
+	"sync"
+	"time"
 
 	pb "gopkg.in/cheggaaa/pb.v1"
 )
 
+// ProgressListener receives events describing the progress of a download.
+// Events are delivered from the goroutines fetching each range, so
+// implementations must be safe for concurrent use.
+//
+//go:generate counterfeiter -o ./fakes/progress_listener.go --fake-name ProgressListener . ProgressListener
+type ProgressListener interface {
+	Started(event StartedEvent)
+	BytesTransferred(event BytesTransferredEvent)
+	RangeCompleted(event RangeCompletedEvent)
+	Retrying(event RetryEvent)
+	Finished(event FinishedEvent)
+}
+
+type StartedEvent struct {
+	TotalBytes  int64
+	TotalRanges int
+}
+
+type BytesTransferredEvent struct {
+	Bytes            int
+	TransferredBytes int64
+	TotalBytes       int64
+}
+
+type RangeCompletedEvent struct {
+	Range           Range
+	CompletedRanges int
+	TotalRanges     int
+}
+
+type RetryEvent struct {
+	Range   Range
+	Attempt int
+	Err     error
+}
+
+type FinishedEvent struct {
+	TransferredBytes int64
+	TotalBytes       int64
+	Duration         time.Duration
+	Err              error
+}
+
 type Bar struct {
 	*pb.ProgressBar
 }
@@ -16,6 +63,14 @@ func NewBar() Bar {
 	return Bar{b}
 }
 
+// NewBarListener returns a ProgressListener that renders a progress bar to
+// output.
+func NewBarListener(output io.Writer) Bar {
+	b := NewBar()
+	b.SetOutput(output)
+	return b
+}
+
 func (b Bar) SetTotal(contentLength int64) {
 	b.Total = contentLength
 }
@@ -27,3 +82,245 @@ func (b Bar) Kickoff() {
 func (b Bar) SetOutput(output io.Writer) {
 	b.Output = output
 }
+
+func (b Bar) Started(event StartedEvent) {
+	b.SetTotal(event.TotalBytes)
+	b.Kickoff()
+}
+
+func (b Bar) BytesTransferred(event BytesTransferredEvent) {
+	b.Add(event.Bytes)
+}
+
+func (b Bar) RangeCompleted(event RangeCompletedEvent) {}
+
+func (b Bar) Retrying(event RetryEvent) {}
+
+func (b Bar) Finished(event FinishedEvent) {
+	b.Finish()
+}
+
+// SilentListener discards all progress events.
+type SilentListener struct{}
+
+func (SilentListener) Started(event StartedEvent)                   {}
+func (SilentListener) BytesTransferred(event BytesTransferredEvent) {}
+func (SilentListener) RangeCompleted(event RangeCompletedEvent)     {}
+func (SilentListener) Retrying(event RetryEvent)                    {}
+func (SilentListener) Finished(event FinishedEvent)                 {}
+
+// LogListener writes plain-text progress lines suitable for non-interactive
+// output. Transfer progress is written at most once per interval.
+type LogListener struct {
+	output   io.Writer
+	interval time.Duration
+
+	mu         sync.Mutex
+	lastReport time.Time
+}
+
+func NewLogListener(output io.Writer, interval time.Duration) *LogListener {
+	return &LogListener{
+		output:   output,
+		interval: interval,
+	}
+}
+
+func (l *LogListener) Started(event StartedEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lastReport = time.Now()
+
+	fmt.Fprintf(l.output, "Download started: %d bytes in %d ranges\n", event.TotalBytes, event.TotalRanges)
+}
+
+func (l *LogListener) BytesTransferred(event BytesTransferredEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if time.Since(l.lastReport) < l.interval {
+		return
+	}
+	l.lastReport = time.Now()
+
+	fmt.Fprintf(
+		l.output,
+		"Downloaded %d of %d bytes (%d%%)\n",
+		event.TransferredBytes,
+		event.TotalBytes,
+		percent(event.TransferredBytes, event.TotalBytes),
+	)
+}
+
+func (l *LogListener) RangeCompleted(event RangeCompletedEvent) {}
+
+func (l *LogListener) Retrying(event RetryEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fmt.Fprintf(
+		l.output,
+		"Retrying bytes %d-%d (attempt %d): %s\n",
+		event.Range.Lower,
+		event.Range.Upper,
+		event.Attempt,
+		event.Err,
+	)
+}
+
+func (l *LogListener) Finished(event FinishedEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if event.Err != nil {
+		fmt.Fprintf(
+			l.output,
+			"Download failed after %d of %d bytes in %s: %s\n",
+			event.TransferredBytes,
+			event.TotalBytes,
+			event.Duration,
+			event.Err,
+		)
+		return
+	}
+
+	fmt.Fprintf(l.output, "Download finished: %d bytes in %s\n", event.TransferredBytes, event.Duration)
+}
+
+// JSONListener writes each progress event as a single line of JSON.
+// Bytes transferred events are written at most once per interval.
+type JSONListener struct {
+	encoder  *json.Encoder
+	interval time.Duration
+
+	mu         sync.Mutex
+	lastReport time.Time
+}
+
+// jsonEvent holds the fields of every event type. Numeric fields are
+// pointers so that those an event type carries are written even when zero,
+// and those it does not are left out.
+type jsonEvent struct {
+	Event            string   `json:"event"`
+	Time             string   `json:"time"`
+	TotalBytes       *int64   `json:"total_bytes,omitempty"`
+	TransferredBytes *int64   `json:"transferred_bytes,omitempty"`
+	TotalRanges      *int     `json:"total_ranges,omitempty"`
+	CompletedRanges  *int     `json:"completed_ranges,omitempty"`
+	RangeLower       *int64   `json:"range_lower,omitempty"`
+	RangeUpper       *int64   `json:"range_upper,omitempty"`
+	Attempt          *int     `json:"attempt,omitempty"`
+	DurationSeconds  *float64 `json:"duration_seconds,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+func NewJSONListener(output io.Writer, interval time.Duration) *JSONListener {
+	return &JSONListener{
+		encoder:  json.NewEncoder(output),
+		interval: interval,
+	}
+}
+
+func (j *JSONListener) Started(event StartedEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.lastReport = time.Now()
+
+	j.write(jsonEvent{
+		Event:       "started",
+		TotalBytes:  int64Ptr(event.TotalBytes),
+		TotalRanges: intPtr(event.TotalRanges),
+	})
+}
+
+func (j *JSONListener) BytesTransferred(event BytesTransferredEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if time.Since(j.lastReport) < j.interval {
+		return
+	}
+	j.lastReport = time.Now()
+
+	j.write(jsonEvent{
+		Event:            "bytes_transferred",
+		TotalBytes:       int64Ptr(event.TotalBytes),
+		TransferredBytes: int64Ptr(event.TransferredBytes),
+	})
+}
+
+func (j *JSONListener) RangeCompleted(event RangeCompletedEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.write(jsonEvent{
+		Event:           "range_completed",
+		RangeLower:      int64Ptr(event.Range.Lower),
+		RangeUpper:      int64Ptr(event.Range.Upper),
+		CompletedRanges: intPtr(event.CompletedRanges),
+		TotalRanges:     intPtr(event.TotalRanges),
+	})
+}
+
+func (j *JSONListener) Retrying(event RetryEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.write(jsonEvent{
+		Event:      "retry",
+		RangeLower: int64Ptr(event.Range.Lower),
+		RangeUpper: int64Ptr(event.Range.Upper),
+		Attempt:    intPtr(event.Attempt),
+		Error:      errorString(event.Err),
+	})
+}
+
+func (j *JSONListener) Finished(event FinishedEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.write(jsonEvent{
+		Event:            "finished",
+		TotalBytes:       int64Ptr(event.TotalBytes),
+		TransferredBytes: int64Ptr(event.TransferredBytes),
+		DurationSeconds:  float64Ptr(event.Duration.Seconds()),
+		Error:            errorString(event.Err),
+	})
+}
+
+func (j *JSONListener) write(event jsonEvent) {
+	event.Time = time.Now().UTC().Format(time.RFC3339)
+
+	// Progress reporting is best effort and must not fail the download.
+	_ = j.encoder.Encode(event)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+func percent(part int64, total int64) int64 {
+	if total <= 0 {
+		return 0
+	}
+
+	return part * 100 / total
+}
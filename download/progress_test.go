@@ -1,7 +1,11 @@
 package download_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"strings"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -13,33 +17,152 @@ import (
 )
 
 var _ = Describe("Progress", func() {
-	var (
-		b download.Bar
-	)
+	Describe("Bar", func() {
+		var (
+			b download.Bar
+		)
 
-	BeforeEach(func() {
-		b = download.NewBar()
-		b.SetOutput(GinkgoWriter)
+		BeforeEach(func() {
+			b = download.NewBar()
+			b.SetOutput(GinkgoWriter)
+		})
+
+		It("handles concurrent writes without racing", func() {
+			total := 10
+
+			b.SetTotal(int64(total))
+			b.Output = ioutil.Discard
+			b.Kickoff()
+
+			var g errgroup.Group
+			for i := 0; i < total; i++ {
+				g.Go(func() error {
+					time.Sleep(10 * time.Millisecond)
+
+					_, err := b.Write([]byte("a"))
+					return err
+				})
+			}
+
+			err := g.Wait()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("tracks transferred bytes as a progress listener", func() {
+			b.Output = ioutil.Discard
+
+			b.Started(download.StartedEvent{TotalBytes: 10, TotalRanges: 2})
+			b.BytesTransferred(download.BytesTransferredEvent{Bytes: 4})
+			b.BytesTransferred(download.BytesTransferredEvent{Bytes: 6})
+			b.Finished(download.FinishedEvent{TransferredBytes: 10, TotalBytes: 10})
+
+			Expect(b.Total).To(Equal(int64(10)))
+			Expect(b.Get()).To(Equal(int64(10)))
+		})
 	})
 
-	It("handles concurrent writes without racing", func() {
-		total := 10
+	Describe("LogListener", func() {
+		var (
+			output *bytes.Buffer
+			l      *download.LogListener
+		)
+
+		BeforeEach(func() {
+			output = &bytes.Buffer{}
+			l = download.NewLogListener(output, time.Hour)
+		})
+
+		It("writes a plain-text line for each significant event", func() {
+			l.Started(download.StartedEvent{TotalBytes: 100, TotalRanges: 2})
+			l.BytesTransferred(download.BytesTransferredEvent{Bytes: 50, TransferredBytes: 50, TotalBytes: 100})
+			l.Retrying(download.RetryEvent{Range: download.NewRange(0, 49), Attempt: 1, Err: errors.New("some error")})
+			l.Finished(download.FinishedEvent{TransferredBytes: 100, TotalBytes: 100, Duration: time.Second})
+
+			Expect(strings.Split(strings.TrimSpace(output.String()), "\n")).To(Equal([]string{
+				"Download started: 100 bytes in 2 ranges",
+				"Retrying bytes 0-49 (attempt 1): some error",
+				"Download finished: 100 bytes in 1s",
+			}))
+		})
+
+		It("writes transfer progress once the interval has elapsed", func() {
+			l = download.NewLogListener(output, 0)
 
-		b.SetTotal(int64(total))
-		b.Output = ioutil.Discard
-		b.Kickoff()
+			l.BytesTransferred(download.BytesTransferredEvent{Bytes: 50, TransferredBytes: 50, TotalBytes: 100})
 
-		var g errgroup.Group
-		for i := 0; i < total; i++ {
-			g.Go(func() error {
-				time.Sleep(10 * time.Millisecond)
+			Expect(output.String()).To(Equal("Downloaded 50 of 100 bytes (50%)\n"))
+		})
 
-				_, err := b.Write([]byte("a"))
-				return err
+		It("reports a failed download", func() {
+			l.Finished(download.FinishedEvent{
+				TransferredBytes: 50,
+				TotalBytes:       100,
+				Duration:         time.Second,
+				Err:              errors.New("some error"),
 			})
-		}
 
-		err := g.Wait()
-		Expect(err).NotTo(HaveOccurred())
+			Expect(output.String()).To(Equal("Download failed after 50 of 100 bytes in 1s: some error\n"))
+		})
+	})
+
+	Describe("JSONListener", func() {
+		var (
+			output *bytes.Buffer
+			j      *download.JSONListener
+		)
+
+		BeforeEach(func() {
+			output = &bytes.Buffer{}
+			j = download.NewJSONListener(output, 0)
+		})
+
+		It("writes one JSON object per line for each event", func() {
+			j.Started(download.StartedEvent{TotalBytes: 100, TotalRanges: 2})
+			j.BytesTransferred(download.BytesTransferredEvent{Bytes: 50, TransferredBytes: 50, TotalBytes: 100})
+			j.RangeCompleted(download.RangeCompletedEvent{Range: download.NewRange(0, 49), CompletedRanges: 1, TotalRanges: 2})
+			j.Retrying(download.RetryEvent{Range: download.NewRange(50, 99), Attempt: 1, Err: errors.New("some error")})
+			j.Finished(download.FinishedEvent{TransferredBytes: 100, TotalBytes: 100, Duration: time.Second})
+
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			Expect(lines).To(HaveLen(5))
+
+			var events []map[string]interface{}
+			for _, line := range lines {
+				var event map[string]interface{}
+				err := json.Unmarshal([]byte(line), &event)
+				Expect(err).NotTo(HaveOccurred())
+
+				events = append(events, event)
+			}
+
+			Expect(events[0]["event"]).To(Equal("started"))
+			Expect(events[0]["total_bytes"]).To(BeEquivalentTo(100))
+			Expect(events[1]["event"]).To(Equal("bytes_transferred"))
+			Expect(events[1]["transferred_bytes"]).To(BeEquivalentTo(50))
+			Expect(events[2]["event"]).To(Equal("range_completed"))
+			Expect(events[2]["completed_ranges"]).To(BeEquivalentTo(1))
+			Expect(events[3]["event"]).To(Equal("retry"))
+			Expect(events[3]["error"]).To(Equal("some error"))
+			Expect(events[4]["event"]).To(Equal("finished"))
+			Expect(events[4]["duration_seconds"]).To(BeEquivalentTo(1))
+		})
+
+		It("writes zero values of the fields an event carries, and only those", func() {
+			j.RangeCompleted(download.RangeCompletedEvent{Range: download.NewRange(0, 49), CompletedRanges: 1, TotalRanges: 2})
+			j.Finished(download.FinishedEvent{TotalBytes: 100, Err: errors.New("some error")})
+
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			Expect(lines).To(HaveLen(2))
+
+			var rangeCompleted map[string]interface{}
+			Expect(json.Unmarshal([]byte(lines[0]), &rangeCompleted)).To(Succeed())
+			Expect(rangeCompleted).To(HaveKeyWithValue("range_lower", BeEquivalentTo(0)))
+			Expect(rangeCompleted).NotTo(HaveKey("transferred_bytes"))
+
+			var finished map[string]interface{}
+			Expect(json.Unmarshal([]byte(lines[1]), &finished)).To(Succeed())
+			Expect(finished).To(HaveKeyWithValue("transferred_bytes", BeEquivalentTo(0)))
+			Expect(finished).NotTo(HaveKey("range_lower"))
+		})
 	})
 })
@@ -0,0 +1,156 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// rangeReaderBlockSize is the smallest number of bytes fetched by a
+	// single request, so that the many small reads made when parsing a file
+	// format do not each cost a round trip.
+	rangeReaderBlockSize = 64 * 1024
+
+	// rangeReaderMaxBlocks bounds the memory used to keep recently read
+	// blocks.
+	rangeReaderMaxBlocks = 64
+)
+
+// RangeReaderAt reads a remote file with HTTP range requests, fetching only
+// the parts of the file that are read. Ranges are retried and expired links
+// refreshed as they are for downloads.
+type RangeReaderAt struct {
+	ctx    context.Context
+	client Client
+	link   *downloadLink
+	size   int64
+
+	mutex  sync.Mutex
+	blocks map[int64][]byte
+	order  []int64
+}
+
+// NewRangeReaderAt fetches a download link and finds the size of the file it
+// refers to with Probe, returning a reader for the file. Errors from the
+// fetcher are returned unwrapped.
+func (c Client) NewRangeReaderAt(
+	ctx context.Context,
+	downloadLinkFetcher downloadLinkFetcher,
+) (*RangeReaderAt, error) {
+	contentURL, err := downloadLinkFetcher.NewDownloadLink()
+	if err != nil {
+		return nil, err
+	}
+
+	finalURL, size, err := c.Probe(ctx, contentURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if size < 0 {
+		return nil, fmt.Errorf("content length is unknown")
+	}
+
+	return &RangeReaderAt{
+		ctx:    ctx,
+		client: c,
+		link: &downloadLink{
+			fetcher: downloadLinkFetcher,
+			url:     finalURL,
+		},
+		size:   size,
+		blocks: make(map[int64][]byte),
+	}, nil
+}
+
+// Size returns the size of the remote file in bytes.
+func (r *RangeReaderAt) Size() int64 {
+	return r.size
+}
+
+func (r *RangeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset: %d", off)
+	}
+
+	n := 0
+	for n < len(p) && off+int64(n) < r.size {
+		position := off + int64(n)
+
+		block, err := r.block(position / rangeReaderBlockSize)
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], block[position%rangeReaderBlockSize:])
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (r *RangeReaderAt) block(index int64) ([]byte, error) {
+	r.mutex.Lock()
+	block, ok := r.blocks[index]
+	r.mutex.Unlock()
+
+	if ok {
+		return block, nil
+	}
+
+	lower := index * rangeReaderBlockSize
+	upper := lower + rangeReaderBlockSize
+	if upper > r.size {
+		upper = r.size
+	}
+
+	block = make([]byte, upper-lower)
+	progress := &transferProgress{listener: SilentListener{}}
+
+	err := r.client.retryableRequest(
+		r.ctx,
+		r.link,
+		NewRange(lower, upper-1),
+		bufferWriterAt{buf: block, offset: lower},
+		progress,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bytes %d-%d: %s", lower, upper-1, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.blocks[index]; !ok {
+		r.blocks[index] = block
+		r.order = append(r.order, index)
+
+		if len(r.order) > rangeReaderMaxBlocks {
+			delete(r.blocks, r.order[0])
+			r.order = r.order[1:]
+		}
+	}
+
+	return block, nil
+}
+
+// bufferWriterAt writes into a buffer holding the bytes of a file starting
+// at offset.
+type bufferWriterAt struct {
+	buf    []byte
+	offset int64
+}
+
+func (w bufferWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	start := off - w.offset
+	if start < 0 || start+int64(len(p)) > int64(len(w.buf)) {
+		return 0, fmt.Errorf("write of %d bytes at offset %d is outside the range", len(p), off)
+	}
+
+	return copy(w.buf[start:], p), nil
+}
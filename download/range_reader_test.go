@@ -0,0 +1,226 @@
+package download_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/pivotal-cf/go-pivnet/download"
+	"github.com/pivotal-cf/go-pivnet/download/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RangeReaderAt", func() {
+	var (
+		httpClient *fakes.HTTPClient
+		fetcher    *fakes.DownloadLinkFetcher
+		downloader download.Client
+
+		content       []byte
+		rangeRequests []string
+		mutex         *sync.Mutex
+		expiredURL    string
+	)
+
+	BeforeEach(func() {
+		httpClient = &fakes.HTTPClient{}
+		fetcher = &fakes.DownloadLinkFetcher{}
+		fetcher.NewDownloadLinkReturns("https://example.com/some-file", nil)
+
+		downloader = download.New(httpClient, &fakes.Ranger{})
+
+		content = make([]byte, 200*1024)
+		for i := range content {
+			content[i] = byte(i % 251)
+		}
+
+		rangeRequests = nil
+		mutex = &sync.Mutex{}
+		expiredURL = ""
+
+		httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+			if req.Method == "HEAD" {
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					ContentLength: int64(len(content)),
+					Request:       req,
+				}, nil
+			}
+
+			if req.URL.String() == expiredURL {
+				return &http.Response{
+					StatusCode: http.StatusForbidden,
+					Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+				}, nil
+			}
+
+			header := req.Header.Get("Range")
+
+			mutex.Lock()
+			rangeRequests = append(rangeRequests, header)
+			mutex.Unlock()
+
+			matches := regexp.MustCompile(`bytes=(\d+)-(\d+)`).FindStringSubmatch(header)
+			lower, _ := strconv.Atoi(matches[1])
+			upper, _ := strconv.Atoi(matches[2])
+
+			return &http.Response{
+				StatusCode: http.StatusPartialContent,
+				Body:       ioutil.NopCloser(bytes.NewReader(content[lower : upper+1])),
+			}, nil
+		}
+	})
+
+	It("reads the requested bytes across blocks", func() {
+		reader, err := downloader.NewRangeReaderAt(context.Background(), fetcher)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(reader.Size()).To(Equal(int64(len(content))))
+
+		buf := make([]byte, 1000)
+		n, err := reader.ReadAt(buf, 64*1024-500)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(1000))
+		Expect(buf).To(Equal(content[64*1024-500 : 64*1024+500]))
+
+		Expect(rangeRequests).To(Equal([]string{
+			"bytes=0-65535",
+			"bytes=65536-131071",
+		}))
+	})
+
+	It("reuses blocks that have already been fetched", func() {
+		reader, err := downloader.NewRangeReaderAt(context.Background(), fetcher)
+		Expect(err).NotTo(HaveOccurred())
+
+		buf := make([]byte, 10)
+		for i := 0; i < 3; i++ {
+			_, err = reader.ReadAt(buf, int64(i*10))
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(rangeRequests).To(HaveLen(1))
+	})
+
+	It("returns io.EOF when reading past the end of the file", func() {
+		reader, err := downloader.NewRangeReaderAt(context.Background(), fetcher)
+		Expect(err).NotTo(HaveOccurred())
+
+		buf := make([]byte, 100)
+		n, err := reader.ReadAt(buf, int64(len(content)-10))
+		Expect(err).To(Equal(io.EOF))
+		Expect(n).To(Equal(10))
+		Expect(buf[:n]).To(Equal(content[len(content)-10:]))
+
+		Expect(rangeRequests).To(Equal([]string{
+			fmt.Sprintf("bytes=%d-%d", 3*64*1024, len(content)-1),
+		}))
+	})
+
+	Context("when the download link expires", func() {
+		It("fetches a fresh link and retries", func() {
+			reader, err := downloader.NewRangeReaderAt(context.Background(), fetcher)
+			Expect(err).NotTo(HaveOccurred())
+
+			expiredURL = "https://example.com/some-file"
+			fetcher.NewDownloadLinkReturns("https://example.com/some-file?fresh", nil)
+
+			buf := make([]byte, 10)
+			_, err = reader.ReadAt(buf, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf).To(Equal(content[:10]))
+
+			Expect(fetcher.NewDownloadLinkCallCount()).To(Equal(2))
+		})
+	})
+
+	Context("when the server only accepts GET", func() {
+		BeforeEach(func() {
+			stub := httpClient.DoStub
+			httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+				if req.Method == "HEAD" {
+					return &http.Response{
+						StatusCode: http.StatusForbidden,
+						Request:    req,
+					}, nil
+				}
+
+				if req.Header.Get("Range") == "bytes=0-0" {
+					return &http.Response{
+						StatusCode: http.StatusPartialContent,
+						Header:     http.Header{"Content-Range": []string{fmt.Sprintf("bytes 0-0/%d", len(content))}},
+						Body:       ioutil.NopCloser(bytes.NewReader(content[:1])),
+						Request:    req,
+					}, nil
+				}
+
+				return stub(req)
+			}
+		})
+
+		It("takes the size from a ranged GET", func() {
+			reader, err := downloader.NewRangeReaderAt(context.Background(), fetcher)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(reader.Size()).To(Equal(int64(len(content))))
+
+			buf := make([]byte, 10)
+			_, err = reader.ReadAt(buf, 100)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(buf).To(Equal(content[100:110]))
+		})
+	})
+
+	Context("when the size cannot be found", func() {
+		BeforeEach(func() {
+			httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					ContentLength: -1,
+					Body:          ioutil.NopCloser(bytes.NewReader(nil)),
+					Request:       req,
+				}, nil
+			}
+		})
+
+		It("returns an error", func() {
+			_, err := downloader.NewRangeReaderAt(context.Background(), fetcher)
+			Expect(err).To(MatchError("content length is unknown"))
+		})
+	})
+
+	Context("when the file cannot be found", func() {
+		BeforeEach(func() {
+			httpClient.DoStub = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusNotFound,
+					Request:    req,
+				}, nil
+			}
+		})
+
+		It("returns an error", func() {
+			_, err := downloader.NewRangeReaderAt(context.Background(), fetcher)
+			Expect(err).To(MatchError("during GET unexpected status code was returned: 404"))
+		})
+	})
+
+	Context("when fetching the download link fails", func() {
+		BeforeEach(func() {
+			fetcher.NewDownloadLinkReturns("", fmt.Errorf("some fetch error"))
+		})
+
+		It("returns the error", func() {
+			_, err := downloader.NewRangeReaderAt(context.Background(), fetcher)
+			Expect(err).To(MatchError("some fetch error"))
+		})
+	})
+})
@@ -13,12 +13,23 @@ type Range struct {
 }
 
 type Ranger struct {
-	numHunks int
+	numHunks     int
+	minChunkSize int64
+	maxChunkSize int64
 }
 
 func NewRanger(hunks int) Ranger {
+	return NewChunkedRanger(hunks, 0, 0)
+}
+
+// NewChunkedRanger returns a Ranger that splits content into the given
+// number of hunks, clamping each hunk to the provided chunk size bounds.
+// A zero bound is treated as unbounded.
+func NewChunkedRanger(hunks int, minChunkSize int64, maxChunkSize int64) Ranger {
 	return Ranger{
-		numHunks: hunks,
+		numHunks:     hunks,
+		minChunkSize: minChunkSize,
+		maxChunkSize: maxChunkSize,
 	}
 }
 
@@ -29,27 +40,61 @@ func (r Ranger) BuildRange(contentLength int64) ([]Range, error) {
 		return ranges, errors.New("content length cannot be zero")
 	}
 
+	// A negative length, e.g. from a response without a Content-Length,
+	// would otherwise produce no ranges and an empty download
+	if contentLength < 0 {
+		return ranges, errors.New("content length is unknown")
+	}
+
+	if r.numHunks < 1 {
+		return ranges, errors.New("number of hunks must be greater than zero")
+	}
+
+	if r.minChunkSize > 0 && r.maxChunkSize > 0 && r.minChunkSize > r.maxChunkSize {
+		return ranges, errors.New("minimum chunk size cannot be greater than maximum chunk size")
+	}
+
 	hunkSize := contentLength / int64(r.numHunks)
 	if hunkSize == 0 {
 		hunkSize = 2
 	}
 
-	iterations := (contentLength / hunkSize)
-	remainder := contentLength % int64(hunkSize)
+	if r.minChunkSize > 0 && hunkSize < r.minChunkSize {
+		hunkSize = r.minChunkSize
+	}
+
+	if r.maxChunkSize > 0 && hunkSize > r.maxChunkSize {
+		hunkSize = r.maxChunkSize
+	}
 
-	for i := int64(0); i < int64(iterations); i++ {
-		lowerByte := i * hunkSize
-		upperByte := ((i + 1) * hunkSize) - 1
-		if i == int64(iterations-1) {
-			upperByte += remainder
+	for lowerByte := int64(0); lowerByte < contentLength; {
+		upperByte := lowerByte + hunkSize - 1
+		if upperByte >= contentLength {
+			upperByte = contentLength - 1
 		}
-		formattedBytes := fmt.Sprintf("bytes=%d-%d", lowerByte, upperByte)
-		ranges = append(ranges, Range{
-			Lower:      lowerByte,
-			Upper:      upperByte,
-			HTTPHeader: http.Header{"Range": []string{formattedBytes}},
-		})
+
+		// Fold a short trailing remainder into the final range unless
+		// doing so would exceed the maximum chunk size.
+		remainder := contentLength - (upperByte + 1)
+		if remainder > 0 && remainder < hunkSize {
+			if r.maxChunkSize == 0 || contentLength-lowerByte <= r.maxChunkSize {
+				upperByte = contentLength - 1
+			}
+		}
+
+		ranges = append(ranges, NewRange(lowerByte, upperByte))
+
+		lowerByte = upperByte + 1
 	}
 
 	return ranges, nil
 }
+
+// NewRange returns a Range covering the inclusive bytes lower to upper.
+func NewRange(lower int64, upper int64) Range {
+	return Range{
+		Lower:      lower,
+		Upper:      upper,
+		HTTPHeader: http.Header{"Range": []string{fmt.Sprintf("bytes=%d-%d", lower, upper)}},
+	}
+}
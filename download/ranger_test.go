@@ -95,6 +95,46 @@ var _ = Describe("BuildRange", func() {
 		})
 	})
 
+	Context("when a minimum chunk size is provided", func() {
+		var cr download.Ranger
+
+		BeforeEach(func() {
+			cr = download.NewChunkedRanger(10, 40, 0)
+		})
+
+		It("returns fewer, larger byte ranges", func() {
+			contentLength := int64(100)
+			r, err := cr.BuildRange(contentLength)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r).To(Equal([]download.Range{
+				{Lower: 0, Upper: 39, HTTPHeader: http.Header{"Range": []string{"bytes=0-39"}}},
+				{Lower: 40, Upper: 99, HTTPHeader: http.Header{"Range": []string{"bytes=40-99"}}},
+			}))
+		})
+	})
+
+	Context("when a maximum chunk size is provided", func() {
+		var cr download.Ranger
+
+		BeforeEach(func() {
+			cr = download.NewChunkedRanger(2, 0, 30)
+		})
+
+		It("returns byte ranges no larger than the maximum", func() {
+			contentLength := int64(100)
+			r, err := cr.BuildRange(contentLength)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r).To(Equal([]download.Range{
+				{Lower: 0, Upper: 29, HTTPHeader: http.Header{"Range": []string{"bytes=0-29"}}},
+				{Lower: 30, Upper: 59, HTTPHeader: http.Header{"Range": []string{"bytes=30-59"}}},
+				{Lower: 60, Upper: 89, HTTPHeader: http.Header{"Range": []string{"bytes=60-89"}}},
+				{Lower: 90, Upper: 99, HTTPHeader: http.Header{"Range": []string{"bytes=90-99"}}},
+			}))
+		})
+	})
+
 	Context("when an error occurs", func() {
 		Context("when the content length is zero", func() {
 			var cr download.Ranger
@@ -109,5 +149,26 @@ var _ = Describe("BuildRange", func() {
 				Expect(err).To(MatchError("content length cannot be zero"))
 			})
 		})
+
+		Context("when the content length is unknown", func() {
+			It("returns an error", func() {
+				_, err := download.NewRanger(4).BuildRange(-1)
+				Expect(err).To(MatchError("content length is unknown"))
+			})
+		})
+
+		Context("when the number of hunks is zero", func() {
+			It("returns an error", func() {
+				_, err := download.NewRanger(0).BuildRange(100)
+				Expect(err).To(MatchError("number of hunks must be greater than zero"))
+			})
+		})
+
+		Context("when the minimum chunk size exceeds the maximum", func() {
+			It("returns an error", func() {
+				_, err := download.NewChunkedRanger(10, 50, 20).BuildRange(100)
+				Expect(err).To(MatchError("minimum chunk size cannot be greater than maximum chunk size"))
+			})
+		})
 	})
 })
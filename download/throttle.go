@@ -0,0 +1,90 @@
+package download
+
+import (
+	"sync"
+	"time"
+)
+
+// Throttle caps the rate at which bytes are transferred. A single Throttle
+// may be shared between any number of goroutines and downloads, in which case
+// the limit applies to their combined throughput.
+type Throttle struct {
+	mu        sync.Mutex
+	limit     int64
+	available float64
+	last      time.Time
+}
+
+// NewThrottle returns a Throttle limited to bytesPerSecond. A limit less than
+// one disables throttling.
+func NewThrottle(bytesPerSecond int64) *Throttle {
+	t := &Throttle{}
+	t.SetLimit(bytesPerSecond)
+	return t
+}
+
+// SetLimit changes the limit in bytes per second. It is safe to call while
+// transfers are in progress. A limit less than one disables throttling.
+func (t *Throttle) SetLimit(bytesPerSecond int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if bytesPerSecond < 0 {
+		bytesPerSecond = 0
+	}
+
+	t.limit = bytesPerSecond
+	t.available = 0
+	t.last = time.Now()
+}
+
+// Limit returns the current limit in bytes per second, or zero when
+// throttling is disabled.
+func (t *Throttle) Limit() int64 {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.limit
+}
+
+// Wait blocks until n more bytes may be transferred without exceeding the
+// limit. A nil Throttle never blocks.
+func (t *Throttle) Wait(n int) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+
+	if t.limit < 1 {
+		t.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	limit := float64(t.limit)
+
+	// Allow at most one second's worth of unused allowance to accumulate.
+	t.available += now.Sub(t.last).Seconds() * limit
+	if t.available > limit {
+		t.available = limit
+	}
+	t.last = now
+
+	t.available -= float64(n)
+
+	var delay time.Duration
+	if t.available < 0 {
+		delay = time.Duration(-t.available / limit * float64(time.Second))
+	}
+
+	t.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
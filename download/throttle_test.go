@@ -0,0 +1,61 @@
+package download_test
+
+import (
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pivotal-cf/go-pivnet/download"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Throttle", func() {
+	It("limits combined throughput across goroutines", func() {
+		t := download.NewThrottle(10000)
+
+		start := time.Now()
+
+		var g errgroup.Group
+		for i := 0; i < 4; i++ {
+			g.Go(func() error {
+				t.Wait(1000)
+				return nil
+			})
+		}
+
+		err := g.Wait()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(time.Since(start)).To(BeNumerically(">=", 350*time.Millisecond))
+	})
+
+	It("does not block when the limit is disabled", func() {
+		t := download.NewThrottle(0)
+
+		start := time.Now()
+		t.Wait(1000000)
+
+		Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond))
+		Expect(t.Limit()).To(Equal(int64(0)))
+	})
+
+	It("allows the limit to be changed", func() {
+		t := download.NewThrottle(1)
+		t.SetLimit(1000000)
+
+		start := time.Now()
+		t.Wait(1000)
+
+		Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond))
+		Expect(t.Limit()).To(Equal(int64(1000000)))
+	})
+
+	It("does not block when nil", func() {
+		var t *download.Throttle
+		t.Wait(1000)
+
+		Expect(t.Limit()).To(Equal(int64(0)))
+	})
+})
@@ -0,0 +1,89 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+
+	. "github.com/onsi/gomega"
+)
+
+// releaseFileContents returns the contents of the product files used by the
+// bulk download tests, keyed by the base name of their object keys.
+func releaseFileContents() map[string][]byte {
+	return map[string][]byte{
+		"product-1.0.pivotal": []byte("some tile contents"),
+		"cli-linux-1.0":       []byte("some linux cli contents"),
+		"cli-windows-1.0.exe": []byte("some windows cli contents"),
+	}
+}
+
+// downloadableProductFile returns a product file whose download link points
+// at server.
+func downloadableProductFile(server *ghttp.Server, id int, name string) pivnet.ProductFile {
+	return pivnet.ProductFile{
+		ID:           id,
+		Name:         fmt.Sprintf("Product File %d", id),
+		AWSObjectKey: "product-files/some-product/" + name,
+		Links: &pivnet.Links{
+			Download: map[string]string{
+				"href": fmt.Sprintf("%s%s/products/%s/product_files/%d/download", server.URL(), apiPrefix, productSlug, id),
+			},
+		},
+	}
+}
+
+// routeDownload redirects the download link of pf to cloudfront, which
+// serves content in ranges. Every GET fails if content is nil.
+func routeDownload(server *ghttp.Server, cloudfront *ghttp.Server, pf pivnet.ProductFile, content []byte) {
+	name := fmt.Sprintf("/%d-%s", pf.ID, path.Base(pf.AWSObjectKey))
+
+	server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/product_files/%d/download", apiPrefix, productSlug, pf.ID),
+		ghttp.RespondWith(http.StatusFound, []byte(`{}`), http.Header{
+			"Location": []string{cloudfront.URL() + name},
+		}),
+	)
+
+	cloudfront.RouteToHandler("HEAD", name,
+		ghttp.RespondWith(http.StatusOK, nil, http.Header{
+			"Content-Length": []string{strconv.Itoa(len(content))},
+		}),
+	)
+
+	cloudfront.RouteToHandler("GET", name, serveRanges(content))
+}
+
+// serveRanges responds to a ranged GET with the requested bytes of content.
+func serveRanges(content []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if content == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		start, end := requestedRange(req)
+
+		w.WriteHeader(http.StatusPartialContent)
+		_, err := w.Write(content[start : end+1])
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
+
+// requestedRange returns the inclusive bounds of the Range header of req.
+func requestedRange(req *http.Request) (int, int) {
+	matches := regexp.MustCompile(`bytes=(\d+)-(\d+)`).FindStringSubmatch(req.Header.Get("Range"))
+	Expect(matches).To(HaveLen(3))
+
+	start, err := strconv.Atoi(matches[1])
+	Expect(err).NotTo(HaveOccurred())
+
+	end, err := strconv.Atoi(matches[2])
+	Expect(err).NotTo(HaveOccurred())
+
+	return start, end
+}
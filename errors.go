@@ -77,3 +77,68 @@ func newErrUnavailableForLegalReasons() ErrUnavailableForLegalReasons {
 		Message:      "The EULA has not been accepted.",
 	}
 }
+
+// ErrSizeMismatch is returned before a download starts when the size
+// reported by the download server disagrees with the product file's size.
+type ErrSizeMismatch struct {
+	ProductFileID int
+	ExpectedBytes int64
+	ContentLength int64
+}
+
+func (e ErrSizeMismatch) Error() string {
+	return fmt.Sprintf(
+		"product file %d is expected to be %d bytes but the download server reports %d bytes",
+		e.ProductFileID,
+		e.ExpectedBytes,
+		e.ContentLength,
+	)
+}
+
+// ErrInsufficientSpace is returned before a download starts when the
+// destination filesystem does not have room for it.
+type ErrInsufficientSpace struct {
+	Path           string
+	RequiredBytes  int64
+	AvailableBytes int64
+}
+
+func (e ErrInsufficientSpace) Error() string {
+	return fmt.Sprintf(
+		"not enough free space to download to %s: %d bytes required, %d bytes available",
+		e.Path,
+		e.RequiredBytes,
+		e.AvailableBytes,
+	)
+}
+
+// ErrProductFileNotReady is returned when waiting for a product file to
+// become ready to serve ends because its transfer failed, the wait timed out
+// or it was cancelled. It holds the last status observed.
+type ErrProductFileNotReady struct {
+	ProductFileID      int
+	FileTransferStatus string
+	ReadyToServe       bool
+	Reason             string
+}
+
+func (e ErrProductFileNotReady) Error() string {
+	return fmt.Sprintf(
+		"product file %d is not ready to serve (file transfer status %q, ready to serve %t): %s",
+		e.ProductFileID,
+		e.FileTransferStatus,
+		e.ReadyToServe,
+		e.Reason,
+	)
+}
+
+// ErrInvalidConfig is returned by Validate methods, listing every problem
+// found so that they can all be fixed at once.
+type ErrInvalidConfig struct {
+	Kind     string
+	Problems []string
+}
+
+func (e ErrInvalidConfig) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Kind, strings.Join(e.Problems, "; "))
+}
@@ -0,0 +1,53 @@
+package pivnet
+
+import (
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+// acceptEULA accepts the EULA of a release whose download was refused,
+// provided the client has been configured to accept that EULA
+// automatically. It returns whether the EULA was accepted, in which case the
+// download is worth retrying.
+func (p ProductFilesService) acceptEULA(productSlug string, releaseID int) (bool, error) {
+	if len(p.client.autoAcceptEULASlugs) == 0 || releaseID == 0 {
+		return false, nil
+	}
+
+	releases := ReleasesService{client: p.client, l: p.client.logger}
+
+	release, err := releases.Get(productSlug, releaseID)
+	if err != nil {
+		return false, err
+	}
+
+	if release.EULA == nil || !p.client.autoAcceptEULASlugs[release.EULA.Slug] {
+		data := logger.Data{
+			"product":        productSlug,
+			"releaseID":      releaseID,
+			"releaseVersion": release.Version,
+		}
+		if release.EULA != nil {
+			data["eula"] = release.EULA.Slug
+		}
+
+		p.client.logger.Info("Not accepting EULA automatically as it is not allowed", data)
+		return false, nil
+	}
+
+	eulas := EULAsService{client: p.client}
+
+	err = eulas.Accept(productSlug, releaseID)
+	if err != nil {
+		return false, err
+	}
+
+	p.client.logger.Info("Accepted EULA automatically", logger.Data{
+		"eula":           release.EULA.Slug,
+		"eulaName":       release.EULA.Name,
+		"product":        productSlug,
+		"releaseID":      releaseID,
+		"releaseVersion": release.Version,
+	})
+
+	return true, nil
+}
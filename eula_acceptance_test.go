@@ -0,0 +1,214 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - automatic EULA acceptance", func() {
+	var (
+		server     *ghttp.Server
+		cloudfront *ghttp.Server
+		client     pivnet.Client
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      *loggerfakes.FakeLogger
+
+		releaseID     int
+		productFileID int
+		content       []byte
+		location      *os.File
+
+		eulaAccepted       int32
+		acceptanceRequests int32
+		release            pivnet.Release
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		cloudfront = ghttp.NewServer()
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}
+
+		releaseID = 1234
+		productFileID = 100
+		content = []byte("some file contents")
+
+		eulaAccepted = 0
+		acceptanceRequests = 0
+
+		release = pivnet.Release{
+			ID:      releaseID,
+			Version: "1.0.0",
+			EULA: &pivnet.EULA{
+				Slug: "some-eula",
+				Name: "Some EULA",
+			},
+		}
+
+		var err error
+		location, err = ioutil.TempFile("", "")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, releaseID, productFileID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{
+				ProductFile: pivnet.ProductFile{
+					ID: productFileID,
+					Links: &pivnet.Links{
+						Download: map[string]string{
+							"href": fmt.Sprintf("%s%s/products/%s/product_files/%d/download", server.URL(), apiPrefix, productSlug, productFileID),
+						},
+					},
+				},
+			}),
+		)
+
+		server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/product_files/%d/download", apiPrefix, productSlug, productFileID),
+			http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if atomic.LoadInt32(&eulaAccepted) == 0 {
+					w.WriteHeader(http.StatusUnavailableForLegalReasons)
+					w.Write([]byte(`{"message":"EULA not accepted"}`))
+					return
+				}
+
+				w.Header().Set("Location", cloudfront.URL()+"/some-file")
+				w.WriteHeader(http.StatusFound)
+			}),
+		)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d", apiPrefix, productSlug, releaseID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, release),
+		)
+
+		server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/releases/%d/eula_acceptance", apiPrefix, productSlug, releaseID),
+			http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&acceptanceRequests, 1)
+				atomic.StoreInt32(&eulaAccepted, 1)
+				w.Write([]byte(`{}`))
+			}),
+		)
+
+		cloudfront.RouteToHandler("HEAD", "/some-file",
+			ghttp.RespondWith(http.StatusOK, nil, http.Header{
+				"Content-Length": []string{strconv.Itoa(len(content))},
+			}),
+		)
+
+		cloudfront.RouteToHandler("GET", "/some-file",
+			ghttp.RespondWith(http.StatusPartialContent, content),
+		)
+	})
+
+	AfterEach(func() {
+		server.Close()
+		cloudfront.Close()
+
+		location.Close()
+		err := os.Remove(location.Name())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	download := func() error {
+		return client.ProductFiles.DownloadForRelease(location, productSlug, releaseID, productFileID, GinkgoWriter)
+	}
+
+	Context("when automatic acceptance is not configured", func() {
+		It("returns the error without accepting the EULA", func() {
+			err := download()
+			Expect(err).To(BeAssignableToTypeOf(pivnet.ErrUnavailableForLegalReasons{}))
+
+			Expect(atomic.LoadInt32(&acceptanceRequests)).To(BeZero())
+		})
+	})
+
+	Context("when the release EULA is allowed to be accepted automatically", func() {
+		BeforeEach(func() {
+			newClientConfig.AutoAcceptEULASlugs = []string{"other-eula", "some-eula"}
+			newClientConfig.DownloadConcurrency = 1
+		})
+
+		It("accepts the EULA, retries the download and logs the acceptance", func() {
+			err := download()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(atomic.LoadInt32(&acceptanceRequests)).To(Equal(int32(1)))
+
+			b, err := ioutil.ReadFile(location.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b).To(Equal(content))
+
+			var acceptanceLog []logger.Data
+			for i := 0; i < fakeLogger.InfoCallCount(); i++ {
+				message, data := fakeLogger.InfoArgsForCall(i)
+				if message == "Accepted EULA automatically" {
+					acceptanceLog = data
+				}
+			}
+			Expect(acceptanceLog).To(HaveLen(1))
+			Expect(acceptanceLog[0]).To(HaveKeyWithValue("eulaName", "Some EULA"))
+			Expect(acceptanceLog[0]).To(HaveKeyWithValue("releaseVersion", "1.0.0"))
+		})
+
+		Context("when the download is still refused after accepting the EULA", func() {
+			JustBeforeEach(func() {
+				server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/product_files/%d/download", apiPrefix, productSlug, productFileID),
+					ghttp.RespondWith(http.StatusUnavailableForLegalReasons, `{"message":"EULA not accepted"}`),
+				)
+			})
+
+			It("retries only once", func() {
+				err := download()
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrUnavailableForLegalReasons{}))
+
+				Expect(atomic.LoadInt32(&acceptanceRequests)).To(Equal(int32(1)))
+			})
+		})
+
+		Context("when accepting the EULA fails", func() {
+			JustBeforeEach(func() {
+				server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/releases/%d/eula_acceptance", apiPrefix, productSlug, releaseID),
+					ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+				)
+			})
+
+			It("returns the error", func() {
+				err := download()
+				Expect(err).To(MatchError(ContainSubstring("foo message")))
+			})
+		})
+	})
+
+	Context("when the release EULA is not in the allowlist", func() {
+		BeforeEach(func() {
+			newClientConfig.AutoAcceptEULASlugs = []string{"other-eula"}
+		})
+
+		It("returns the error without accepting the EULA", func() {
+			err := download()
+			Expect(err).To(BeAssignableToTypeOf(pivnet.ErrUnavailableForLegalReasons{}))
+
+			Expect(atomic.LoadInt32(&acceptanceRequests)).To(BeZero())
+		})
+	})
+})
@@ -0,0 +1,55 @@
+package pivnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+type FederationTokenService struct {
+	client Client
+}
+
+// FederationToken holds temporary AWS credentials for uploading product
+// files to the product's bucket.
+type FederationToken struct {
+	AccessKeyID     string `json:"access_key_id,omitempty" yaml:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty" yaml:"secret_access_key,omitempty"`
+	SessionToken    string `json:"session_token,omitempty" yaml:"session_token,omitempty"`
+	Bucket          string `json:"bucket,omitempty" yaml:"bucket,omitempty"`
+	Region          string `json:"region,omitempty" yaml:"region,omitempty"`
+}
+
+type federationTokenBody struct {
+	ProductID string `json:"product_id"`
+}
+
+func (f FederationTokenService) GenerateFederationToken(productSlug string) (FederationToken, error) {
+	url := "/federation_token"
+
+	b, err := json.Marshal(federationTokenBody{ProductID: productSlug})
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling
+		// a known-good body
+		return FederationToken{}, err
+	}
+
+	var response FederationToken
+	resp, err := f.client.MakeRequest(
+		"POST",
+		url,
+		http.StatusOK,
+		bytes.NewReader(b),
+	)
+	if err != nil {
+		return FederationToken{}, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return FederationToken{}, err
+	}
+
+	return response, nil
+}
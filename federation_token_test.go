@@ -0,0 +1,82 @@
+package pivnet_test
+
+import (
+	"net/http"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - federation token", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("GenerateFederationToken", func() {
+		It("returns upload credentials for the product", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", apiPrefix+"/federation_token"),
+					ghttp.VerifyJSON(`{"product_id":"some-product"}`),
+					ghttp.RespondWith(http.StatusOK, `{
+						"access_key_id": "some-access-key-id",
+						"secret_access_key": "some-secret-access-key",
+						"session_token": "some-session-token",
+						"bucket": "some-bucket",
+						"region": "some-region"
+					}`),
+				),
+			)
+
+			token, err := client.FederationToken.GenerateFederationToken("some-product")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(token).To(Equal(pivnet.FederationToken{
+				AccessKeyID:     "some-access-key-id",
+				SecretAccessKey: "some-secret-access-key",
+				SessionToken:    "some-session-token",
+				Bucket:          "some-bucket",
+				Region:          "some-region",
+			}))
+		})
+
+		Context("when the server responds with a non-2XX status code", func() {
+			It("returns an error", func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", apiPrefix+"/federation_token"),
+						ghttp.RespondWith(http.StatusTeapot, `{"message":"foo message"}`),
+					),
+				)
+
+				_, err := client.FederationToken.GenerateFederationToken("some-product")
+				Expect(err).To(MatchError(ContainSubstring("foo message")))
+			})
+		})
+	})
+})
@@ -0,0 +1,120 @@
+package pivnet
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+type DownloadFileGroupConfig struct {
+	ProductSlug    string
+	FileGroupID    int
+	DestinationDir string
+
+	DownloadOptions
+}
+
+type DownloadFileGroupsForReleaseConfig struct {
+	ProductSlug    string
+	ReleaseID      int
+	DestinationDir string
+
+	DownloadOptions
+}
+
+// Download downloads every product file in a file group into a subdirectory
+// of the destination directory named after the group, and writes a manifest
+// of the downloaded files to the configured manifest path or, by default, to
+// the destination directory. Files whose names would clash are prefixed with
+// their product file ID.
+func (f FileGroupsService) Download(config DownloadFileGroupConfig) (DownloadReport, error) {
+	fileGroup, err := f.Get(config.ProductSlug, config.FileGroupID)
+	if err != nil {
+		return DownloadReport{}, err
+	}
+
+	return f.download(
+		config.ProductSlug,
+		0,
+		[]FileGroup{fileGroup},
+		config.DestinationDir,
+		config.DownloadOptions,
+	)
+}
+
+// DownloadForRelease downloads every file group of a release as Download
+// does, writing a single manifest for the release.
+func (f FileGroupsService) DownloadForRelease(config DownloadFileGroupsForReleaseConfig) (DownloadReport, error) {
+	fileGroups, err := f.ListForRelease(config.ProductSlug, config.ReleaseID)
+	if err != nil {
+		return DownloadReport{}, err
+	}
+
+	return f.download(
+		config.ProductSlug,
+		config.ReleaseID,
+		fileGroups,
+		config.DestinationDir,
+		config.DownloadOptions,
+	)
+}
+
+func (f FileGroupsService) download(
+	productSlug string,
+	releaseID int,
+	fileGroups []FileGroup,
+	destinationDir string,
+	options DownloadOptions,
+) (DownloadReport, error) {
+	var jobs []downloadJob
+	for _, fileGroup := range fileGroups {
+		dir := SafePathComponent(fileGroup.Name)
+		if dir == "" {
+			dir = fmt.Sprintf("file-group-%d", fileGroup.ID)
+		}
+
+		for _, pf := range fileGroup.ProductFiles {
+			jobs = append(jobs, downloadJob{
+				productSlug: productSlug,
+				releaseID:   releaseID,
+				fileGroup:   fileGroup.Name,
+				productFile: pf,
+				path:        filepath.Join(destinationDir, dir, pf.FileName()),
+			})
+		}
+	}
+
+	disambiguatePaths(jobs)
+
+	productFiles := ProductFilesService{client: f.client}
+
+	err := productFiles.preflightJobs(destinationDir, jobs)
+	if err != nil {
+		return DownloadReport{}, err
+	}
+
+	results := productFiles.downloadAll(jobs, options)
+
+	var report DownloadReport
+	downloadErr := report.add(jobs, results)
+
+	manifest := Manifest{ProductSlug: productSlug}
+	if releaseID != 0 {
+		var err error
+		manifest, err = newReleaseManifest(f.client, productSlug, releaseID)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	manifestPath := options.ManifestPath
+	if manifestPath == "" {
+		manifestPath = filepath.Join(destinationDir, ManifestFileName)
+	}
+
+	err = writeManifest(manifest, manifestPath, jobs, results)
+	if err != nil {
+		return report, err
+	}
+
+	return report, downloadErr
+}
@@ -0,0 +1,271 @@
+package pivnet_test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - download file groups", func() {
+	var (
+		server     *ghttp.Server
+		cloudfront *ghttp.Server
+		client     pivnet.Client
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		releaseID      int
+		destinationDir string
+		fileGroups     []pivnet.FileGroup
+		contents       map[string][]byte
+	)
+
+	readManifest := func() pivnet.Manifest {
+		manifest, err := pivnet.ReadManifest(filepath.Join(destinationDir, pivnet.ManifestFileName))
+		Expect(err).NotTo(HaveOccurred())
+
+		return manifest
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		cloudfront = ghttp.NewServer()
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		releaseID = 1234
+
+		var err error
+		destinationDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		contents = releaseFileContents()
+
+		fileGroups = []pivnet.FileGroup{
+			{
+				ID:   10,
+				Name: "Tiles",
+				ProductFiles: []pivnet.ProductFile{
+					downloadableProductFile(server, 100, "product-1.0.pivotal"),
+				},
+			},
+			{
+				ID:   11,
+				Name: "CLIs",
+				ProductFiles: []pivnet.ProductFile{
+					downloadableProductFile(server, 101, "cli-linux-1.0"),
+					downloadableProductFile(server, 102, "cli-windows-1.0.exe"),
+				},
+			},
+		}
+		linuxMD5 := md5.Sum(contents["cli-linux-1.0"])
+		fileGroups[1].ProductFiles[0].MD5 = hex.EncodeToString(linuxMD5[:])
+	})
+
+	JustBeforeEach(func() {
+		for _, fileGroup := range fileGroups {
+			for _, pf := range fileGroup.ProductFiles {
+				routeDownload(server, cloudfront, pf, contents[filepath.Base(pf.AWSObjectKey)])
+			}
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		cloudfront.Close()
+
+		err := os.RemoveAll(destinationDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("Download", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/file_groups/%d", apiPrefix, productSlug, fileGroups[1].ID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, fileGroups[1]),
+			)
+		})
+
+		BeforeEach(func() {
+			contents["cli-linux-1.0"] = nil
+		})
+
+		It("downloads the files of the group into a directory named after it", func() {
+			report, err := client.FileGroups.Download(pivnet.DownloadFileGroupConfig{
+				ProductSlug:    productSlug,
+				FileGroupID:    fileGroups[1].ID,
+				DestinationDir: destinationDir,
+			})
+			Expect(err).To(MatchError("1 of 2 product files failed to download"))
+
+			Expect(report.Succeeded).To(Equal([]pivnet.DownloadedProductFile{
+				{
+					ProductFile: fileGroups[1].ProductFiles[1],
+					Path:        filepath.Join(destinationDir, "CLIs", "cli-windows-1.0.exe"),
+				},
+			}))
+			Expect(report.Failed).To(HaveLen(1))
+			Expect(report.Failed[0].ProductFile).To(Equal(fileGroups[1].ProductFiles[0]))
+
+			content, err := ioutil.ReadFile(report.Succeeded[0].Path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(content).To(Equal(contents["cli-windows-1.0.exe"]))
+
+			manifest := readManifest()
+			Expect(manifest.ProductSlug).To(Equal(productSlug))
+			Expect(manifest.ReleaseID).To(BeZero())
+			Expect(manifest.Files).To(HaveLen(1))
+
+			file := manifest.Files[0]
+			Expect(file.FileGroup).To(Equal("CLIs"))
+			Expect(file.ProductFileID).To(Equal(102))
+			Expect(file.Name).To(Equal("Product File 102"))
+			Expect(file.AWSObjectKey).To(Equal("product-files/some-product/cli-windows-1.0.exe"))
+			Expect(file.Path).To(Equal("CLIs/cli-windows-1.0.exe"))
+			Expect(file.Size).To(Equal(int64(len(contents["cli-windows-1.0.exe"]))))
+			Expect(file.DownloadedAt).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(file.SourceHost).To(Equal(strings.TrimPrefix(cloudfront.URL(), "http://")))
+
+			Expect(manifest.Verify(destinationDir)).To(Succeed())
+		})
+
+		Context("when getting the file group fails", func() {
+			JustBeforeEach(func() {
+				server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/file_groups/%d", apiPrefix, productSlug, fileGroups[1].ID),
+					ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+				)
+			})
+
+			It("forwards the error", func() {
+				_, err := client.FileGroups.Download(pivnet.DownloadFileGroupConfig{
+					ProductSlug:    productSlug,
+					FileGroupID:    fileGroups[1].ID,
+					DestinationDir: destinationDir,
+				})
+				Expect(err).To(MatchError(ContainSubstring("foo message")))
+			})
+		})
+	})
+
+	Describe("DownloadForRelease", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/file_groups", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{FileGroups: fileGroups}),
+			)
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.Release{ID: releaseID, Version: "1.0.0"}),
+			)
+		})
+
+		It("downloads every file group of the release and writes a manifest", func() {
+			report, err := client.FileGroups.DownloadForRelease(pivnet.DownloadFileGroupsForReleaseConfig{
+				ProductSlug:     productSlug,
+				ReleaseID:       releaseID,
+				DestinationDir:  destinationDir,
+				DownloadOptions: pivnet.DownloadOptions{ConcurrentFiles: 1},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Succeeded).To(HaveLen(3))
+			Expect(report.Failed).To(BeEmpty())
+
+			for _, relativePath := range []string{
+				"Tiles/product-1.0.pivotal",
+				"CLIs/cli-linux-1.0",
+				"CLIs/cli-windows-1.0.exe",
+			} {
+				content, err := ioutil.ReadFile(filepath.Join(destinationDir, filepath.FromSlash(relativePath)))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(content).To(Equal(contents[filepath.Base(relativePath)]))
+			}
+
+			manifest := readManifest()
+			Expect(manifest.ProductSlug).To(Equal(productSlug))
+			Expect(manifest.ReleaseID).To(Equal(releaseID))
+			Expect(manifest.ReleaseVersion).To(Equal("1.0.0"))
+
+			var paths []string
+			for _, file := range manifest.Files {
+				paths = append(paths, file.FileGroup+":"+file.Path)
+			}
+			Expect(paths).To(ConsistOf(
+				"Tiles:Tiles/product-1.0.pivotal",
+				"CLIs:CLIs/cli-linux-1.0",
+				"CLIs:CLIs/cli-windows-1.0.exe",
+			))
+
+			Expect(manifest.Verify(destinationDir)).To(Succeed())
+		})
+
+		Context("when a manifest path is given", func() {
+			It("writes the manifest there with paths relative to it", func() {
+				manifestPath := filepath.Join(destinationDir, "meta", "manifest.yml")
+
+				_, err := client.FileGroups.DownloadForRelease(pivnet.DownloadFileGroupsForReleaseConfig{
+					ProductSlug:     productSlug,
+					ReleaseID:       releaseID,
+					DestinationDir:  destinationDir,
+					DownloadOptions: pivnet.DownloadOptions{ManifestPath: manifestPath},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				manifest, err := pivnet.ReadManifest(manifestPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(manifest.Files).To(HaveLen(3))
+				Expect(manifest.Files[0].Path).To(HavePrefix("../"))
+				Expect(manifest.Verify(filepath.Dir(manifestPath))).To(Succeed())
+
+				_, err = os.Stat(filepath.Join(destinationDir, pivnet.ManifestFileName))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
+		Context("when a product file has no download link", func() {
+			BeforeEach(func() {
+				fileGroups[0].ProductFiles[0].Links = nil
+			})
+
+			JustBeforeEach(func() {
+				withLinks := downloadableProductFile(server, 100, "product-1.0.pivotal")
+				server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, releaseID, 100),
+					ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: withLinks}),
+				)
+				routeDownload(server, cloudfront, withLinks, contents["product-1.0.pivotal"])
+			})
+
+			It("fetches the product file before downloading it", func() {
+				_, err := client.FileGroups.DownloadForRelease(pivnet.DownloadFileGroupsForReleaseConfig{
+					ProductSlug:    productSlug,
+					ReleaseID:      releaseID,
+					DestinationDir: destinationDir,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				content, err := ioutil.ReadFile(filepath.Join(destinationDir, "Tiles", "product-1.0.pivotal"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(content).To(Equal(contents["product-1.0.pivotal"]))
+			})
+		})
+	})
+})
@@ -0,0 +1,98 @@
+package pivnet
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName returns the name under which a product file is saved, preferring
+// the base name of its AWS object key.
+func (p ProductFile) FileName() string {
+	for _, name := range []string{p.AWSObjectKey, p.Name} {
+		base := SafePathComponent(path.Base(filepath.ToSlash(name)))
+		if name != "" && base != "" {
+			return base
+		}
+	}
+
+	return fmt.Sprintf("product-file-%d", p.ID)
+}
+
+// UniqueFileNames returns the FileName of each product file, prefixed with
+// its product file ID where it would clash with another's, e.g. when two AWS
+// object keys have the same base name under different prefixes.
+func UniqueFileNames(productFiles []ProductFile) []string {
+	names := make([]string, len(productFiles))
+	count := make(map[string]int)
+	for i, pf := range productFiles {
+		names[i] = pf.FileName()
+		count[names[i]]++
+	}
+
+	for i, pf := range productFiles {
+		if count[names[i]] > 1 {
+			names[i] = prefixWithID(pf.ID, names[i])
+		}
+	}
+
+	return names
+}
+
+func prefixWithID(productFileID int, name string) string {
+	return fmt.Sprintf("%d-%s", productFileID, name)
+}
+
+// SafePathComponent makes name usable as a single path component, returning
+// an empty string if that is not possible.
+func SafePathComponent(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	if name == "." || name == ".." {
+		return ""
+	}
+
+	return name
+}
+
+// ValidateFilePatterns checks that each pattern is a valid glob pattern, as
+// understood by path.Match.
+func ValidateFilePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %s", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// MatchesFilePatterns reports whether the product file name or the base name
+// of its AWS object key matches any of the patterns, which must have been
+// checked with ValidateFilePatterns.
+func (p ProductFile) MatchesFilePatterns(patterns []string) bool {
+	names := []string{p.Name, path.Base(p.AWSObjectKey)}
+
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if name == "" || name == "." {
+				continue
+			}
+
+			// Patterns have been validated, so errors cannot occur
+			matched, _ := path.Match(pattern, name)
+			if matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
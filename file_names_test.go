@@ -0,0 +1,56 @@
+package pivnet_test
+
+import (
+	"github.com/pivotal-cf/go-pivnet"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Product file names", func() {
+	Describe("FileName", func() {
+		It("prefers the base name of the AWS object key", func() {
+			pf := pivnet.ProductFile{ID: 1, Name: "Some CLI", AWSObjectKey: "product-files/some-product/cli-linux"}
+			Expect(pf.FileName()).To(Equal("cli-linux"))
+		})
+
+		It("falls back to the base name of the name", func() {
+			pf := pivnet.ProductFile{ID: 1, Name: "Some CLI"}
+			Expect(pf.FileName()).To(Equal("Some CLI"))
+		})
+
+		It("falls back to the ID", func() {
+			pf := pivnet.ProductFile{ID: 1, Name: ".."}
+			Expect(pf.FileName()).To(Equal("product-file-1"))
+		})
+	})
+
+	Describe("UniqueFileNames", func() {
+		It("prefixes clashing names with the product file ID", func() {
+			names := pivnet.UniqueFileNames([]pivnet.ProductFile{
+				{ID: 1, AWSObjectKey: "product-files/some-product/cli-linux"},
+				{ID: 2, AWSObjectKey: "product-files/some-product/other/cli-linux"},
+				{ID: 3, AWSObjectKey: "product-files/some-product/cli-windows.exe"},
+			})
+
+			Expect(names).To(Equal([]string{"1-cli-linux", "2-cli-linux", "cli-windows.exe"}))
+		})
+	})
+
+	Describe("MatchesFilePatterns", func() {
+		pf := pivnet.ProductFile{Name: "CLI - Linux", AWSObjectKey: "product-files/some-product/cli-linux"}
+
+		It("matches the name or the base name of the AWS object key", func() {
+			Expect(pf.MatchesFilePatterns([]string{"CLI - *"})).To(BeTrue())
+			Expect(pf.MatchesFilePatterns([]string{"cli-*"})).To(BeTrue())
+			Expect(pf.MatchesFilePatterns([]string{"product-files/*"})).To(BeFalse())
+		})
+	})
+
+	Describe("ValidateFilePatterns", func() {
+		It("rejects malformed patterns", func() {
+			Expect(pivnet.ValidateFilePatterns([]string{"*.tgz"})).To(Succeed())
+			Expect(pivnet.ValidateFilePatterns([]string{"["})).To(MatchError(ContainSubstring(`invalid pattern "["`)))
+		})
+	})
+})
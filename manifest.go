@@ -0,0 +1,207 @@
+package pivnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ManifestFileName is the name of the manifest written alongside the
+// product files of a file group download when no manifest path is given.
+const ManifestFileName = "manifest.json"
+
+// Manifest records the product files that were downloaded and where they
+// came from.
+type Manifest struct {
+	ProductSlug    string         `json:"product_slug" yaml:"product_slug"`
+	ReleaseID      int            `json:"release_id,omitempty" yaml:"release_id,omitempty"`
+	ReleaseVersion string         `json:"release_version,omitempty" yaml:"release_version,omitempty"`
+	Files          []ManifestFile `json:"files" yaml:"files"`
+}
+
+type ManifestFile struct {
+	FileGroup     string `json:"file_group,omitempty" yaml:"file_group,omitempty"`
+	ProductFileID int    `json:"product_file_id" yaml:"product_file_id"`
+	Name          string `json:"name" yaml:"name"`
+	AWSObjectKey  string `json:"aws_object_key" yaml:"aws_object_key"`
+	// Path is relative to the directory containing the manifest
+	Path         string    `json:"path" yaml:"path"`
+	Size         int64     `json:"size" yaml:"size"`
+	MD5          string    `json:"md5,omitempty" yaml:"md5,omitempty"`
+	SHA256       string    `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at" yaml:"downloaded_at"`
+	// SourceHost is empty when the file was served from the download cache
+	SourceHost string `json:"source_host,omitempty" yaml:"source_host,omitempty"`
+}
+
+func newReleaseManifest(client Client, productSlug string, releaseID int) (Manifest, error) {
+	releases := ReleasesService{client: client, l: client.logger}
+
+	release, err := releases.Get(productSlug, releaseID)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	return Manifest{
+		ProductSlug:    productSlug,
+		ReleaseID:      releaseID,
+		ReleaseVersion: release.Version,
+	}, nil
+}
+
+// writeManifest adds the successfully downloaded jobs to the manifest and
+// writes it to manifestPath.
+func writeManifest(
+	manifest Manifest,
+	manifestPath string,
+	jobs []downloadJob,
+	results []downloadResult,
+) error {
+	manifest.Files = []ManifestFile{}
+	for i, job := range jobs {
+		if results[i].err != nil {
+			continue
+		}
+
+		err := manifest.add(manifestPath, job, results[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	err := os.MkdirAll(filepath.Dir(manifestPath), 0755)
+	if err != nil {
+		return err
+	}
+
+	return manifest.Write(manifestPath)
+}
+
+func (m *Manifest) add(manifestPath string, job downloadJob, result downloadResult) error {
+	stat, err := os.Stat(job.path)
+	if err != nil {
+		return err
+	}
+
+	relativePath, err := filepath.Rel(filepath.Dir(manifestPath), job.path)
+	if err != nil {
+		return err
+	}
+
+	m.Files = append(m.Files, ManifestFile{
+		FileGroup:     job.fileGroup,
+		ProductFileID: job.productFile.ID,
+		Name:          job.productFile.Name,
+		AWSObjectKey:  job.productFile.AWSObjectKey,
+		Path:          filepath.ToSlash(relativePath),
+		Size:          stat.Size(),
+		MD5:           job.productFile.MD5,
+		SHA256:        job.productFile.SHA256,
+		DownloadedAt:  result.downloadedAt,
+		SourceHost:    result.sourceHost,
+	})
+
+	return nil
+}
+
+// Write writes the manifest to filePath, as YAML if the path ends in .yml or
+// .yaml and as JSON otherwise.
+func (m Manifest) Write(filePath string) error {
+	var b []byte
+	var err error
+	if isYAMLPath(filePath) {
+		b, err = yaml.Marshal(m)
+	} else {
+		b, err = json.MarshalIndent(m, "", "  ")
+		b = append(b, '\n')
+	}
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling a known-good body
+		return err
+	}
+
+	return ioutil.WriteFile(filePath, b, 0644)
+}
+
+// ReadManifest reads a manifest written by Write.
+func ReadManifest(filePath string) (Manifest, error) {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	var m Manifest
+	if isYAMLPath(filePath) {
+		err = yaml.Unmarshal(b, &m)
+	} else {
+		err = json.Unmarshal(b, &m)
+	}
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to parse manifest %s: %s", filePath, err)
+	}
+
+	return m, nil
+}
+
+// Verify checks, without contacting Pivotal Network, that every file in the
+// manifest is present in dir with the recorded size and checksums.
+func (m Manifest) Verify(dir string) error {
+	var failures []string
+	for _, file := range m.Files {
+		err := file.verify(dir)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf(
+			"%d of %d files failed verification: %s",
+			len(failures),
+			len(m.Files),
+			strings.Join(failures, "; "),
+		)
+	}
+
+	return nil
+}
+
+func (f ManifestFile) verify(dir string) error {
+	location, err := os.Open(filepath.Join(dir, filepath.FromSlash(f.Path)))
+	if err != nil {
+		return err
+	}
+	defer location.Close()
+
+	stat, err := location.Stat()
+	if err != nil {
+		return err
+	}
+
+	if stat.Size() != f.Size {
+		return fmt.Errorf(
+			"size mismatch for %s: expected %d bytes, got %d",
+			location.Name(),
+			f.Size,
+			stat.Size(),
+		)
+	}
+
+	pf := ProductFile{
+		MD5:    f.MD5,
+		SHA256: f.SHA256,
+	}
+
+	return pf.Verify(location)
+}
+
+func isYAMLPath(filePath string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	return ext == ".yml" || ext == ".yaml"
+}
@@ -0,0 +1,151 @@
+package pivnet_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manifest", func() {
+	var (
+		dir      string
+		content  []byte
+		manifest pivnet.Manifest
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		content = []byte("some file contents")
+
+		err = os.MkdirAll(filepath.Join(dir, "some-group"), 0755)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = ioutil.WriteFile(filepath.Join(dir, "some-group", "some-file"), content, 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		md5Sum := md5.Sum(content)
+		sha256Sum := sha256.Sum256(content)
+
+		manifest = pivnet.Manifest{
+			ProductSlug:    "some-product",
+			ReleaseID:      1234,
+			ReleaseVersion: "1.0.0",
+			Files: []pivnet.ManifestFile{
+				{
+					FileGroup:     "some-group",
+					ProductFileID: 100,
+					Name:          "Some File",
+					AWSObjectKey:  "product-files/some-product/some-file",
+					Path:          "some-group/some-file",
+					Size:          int64(len(content)),
+					MD5:           hex.EncodeToString(md5Sum[:]),
+					SHA256:        hex.EncodeToString(sha256Sum[:]),
+					DownloadedAt:  time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC),
+					SourceHost:    "example.com",
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(dir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("Write and ReadManifest", func() {
+		for _, name := range []string{"manifest.json", "manifest.yml", "manifest.yaml"} {
+			name := name
+
+			It("round-trips the manifest through "+name, func() {
+				manifestPath := filepath.Join(dir, name)
+
+				err := manifest.Write(manifestPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				read, err := pivnet.ReadManifest(manifestPath)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(read).To(Equal(manifest))
+			})
+		}
+
+		It("writes YAML to .yml paths", func() {
+			manifestPath := filepath.Join(dir, "manifest.yml")
+
+			err := manifest.Write(manifestPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			b, err := ioutil.ReadFile(manifestPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(b)).To(ContainSubstring("product_slug: some-product"))
+		})
+
+		Context("when the manifest cannot be parsed", func() {
+			It("returns an error", func() {
+				manifestPath := filepath.Join(dir, "manifest.json")
+
+				err := ioutil.WriteFile(manifestPath, []byte("{"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = pivnet.ReadManifest(manifestPath)
+				Expect(err).To(MatchError(ContainSubstring("failed to parse manifest")))
+			})
+		})
+	})
+
+	Describe("Verify", func() {
+		It("succeeds when every file matches", func() {
+			Expect(manifest.Verify(dir)).To(Succeed())
+		})
+
+		Context("when a file has been modified", func() {
+			BeforeEach(func() {
+				err := ioutil.WriteFile(filepath.Join(dir, "some-group", "some-file"), []byte("other file contents"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("reports the mismatch", func() {
+				err := manifest.Verify(dir)
+				Expect(err).To(MatchError(ContainSubstring("1 of 1 files failed verification")))
+				Expect(err).To(MatchError(ContainSubstring("size mismatch")))
+			})
+		})
+
+		Context("when a file has been corrupted without changing its size", func() {
+			BeforeEach(func() {
+				err := ioutil.WriteFile(filepath.Join(dir, "some-group", "some-file"), []byte("some file CONTENTS"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("reports the checksum mismatch", func() {
+				err := manifest.Verify(dir)
+				Expect(err).To(MatchError(ContainSubstring("SHA256 mismatch")))
+			})
+		})
+
+		Context("when a file is missing", func() {
+			BeforeEach(func() {
+				err := os.Remove(filepath.Join(dir, "some-group", "some-file"))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an error", func() {
+				err := manifest.Verify(dir)
+				Expect(err).To(MatchError(ContainSubstring("1 of 1 files failed verification")))
+			})
+		})
+	})
+})
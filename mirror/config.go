@@ -0,0 +1,110 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pivotal-cf/go-pivnet"
+	"gopkg.in/yaml.v2"
+)
+
+type Config struct {
+	// Dir is the root of the mirror. Product files are stored under
+	// <product slug>/<release version>/ and the index in index.json.
+	Dir string `json:"dir" yaml:"dir"`
+
+	Products []ProductConfig `json:"products" yaml:"products"`
+
+	// Prune removes releases and product files mirrored by earlier runs
+	// that are no longer selected.
+	Prune bool `json:"prune,omitempty" yaml:"prune,omitempty"`
+
+	// ConcurrentFiles is the number of product files downloaded at the same
+	// time. Defaults to 4 when unset.
+	ConcurrentFiles int `json:"concurrent_files,omitempty" yaml:"concurrent_files,omitempty"`
+
+	// ProgressWriter receives the progress of each download. Progress is
+	// discarded when unset.
+	ProgressWriter io.Writer `json:"-" yaml:"-"`
+}
+
+type ProductConfig struct {
+	Slug string `json:"slug" yaml:"slug"`
+
+	// Versions is a Constraint on the release versions to mirror. Every
+	// release is mirrored when it is empty.
+	Versions string `json:"versions,omitempty" yaml:"versions,omitempty"`
+
+	// Files are glob patterns, matched as by
+	// pivnet.ProductFile.MatchesFilePatterns. Every product file is mirrored
+	// when it is empty.
+	Files []string `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+// LoadConfig reads a config from a YAML file if the path ends in .yml or
+// .yaml, and from a JSON file otherwise.
+func LoadConfig(configPath string) (Config, error) {
+	b, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var config Config
+	ext := strings.ToLower(filepath.Ext(configPath))
+	if ext == ".yml" || ext == ".yaml" {
+		err = yaml.Unmarshal(b, &config)
+	} else {
+		err = json.Unmarshal(b, &config)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse config %s: %s", configPath, err)
+	}
+
+	return config, nil
+}
+
+// selector holds a validated ProductConfig.
+type selector struct {
+	slug       string
+	constraint Constraint
+	files      []string
+}
+
+func (c Config) selectors() ([]selector, error) {
+	if c.Dir == "" {
+		return nil, fmt.Errorf("mirror directory must not be empty")
+	}
+
+	var selectors []selector
+	for _, product := range c.Products {
+		if product.Slug == "" {
+			return nil, fmt.Errorf("product slug must not be empty")
+		}
+
+		constraint, err := ParseConstraint(product.Versions)
+		if err != nil {
+			return nil, err
+		}
+
+		err = pivnet.ValidateFilePatterns(product.Files)
+		if err != nil {
+			return nil, err
+		}
+
+		selectors = append(selectors, selector{
+			slug:       product.Slug,
+			constraint: constraint,
+			files:      product.Files,
+		})
+	}
+
+	return selectors, nil
+}
+
+func (s selector) matchesFile(pf pivnet.ProductFile) bool {
+	return len(s.files) == 0 || pf.MatchesFilePatterns(s.files)
+}
@@ -0,0 +1,83 @@
+package mirror_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/go-pivnet/mirror"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LoadConfig", func() {
+	var (
+		dir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		err := os.RemoveAll(dir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	expected := mirror.Config{
+		Dir:   "/var/mirror",
+		Prune: true,
+		Products: []mirror.ProductConfig{
+			{
+				Slug:     "some-product",
+				Versions: ">=1.10.0, <1.12",
+				Files:    []string{"*.pivotal"},
+			},
+		},
+	}
+
+	It("reads YAML configs", func() {
+		configPath := filepath.Join(dir, "mirror.yml")
+		err := ioutil.WriteFile(configPath, []byte(`
+dir: /var/mirror
+prune: true
+products:
+- slug: some-product
+  versions: ">=1.10.0, <1.12"
+  files: ["*.pivotal"]
+`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		config, err := mirror.LoadConfig(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(Equal(expected))
+	})
+
+	It("reads JSON configs", func() {
+		configPath := filepath.Join(dir, "mirror.json")
+		err := ioutil.WriteFile(configPath, []byte(`{
+  "dir": "/var/mirror",
+  "prune": true,
+  "products": [{"slug": "some-product", "versions": ">=1.10.0, <1.12", "files": ["*.pivotal"]}]
+}`), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		config, err := mirror.LoadConfig(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(Equal(expected))
+	})
+
+	Context("when the config cannot be parsed", func() {
+		It("returns an error", func() {
+			configPath := filepath.Join(dir, "mirror.json")
+			err := ioutil.WriteFile(configPath, []byte("{"), 0644)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = mirror.LoadConfig(configPath)
+			Expect(err).To(MatchError(ContainSubstring("failed to parse config")))
+		})
+	})
+})
@@ -0,0 +1,191 @@
+package mirror
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Constraint selects release versions. It is a comma-separated list of
+// clauses, all of which must hold, such as ">=1.10.0, <1.12". A clause is a
+// version optionally preceded by one of =, !=, >, >=, <, <= or ~>. A version
+// ending in .x or .* matches every version with that prefix, and "~>1.10"
+// matches 1.10 and later releases before 2, while "~>1.10.2" matches 1.10.2
+// and later releases before 1.11. The empty constraint matches every
+// version.
+type Constraint struct {
+	clauses []clause
+}
+
+type clause struct {
+	operator string
+	version  []string
+	wildcard bool
+}
+
+var operators = []string{"~>", ">=", "<=", "!=", ">", "<", "="}
+
+func ParseConstraint(constraint string) (Constraint, error) {
+	var c Constraint
+	if strings.TrimSpace(constraint) == "" {
+		return c, nil
+	}
+
+	for _, part := range strings.Split(constraint, ",") {
+		part = strings.TrimSpace(part)
+
+		cl := clause{operator: "="}
+		for _, operator := range operators {
+			if strings.HasPrefix(part, operator) {
+				cl.operator = operator
+				part = strings.TrimSpace(strings.TrimPrefix(part, operator))
+				break
+			}
+		}
+
+		if part == "" {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: missing version", constraint)
+		}
+
+		if strings.HasSuffix(part, ".x") || strings.HasSuffix(part, ".*") {
+			if cl.operator != "=" && cl.operator != "!=" {
+				return Constraint{}, fmt.Errorf("invalid version constraint %q: wildcards cannot be used with %s", constraint, cl.operator)
+			}
+
+			cl.wildcard = true
+			part = part[:len(part)-2]
+		}
+
+		cl.version = strings.Split(part, ".")
+
+		if cl.operator == "~>" && len(cl.version) < 2 {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: ~> requires at least two version segments", constraint)
+		}
+
+		c.clauses = append(c.clauses, cl)
+	}
+
+	return c, nil
+}
+
+func (c Constraint) Matches(version string) bool {
+	segments := strings.Split(version, ".")
+
+	for _, cl := range c.clauses {
+		if !cl.matches(segments) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (cl clause) matches(version []string) bool {
+	if cl.wildcard {
+		matched := hasPrefix(version, cl.version)
+		if cl.operator == "!=" {
+			return !matched
+		}
+		return matched
+	}
+
+	comparison := compareVersions(version, cl.version)
+
+	switch cl.operator {
+	case "=":
+		return comparison == 0
+	case "!=":
+		return comparison != 0
+	case ">":
+		return comparison > 0
+	case ">=":
+		return comparison >= 0
+	case "<":
+		return comparison < 0
+	case "<=":
+		return comparison <= 0
+	case "~>":
+		prefix := cl.version[:len(cl.version)-1]
+		return comparison >= 0 && hasPrefix(version, prefix)
+	default:
+		return false
+	}
+}
+
+func hasPrefix(version []string, prefix []string) bool {
+	if len(version) < len(prefix) {
+		return false
+	}
+
+	for i := range prefix {
+		if compareSegments(version[i], prefix[i]) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareVersions compares versions segment by segment, treating missing
+// segments as zero, so that 1.10 and 1.10.0 are equal.
+func compareVersions(a []string, b []string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		aSegment, bSegment := "0", "0"
+		if i < len(a) {
+			aSegment = a[i]
+		}
+		if i < len(b) {
+			bSegment = b[i]
+		}
+
+		comparison := compareSegments(aSegment, bSegment)
+		if comparison != 0 {
+			return comparison
+		}
+	}
+
+	return 0
+}
+
+// compareSegments compares the leading numbers of two version segments,
+// then the remainder lexically. A pre-release suffix such as -rc.1 sorts
+// before the same segment without one.
+func compareSegments(a string, b string) int {
+	aNumber, aRest := splitNumber(a)
+	bNumber, bRest := splitNumber(b)
+
+	switch {
+	case aNumber < bNumber:
+		return -1
+	case aNumber > bNumber:
+		return 1
+	case aRest == bRest:
+		return 0
+	case aRest == "" && strings.HasPrefix(bRest, "-"):
+		return 1
+	case bRest == "" && strings.HasPrefix(aRest, "-"):
+		return -1
+	default:
+		return strings.Compare(aRest, bRest)
+	}
+}
+
+func splitNumber(segment string) (int, string) {
+	i := 0
+	for i < len(segment) && segment[i] >= '0' && segment[i] <= '9' {
+		i++
+	}
+
+	// Segments without a leading number sort before every number
+	if i == 0 {
+		return -1, segment
+	}
+
+	n, err := strconv.Atoi(segment[:i])
+	if err != nil {
+		// Untested as only overlong numbers fail to parse
+		return -1, segment
+	}
+
+	return n, segment[i:]
+}
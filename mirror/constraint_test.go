@@ -0,0 +1,70 @@
+package mirror_test
+
+import (
+	"github.com/pivotal-cf/go-pivnet/mirror"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Constraint", func() {
+	matches := func(constraint string, version string) bool {
+		c, err := mirror.ParseConstraint(constraint)
+		Expect(err).NotTo(HaveOccurred())
+
+		return c.Matches(version)
+	}
+
+	It("matches every version when empty", func() {
+		Expect(matches("", "1.2.3")).To(BeTrue())
+		Expect(matches(" ", "anything")).To(BeTrue())
+	})
+
+	It("matches exact versions, treating missing segments as zero", func() {
+		Expect(matches("1.10.0", "1.10.0")).To(BeTrue())
+		Expect(matches("=1.10", "1.10.0")).To(BeTrue())
+		Expect(matches("1.10.0", "1.10.1")).To(BeFalse())
+		Expect(matches("!=1.10.0", "1.10.1")).To(BeTrue())
+	})
+
+	It("compares segments numerically", func() {
+		Expect(matches(">1.9", "1.10.0")).To(BeTrue())
+		Expect(matches(">=1.10.0", "1.10.0")).To(BeTrue())
+		Expect(matches("<1.10", "1.9.9")).To(BeTrue())
+		Expect(matches("<=1.10", "1.10.1")).To(BeFalse())
+	})
+
+	It("requires every clause to hold", func() {
+		Expect(matches(">=1.10.0, <1.12", "1.11.5")).To(BeTrue())
+		Expect(matches(">=1.10.0, <1.12", "1.12.0")).To(BeFalse())
+		Expect(matches(">=1.10.0, <1.12", "1.9.0")).To(BeFalse())
+	})
+
+	It("supports wildcards", func() {
+		Expect(matches("1.10.x", "1.10.7")).To(BeTrue())
+		Expect(matches("1.10.*", "1.11.0")).To(BeFalse())
+		Expect(matches("!=1.10.x", "1.11.0")).To(BeTrue())
+	})
+
+	It("supports pessimistic constraints", func() {
+		Expect(matches("~>1.10", "1.12.0")).To(BeTrue())
+		Expect(matches("~>1.10", "2.0.0")).To(BeFalse())
+		Expect(matches("~>1.10.2", "1.10.5")).To(BeTrue())
+		Expect(matches("~>1.10.2", "1.10.1")).To(BeFalse())
+		Expect(matches("~>1.10.2", "1.11.0")).To(BeFalse())
+	})
+
+	It("orders pre-release suffixes before the release", func() {
+		Expect(matches("<1.10.0", "1.10.0-rc.1")).To(BeTrue())
+		Expect(matches(">=1.10.0", "1.10.0-rc.1")).To(BeFalse())
+	})
+
+	Context("when the constraint is invalid", func() {
+		It("returns an error", func() {
+			for _, constraint := range []string{">=", "1.0, ", ">1.x", "~>1"} {
+				_, err := mirror.ParseConstraint(constraint)
+				Expect(err).To(HaveOccurred(), constraint)
+			}
+		})
+	})
+})
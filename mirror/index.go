@@ -0,0 +1,115 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet"
+)
+
+// IndexFileName is the name of the index at the root of the mirror.
+const IndexFileName = "index.json"
+
+// Index describes everything in the mirror.
+type Index struct {
+	Products []IndexProduct `json:"products"`
+}
+
+type IndexProduct struct {
+	Product  pivnet.Product `json:"product"`
+	Releases []IndexRelease `json:"releases"`
+}
+
+type IndexRelease struct {
+	Release pivnet.Release `json:"release"`
+	Files   []IndexFile    `json:"files"`
+}
+
+type IndexFile struct {
+	ProductFile pivnet.ProductFile `json:"product_file"`
+	FileGroups  []string           `json:"file_groups,omitempty"`
+	// Path is relative to the root of the mirror
+	Path       string    `json:"path"`
+	MirroredAt time.Time `json:"mirrored_at"`
+}
+
+// ReadIndex reads the index of the mirror rooted at dir. An empty index is
+// returned if the mirror has not been created yet.
+func ReadIndex(dir string) (Index, error) {
+	b, err := ioutil.ReadFile(indexPath(dir))
+	if os.IsNotExist(err) {
+		return Index{}, nil
+	}
+	if err != nil {
+		return Index{}, err
+	}
+
+	var index Index
+	err = json.Unmarshal(b, &index)
+	if err != nil {
+		return Index{}, fmt.Errorf("failed to parse index: %s", err)
+	}
+
+	return index, nil
+}
+
+func (i Index) write(dir string) error {
+	b, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling a known-good body
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".index.json.tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(append(b, '\n'))
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), indexPath(dir))
+}
+
+// withFile adds a file to the index, along with its product and release if
+// they are not already present.
+func (i Index) withFile(product pivnet.Product, release pivnet.Release, file IndexFile) Index {
+	p := 0
+	for p < len(i.Products) && i.Products[p].Product.Slug != product.Slug {
+		p++
+	}
+	if p == len(i.Products) {
+		i.Products = append(i.Products, IndexProduct{Product: product})
+	}
+
+	releases := i.Products[p].Releases
+	r := 0
+	for r < len(releases) && releases[r].Release.ID != release.ID {
+		r++
+	}
+	if r == len(releases) {
+		releases = append(releases, IndexRelease{Release: release})
+	}
+
+	releases[r].Files = append(releases[r].Files, file)
+	i.Products[p].Releases = releases
+
+	return i
+}
+
+func indexPath(dir string) string {
+	return filepath.Join(dir, IndexFileName)
+}
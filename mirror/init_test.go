@@ -0,0 +1,17 @@
+package mirror_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+const (
+	apiPrefix = "/api/v2"
+)
+
+func TestMirror(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Mirror Suite")
+}
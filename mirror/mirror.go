@@ -0,0 +1,403 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const concurrentFiles = 4
+
+// Mirror keeps a local copy of selected products, releases and product
+// files. Each run downloads only the product files that are new or have
+// changed since the previous run, as recorded in the mirror's index.
+type Mirror struct {
+	client pivnet.Client
+	logger logger.Logger
+}
+
+// Report describes the outcome of a run. Paths are relative to the root of
+// the mirror.
+type Report struct {
+	Downloaded []string
+	Unchanged  []string
+	Pruned     []string
+	Failed     []FailedFile
+}
+
+type FailedFile struct {
+	Path string
+	Err  error
+}
+
+func New(client pivnet.Client, logger logger.Logger) Mirror {
+	return Mirror{
+		client: client,
+		logger: logger,
+	}
+}
+
+type fileKey struct {
+	productSlug   string
+	releaseID     int
+	productFileID int
+}
+
+// fileRef locates a file in the index being built.
+type fileRef struct {
+	product int
+	release int
+	file    int
+}
+
+// Run brings the mirror up to date with the config, writing the index even
+// if some product files fail to download. An error is returned if any of
+// them fail.
+func (m Mirror) Run(config Config) (Report, error) {
+	selectors, err := config.selectors()
+	if err != nil {
+		return Report{}, err
+	}
+
+	err = os.MkdirAll(config.Dir, 0755)
+	if err != nil {
+		return Report{}, err
+	}
+
+	previous, err := ReadIndex(config.Dir)
+	if err != nil {
+		return Report{}, err
+	}
+
+	previousFiles := make(map[fileKey]IndexFile)
+	for _, product := range previous.Products {
+		for _, release := range product.Releases {
+			for _, file := range release.Files {
+				previousFiles[fileKey{product.Product.Slug, release.Release.ID, file.ProductFile.ID}] = file
+			}
+		}
+	}
+
+	var report Report
+	var index Index
+	var downloads []fileRef
+
+	for p, s := range selectors {
+		product, err := m.client.Products.Get(s.slug)
+		if err != nil {
+			return Report{}, err
+		}
+
+		releases, err := m.client.Releases.List(s.slug)
+		if err != nil {
+			return Report{}, err
+		}
+
+		index.Products = append(index.Products, IndexProduct{Product: product})
+
+		for _, release := range releases {
+			if !s.constraint.Matches(release.Version) {
+				continue
+			}
+
+			files, err := m.releaseFiles(s, release)
+			if err != nil {
+				return Report{}, err
+			}
+
+			r := len(index.Products[p].Releases)
+			for f, file := range files {
+				key := fileKey{s.slug, release.ID, file.ProductFile.ID}
+
+				if previousFile, ok := previousFiles[key]; ok && m.unchanged(config.Dir, previousFile, file) {
+					files[f] = previousFile
+					files[f].ProductFile = file.ProductFile
+					files[f].FileGroups = file.FileGroups
+					report.Unchanged = append(report.Unchanged, file.Path)
+					continue
+				}
+
+				downloads = append(downloads, fileRef{product: p, release: r, file: f})
+			}
+
+			index.Products[p].Releases = append(index.Products[p].Releases, IndexRelease{
+				Release: release,
+				Files:   files,
+			})
+		}
+	}
+
+	failed := make(map[fileRef]bool)
+	for i, err := range m.download(config, index, downloads) {
+		ref := downloads[i]
+		product := index.Products[ref.product]
+		release := product.Releases[ref.release]
+		file := &release.Files[ref.file]
+
+		if err != nil {
+			report.Failed = append(report.Failed, FailedFile{Path: file.Path, Err: err})
+
+			// The previous copy, if any, is left in place by a failed
+			// download, so it remains in the index.
+			previousFile, ok := previousFiles[fileKey{product.Product.Slug, release.Release.ID, file.ProductFile.ID}]
+			if ok && previousFile.Path == file.Path {
+				*file = previousFile
+			} else {
+				failed[ref] = true
+			}
+			continue
+		}
+
+		file.MirroredAt = time.Now().UTC()
+		report.Downloaded = append(report.Downloaded, file.Path)
+	}
+
+	index = withoutFailed(index, failed)
+
+	index, report.Pruned, err = m.reconcile(config, index, previous)
+	if err != nil {
+		return report, err
+	}
+
+	err = index.write(config.Dir)
+	if err != nil {
+		return report, err
+	}
+
+	if len(report.Failed) > 0 {
+		return report, fmt.Errorf(
+			"%d of %d product files failed to download",
+			len(report.Failed),
+			len(downloads),
+		)
+	}
+
+	return report, nil
+}
+
+// releaseFiles lists the product files of a release selected by s,
+// including those that only belong to the release through a file group.
+func (m Mirror) releaseFiles(s selector, release pivnet.Release) ([]IndexFile, error) {
+	productFiles, err := m.client.ProductFiles.ListForRelease(s.slug, release.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileGroups, err := m.client.FileGroups.ListForRelease(s.slug, release.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[int][]string)
+	for _, fileGroup := range fileGroups {
+		for _, pf := range fileGroup.ProductFiles {
+			if _, ok := groups[pf.ID]; !ok {
+				productFiles = append(productFiles, pf)
+			}
+			groups[pf.ID] = append(groups[pf.ID], fileGroup.Name)
+		}
+	}
+
+	seen := make(map[int]bool)
+	var selected []pivnet.ProductFile
+	for _, pf := range productFiles {
+		if seen[pf.ID] || !s.matchesFile(pf) {
+			continue
+		}
+		seen[pf.ID] = true
+
+		selected = append(selected, pf)
+	}
+
+	var files []IndexFile
+	for i, name := range pivnet.UniqueFileNames(selected) {
+		files = append(files, IndexFile{
+			ProductFile: selected[i],
+			FileGroups:  groups[selected[i].ID],
+			Path:        path.Join(s.slug, releaseDir(release), name),
+		})
+	}
+
+	return files, nil
+}
+
+// unchanged reports whether the previously mirrored copy of a file is still
+// current and present on disk.
+func (m Mirror) unchanged(dir string, previous IndexFile, current IndexFile) bool {
+	if previous.Path != current.Path ||
+		previous.ProductFile.UpdatedAt != current.ProductFile.UpdatedAt ||
+		previous.ProductFile.MD5 != current.ProductFile.MD5 ||
+		previous.ProductFile.SHA256 != current.ProductFile.SHA256 {
+		return false
+	}
+
+	stat, err := os.Stat(filepath.Join(dir, filepath.FromSlash(previous.Path)))
+	if err != nil {
+		return false
+	}
+
+	size := current.ProductFile.Size
+	if size == 0 {
+		size = previous.ProductFile.Size
+	}
+
+	return size == 0 || stat.Size() == int64(size)
+}
+
+// download fetches the referenced files, several at a time, returning the
+// error for each in the same order as the references.
+func (m Mirror) download(config Config, index Index, refs []fileRef) []error {
+	progressWriter := config.ProgressWriter
+	if progressWriter == nil {
+		progressWriter = ioutil.Discard
+	}
+
+	concurrency := config.ConcurrentFiles
+	if concurrency < 1 {
+		concurrency = concurrentFiles
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	errs := make([]error, len(refs))
+
+	var wg sync.WaitGroup
+	for i, ref := range refs {
+		i := i
+		product := index.Products[ref.product]
+		release := product.Releases[ref.release]
+		file := release.Files[ref.file]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			filePath := filepath.Join(config.Dir, filepath.FromSlash(file.Path))
+
+			m.logger.Info("Mirroring product file", logger.Data{"path": file.Path})
+
+			err := os.MkdirAll(filepath.Dir(filePath), 0755)
+			if err == nil {
+				err = m.client.ProductFiles.DownloadForReleaseToPath(
+					context.Background(),
+					filePath,
+					product.Product.Slug,
+					release.Release.ID,
+					file.ProductFile.ID,
+					progressWriter,
+				)
+			}
+
+			if err != nil {
+				m.logger.Info("Failed to mirror product file", logger.Data{"path": file.Path, "error": err.Error()})
+			}
+
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// reconcile deals with the files in the previous index that are no longer
+// selected, deleting them when pruning and otherwise keeping them in the
+// index. A path that a selected file now occupies is left alone, as it
+// holds the selected file rather than the stale one.
+func (m Mirror) reconcile(config Config, index Index, previous Index) (Index, []string, error) {
+	current := make(map[fileKey]bool)
+	currentPaths := make(map[string]bool)
+	for _, product := range index.Products {
+		for _, release := range product.Releases {
+			for _, file := range release.Files {
+				current[fileKey{product.Product.Slug, release.Release.ID, file.ProductFile.ID}] = true
+				currentPaths[file.Path] = true
+			}
+		}
+	}
+
+	var pruned []string
+	for _, product := range previous.Products {
+		for _, release := range product.Releases {
+			for _, file := range release.Files {
+				if current[fileKey{product.Product.Slug, release.Release.ID, file.ProductFile.ID}] || currentPaths[file.Path] {
+					continue
+				}
+
+				if !config.Prune {
+					index = index.withFile(product.Product, release.Release, file)
+					continue
+				}
+
+				err := m.remove(config.Dir, file.Path)
+				if err != nil {
+					return Index{}, nil, err
+				}
+
+				pruned = append(pruned, file.Path)
+			}
+		}
+	}
+
+	return index, pruned, nil
+}
+
+// remove deletes a mirrored file along with any directories left empty.
+func (m Mirror) remove(dir string, relativePath string) error {
+	m.logger.Info("Pruning product file", logger.Data{"path": relativePath})
+
+	err := os.Remove(filepath.Join(dir, filepath.FromSlash(relativePath)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for parent := path.Dir(relativePath); parent != "." && parent != "/"; parent = path.Dir(parent) {
+		// Removing a directory that is not empty fails, which is expected
+		if os.Remove(filepath.Join(dir, filepath.FromSlash(parent))) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+func withoutFailed(index Index, failed map[fileRef]bool) Index {
+	if len(failed) == 0 {
+		return index
+	}
+
+	for p := range index.Products {
+		for r := range index.Products[p].Releases {
+			release := &index.Products[p].Releases[r]
+
+			var files []IndexFile
+			for f, file := range release.Files {
+				if !failed[fileRef{product: p, release: r, file: f}] {
+					files = append(files, file)
+				}
+			}
+			release.Files = files
+		}
+	}
+
+	return index
+}
+
+func releaseDir(release pivnet.Release) string {
+	dir := pivnet.SafePathComponent(release.Version)
+	if dir == "" {
+		return fmt.Sprintf("release-%d", release.ID)
+	}
+
+	return dir
+}
@@ -0,0 +1,435 @@
+package mirror_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+	"github.com/pivotal-cf/go-pivnet/mirror"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mirror", func() {
+	const productSlug = "some-product"
+
+	var (
+		server     *ghttp.Server
+		cloudfront *ghttp.Server
+		m          mirror.Mirror
+
+		dir    string
+		config mirror.Config
+
+		releases     []pivnet.Release
+		productFiles map[int][]pivnet.ProductFile
+		fileGroups   map[int][]pivnet.FileGroup
+		contents     map[string][]byte
+
+		mutex     *sync.Mutex
+		downloads map[string]int
+	)
+
+	productFile := func(id int, name string, updatedAt string) pivnet.ProductFile {
+		return pivnet.ProductFile{
+			ID:           id,
+			Name:         fmt.Sprintf("Product File %d", id),
+			AWSObjectKey: "product-files/" + productSlug + "/" + name,
+			UpdatedAt:    updatedAt,
+			Size:         len(contents[name]),
+		}
+	}
+
+	routeProductFile := func(releaseID int, pf pivnet.ProductFile) {
+		name := filepath.Base(pf.AWSObjectKey)
+		content := contents[name]
+
+		withLinks := pf
+		withLinks.Links = &pivnet.Links{
+			Download: map[string]string{
+				"href": fmt.Sprintf("%s%s/products/%s/product_files/%d/download", server.URL(), apiPrefix, productSlug, pf.ID),
+			},
+		}
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, releaseID, pf.ID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: withLinks}),
+		)
+
+		server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/product_files/%d/download", apiPrefix, productSlug, pf.ID),
+			ghttp.RespondWith(http.StatusFound, []byte(`{}`), http.Header{
+				"Location": []string{cloudfront.URL() + "/" + name},
+			}),
+		)
+
+		cloudfront.RouteToHandler("HEAD", "/"+name,
+			ghttp.RespondWith(http.StatusOK, nil, http.Header{
+				"Content-Length": []string{strconv.Itoa(len(content))},
+			}),
+		)
+
+		cloudfront.RouteToHandler("GET", "/"+name, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mutex.Lock()
+			downloads[name]++
+			mutex.Unlock()
+
+			if content == nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			matches := regexp.MustCompile(`bytes=(\d+)-(\d+)`).FindStringSubmatch(req.Header.Get("Range"))
+
+			start, err := strconv.Atoi(matches[1])
+			Expect(err).NotTo(HaveOccurred())
+
+			end, err := strconv.Atoi(matches[2])
+			Expect(err).NotTo(HaveOccurred())
+
+			w.WriteHeader(http.StatusPartialContent)
+			_, err = w.Write(content[start : end+1])
+			Expect(err).NotTo(HaveOccurred())
+		}))
+	}
+
+	route := func() {
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.Product{ID: 1, Slug: productSlug, Name: "Some Product"}),
+		)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: releases}),
+		)
+
+		for _, release := range releases {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, release.ID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: productFiles[release.ID]}),
+			)
+
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/file_groups", apiPrefix, productSlug, release.ID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{FileGroups: fileGroups[release.ID]}),
+			)
+
+			for _, pf := range productFiles[release.ID] {
+				routeProductFile(release.ID, pf)
+			}
+
+			for _, fileGroup := range fileGroups[release.ID] {
+				for _, pf := range fileGroup.ProductFiles {
+					routeProductFile(release.ID, pf)
+				}
+			}
+		}
+	}
+
+	indexedPaths := func() []string {
+		index, err := mirror.ReadIndex(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		var paths []string
+		for _, product := range index.Products {
+			for _, release := range product.Releases {
+				for _, file := range release.Files {
+					paths = append(paths, file.Path)
+				}
+			}
+		}
+
+		return paths
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		cloudfront = ghttp.NewServer()
+
+		fakeLogger := &loggerfakes.FakeLogger{}
+		client := pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, fakeLogger)
+
+		m = mirror.New(client, fakeLogger)
+
+		var err error
+		dir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		config = mirror.Config{
+			Dir: dir,
+			Products: []mirror.ProductConfig{
+				{
+					Slug:     productSlug,
+					Versions: ">=1.10.0",
+					Files:    []string{"*.pivotal"},
+				},
+			},
+		}
+
+		contents = map[string][]byte{
+			"product-1.9.0.pivotal":  []byte("old tile"),
+			"product-1.10.0.pivotal": []byte("some tile"),
+			"cli-1.10.0":             []byte("some cli"),
+			"stemcell-3421.pivotal":  []byte("some stemcell"),
+			"product-1.11.0.pivotal": []byte("newer tile"),
+		}
+
+		releases = []pivnet.Release{
+			{ID: 1, Version: "1.9.0"},
+			{ID: 2, Version: "1.10.0"},
+			{ID: 3, Version: "1.11.0"},
+		}
+
+		productFiles = map[int][]pivnet.ProductFile{
+			1: {productFile(10, "product-1.9.0.pivotal", "2017-01-01")},
+			2: {
+				productFile(20, "product-1.10.0.pivotal", "2017-02-01"),
+				productFile(21, "cli-1.10.0", "2017-02-01"),
+			},
+			3: {productFile(30, "product-1.11.0.pivotal", "2017-03-01")},
+		}
+
+		fileGroups = map[int][]pivnet.FileGroup{
+			2: {
+				{
+					ID:           200,
+					Name:         "Stemcells",
+					ProductFiles: []pivnet.ProductFile{productFile(22, "stemcell-3421.pivotal", "2017-02-01")},
+				},
+			},
+		}
+
+		mutex = &sync.Mutex{}
+		downloads = make(map[string]int)
+	})
+
+	JustBeforeEach(func() {
+		route()
+	})
+
+	AfterEach(func() {
+		server.Close()
+		cloudfront.Close()
+
+		err := os.RemoveAll(dir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("downloads the selected product files and indexes them", func() {
+		report, err := m.Run(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.Downloaded).To(ConsistOf(
+			"some-product/1.10.0/product-1.10.0.pivotal",
+			"some-product/1.10.0/stemcell-3421.pivotal",
+			"some-product/1.11.0/product-1.11.0.pivotal",
+		))
+		Expect(report.Unchanged).To(BeEmpty())
+
+		for _, relativePath := range report.Downloaded {
+			content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(relativePath)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(content).To(Equal(contents[filepath.Base(relativePath)]))
+		}
+
+		index, err := mirror.ReadIndex(dir)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(index.Products).To(HaveLen(1))
+		Expect(index.Products[0].Product.Name).To(Equal("Some Product"))
+		Expect(index.Products[0].Releases).To(HaveLen(2))
+
+		release := index.Products[0].Releases[0]
+		Expect(release.Release.Version).To(Equal("1.10.0"))
+		Expect(release.Files).To(HaveLen(2))
+		Expect(release.Files[1].ProductFile.ID).To(Equal(22))
+		Expect(release.Files[1].FileGroups).To(Equal([]string{"Stemcells"}))
+		Expect(release.Files[1].MirroredAt).NotTo(BeZero())
+	})
+
+	Context("when the mirror is up to date", func() {
+		JustBeforeEach(func() {
+			_, err := m.Run(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			downloads = make(map[string]int)
+		})
+
+		It("downloads nothing", func() {
+			report, err := m.Run(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Downloaded).To(BeEmpty())
+			Expect(report.Unchanged).To(HaveLen(3))
+			Expect(downloads).To(BeEmpty())
+		})
+
+		It("downloads product files that have changed", func() {
+			contents["product-1.11.0.pivotal"] = []byte("updated tile")
+			productFiles[3] = []pivnet.ProductFile{productFile(30, "product-1.11.0.pivotal", "2017-04-01")}
+			route()
+
+			report, err := m.Run(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Downloaded).To(Equal([]string{"some-product/1.11.0/product-1.11.0.pivotal"}))
+
+			content, err := ioutil.ReadFile(filepath.Join(dir, "some-product", "1.11.0", "product-1.11.0.pivotal"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(content).To(Equal([]byte("updated tile")))
+		})
+
+		It("downloads product files that are missing locally", func() {
+			err := os.Remove(filepath.Join(dir, "some-product", "1.10.0", "product-1.10.0.pivotal"))
+			Expect(err).NotTo(HaveOccurred())
+
+			report, err := m.Run(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Downloaded).To(Equal([]string{"some-product/1.10.0/product-1.10.0.pivotal"}))
+		})
+
+		Context("when a release drops out of the constraints", func() {
+			JustBeforeEach(func() {
+				config.Products[0].Versions = ">=1.11.0"
+			})
+
+			It("keeps the release in the index", func() {
+				report, err := m.Run(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(report.Pruned).To(BeEmpty())
+				Expect(indexedPaths()).To(HaveLen(3))
+			})
+
+			Context("when pruning", func() {
+				BeforeEach(func() {
+					config.Prune = true
+				})
+
+				It("removes the release from disk and from the index", func() {
+					report, err := m.Run(config)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(report.Pruned).To(ConsistOf(
+						"some-product/1.10.0/product-1.10.0.pivotal",
+						"some-product/1.10.0/stemcell-3421.pivotal",
+					))
+					Expect(indexedPaths()).To(Equal([]string{"some-product/1.11.0/product-1.11.0.pivotal"}))
+
+					_, err = os.Stat(filepath.Join(dir, "some-product", "1.10.0"))
+					Expect(os.IsNotExist(err)).To(BeTrue())
+
+					_, err = os.Stat(filepath.Join(dir, "some-product", "1.11.0", "product-1.11.0.pivotal"))
+					Expect(err).NotTo(HaveOccurred())
+				})
+			})
+		})
+	})
+
+	Context("when a product file is replaced in a release", func() {
+		JustBeforeEach(func() {
+			_, err := m.Run(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			contents["product-1.11.0.pivotal"] = []byte("replacement tile")
+			productFiles[3] = []pivnet.ProductFile{productFile(31, "product-1.11.0.pivotal", "2017-04-01")}
+			route()
+		})
+
+		indexedIDs := func(relativePath string) []int {
+			index, err := mirror.ReadIndex(dir)
+			Expect(err).NotTo(HaveOccurred())
+
+			var ids []int
+			for _, product := range index.Products {
+				for _, release := range product.Releases {
+					for _, file := range release.Files {
+						if file.Path == relativePath {
+							ids = append(ids, file.ProductFile.ID)
+						}
+					}
+				}
+			}
+
+			return ids
+		}
+
+		It("indexes only the replacement", func() {
+			report, err := m.Run(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Downloaded).To(Equal([]string{"some-product/1.11.0/product-1.11.0.pivotal"}))
+			Expect(indexedIDs("some-product/1.11.0/product-1.11.0.pivotal")).To(Equal([]int{31}))
+		})
+
+		Context("when pruning", func() {
+			BeforeEach(func() {
+				config.Prune = true
+			})
+
+			It("keeps the replacement on disk and in the index", func() {
+				report, err := m.Run(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(report.Pruned).To(BeEmpty())
+				Expect(indexedIDs("some-product/1.11.0/product-1.11.0.pivotal")).To(Equal([]int{31}))
+
+				content, err := ioutil.ReadFile(filepath.Join(dir, "some-product", "1.11.0", "product-1.11.0.pivotal"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(content).To(Equal([]byte("replacement tile")))
+			})
+		})
+	})
+
+	Context("when a product file fails to download", func() {
+		BeforeEach(func() {
+			contents["stemcell-3421.pivotal"] = nil
+		})
+
+		It("reports the failure and indexes the rest", func() {
+			report, err := m.Run(config)
+			Expect(err).To(MatchError("1 of 3 product files failed to download"))
+
+			Expect(report.Failed).To(HaveLen(1))
+			Expect(report.Failed[0].Path).To(Equal("some-product/1.10.0/stemcell-3421.pivotal"))
+
+			Expect(indexedPaths()).To(ConsistOf(
+				"some-product/1.10.0/product-1.10.0.pivotal",
+				"some-product/1.11.0/product-1.11.0.pivotal",
+			))
+		})
+	})
+
+	Context("when the config is invalid", func() {
+		It("returns an error without contacting the server", func() {
+			config.Products[0].Versions = ">="
+
+			_, err := m.Run(config)
+			Expect(err).To(MatchError(ContainSubstring("invalid version constraint")))
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when listing releases fails", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases", apiPrefix, productSlug),
+				ghttp.RespondWith(http.StatusTeapot, `{"message":"foo message"}`),
+			)
+		})
+
+		It("returns the error", func() {
+			_, err := m.Run(config)
+			Expect(err).To(MatchError(ContainSubstring("foo message")))
+		})
+	})
+})
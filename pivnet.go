@@ -12,8 +12,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pivotal-cf/go-pivnet/cache"
 	"github.com/pivotal-cf/go-pivnet/download"
 	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/upload"
 )
 
 const (
@@ -30,7 +32,14 @@ type Client struct {
 
 	HTTP *http.Client
 
-	downloader download.Client
+	downloader          download.Client
+	downloadThrottle    *download.Throttle
+	newProgressListener func(progressWriter io.Writer) download.ProgressListener
+	downloadCache       *cache.Cache
+	autoAcceptEULASlugs map[string]bool
+	newObjectStore      func(token FederationToken) upload.ObjectStore
+	uploadPartSize      int64
+	uploadConcurrency   int
 
 	Auth                 *AuthService
 	EULA                 *EULAsService
@@ -43,6 +52,7 @@ type Client struct {
 	DependencySpecifiers *DependencySpecifiersService
 	ReleaseTypes         *ReleaseTypesService
 	ReleaseUpgradePaths  *ReleaseUpgradePathsService
+	FederationToken      *FederationTokenService
 }
 
 type ClientConfig struct {
@@ -50,6 +60,61 @@ type ClientConfig struct {
 	Token             string
 	UserAgent         string
 	SkipSSLValidation bool
+
+	// DownloadConcurrency is the number of byte ranges of a single file
+	// fetched in parallel. Defaults to 10 when unset.
+	DownloadConcurrency int
+
+	// DownloadMinChunkSize and DownloadMaxChunkSize bound the size in bytes
+	// of each byte range. Zero leaves the corresponding bound unset.
+	DownloadMinChunkSize int64
+	DownloadMaxChunkSize int64
+
+	// DownloadBytesPerSecond caps the combined bandwidth of all downloads
+	// made through the client. Zero means no cap.
+	DownloadBytesPerSecond int64
+
+	// DownloadMaxRetries is the number of times a byte range is retried
+	// after a transient failure. Defaults to 5 when unset.
+	DownloadMaxRetries int
+
+	// DownloadRetryBackoff is the delay before the first retry of a byte
+	// range, doubling on each subsequent retry. Defaults to one second.
+	DownloadRetryBackoff time.Duration
+
+	// NewProgressListener builds the listener that receives progress events
+	// for each download, given the progress writer passed to the download.
+	// Defaults to a progress bar rendered to the progress writer.
+	NewProgressListener func(progressWriter io.Writer) download.ProgressListener
+
+	// DownloadCacheDir, when set, is a directory of previously downloaded
+	// product files, keyed by checksum, that are reused instead of being
+	// downloaded again. It may be shared between processes.
+	DownloadCacheDir string
+
+	// DownloadCacheMaxBytes is the size beyond which the least recently used
+	// files are evicted from the download cache. Zero disables eviction.
+	DownloadCacheMaxBytes int64
+
+	// AutoAcceptEULASlugs lists the EULAs that may be accepted on the
+	// user's behalf when a download is refused because the release EULA has
+	// not been accepted. The download is then retried once. EULAs are never
+	// accepted automatically when unset.
+	AutoAcceptEULASlugs []string
+
+	// UploadPartSize is the size in bytes of each part of a product file
+	// upload. Defaults to 64MB when unset.
+	UploadPartSize int64
+
+	// UploadConcurrency is the number of parts of a product file uploaded in
+	// parallel. Defaults to 4 when unset.
+	UploadConcurrency int
+
+	// NewObjectStore builds the object store product files are uploaded to,
+	// given the federation token for the product. Defaults to the S3 bucket
+	// named by the token. Override it to upload to an S3-compatible
+	// stand-in.
+	NewObjectStore func(token FederationToken) upload.ObjectStore
 }
 
 func NewClient(
@@ -68,20 +133,73 @@ func NewClient(
 		},
 	}
 
-	ranger := download.NewRanger(concurrentDownloads)
+	downloadConcurrency := config.DownloadConcurrency
+	if downloadConcurrency < 1 {
+		downloadConcurrency = concurrentDownloads
+	}
+
+	ranger := download.NewChunkedRanger(
+		downloadConcurrency,
+		config.DownloadMinChunkSize,
+		config.DownloadMaxChunkSize,
+	)
 	downloader := download.New(
 		http.DefaultClient,
 		ranger,
-		download.NewBar(),
 	)
+	downloader.Concurrency = downloadConcurrency
+
+	if config.DownloadMaxRetries > 0 {
+		downloader.MaxRetries = config.DownloadMaxRetries
+	}
+
+	if config.DownloadRetryBackoff > 0 {
+		downloader.RetryBackoff = config.DownloadRetryBackoff
+	}
+
+	downloadThrottle := download.NewThrottle(config.DownloadBytesPerSecond)
+	downloader.Throttle = downloadThrottle
+
+	newProgressListener := config.NewProgressListener
+	if newProgressListener == nil {
+		newProgressListener = func(progressWriter io.Writer) download.ProgressListener {
+			return download.NewBarListener(progressWriter)
+		}
+	}
+
+	var downloadCache *cache.Cache
+	if config.DownloadCacheDir != "" {
+		c := cache.New(config.DownloadCacheDir, config.DownloadCacheMaxBytes)
+		downloadCache = &c
+	}
+
+	var autoAcceptEULASlugs map[string]bool
+	if len(config.AutoAcceptEULASlugs) > 0 {
+		autoAcceptEULASlugs = make(map[string]bool)
+		for _, slug := range config.AutoAcceptEULASlugs {
+			autoAcceptEULASlugs[slug] = true
+		}
+	}
+
+	newObjectStore := config.NewObjectStore
+	if newObjectStore == nil {
+		newObjectStore = newS3ObjectStore
+	}
 
 	client := Client{
-		baseURL:    baseURL,
-		token:      config.Token,
-		userAgent:  config.UserAgent,
-		logger:     logger,
-		downloader: downloader,
-		HTTP:       httpClient,
+		baseURL:             baseURL,
+		token:               config.Token,
+		userAgent:           config.UserAgent,
+		logger:              logger,
+		downloader:          downloader,
+		downloadThrottle:    downloadThrottle,
+		newProgressListener: newProgressListener,
+		downloadCache:       downloadCache,
+		autoAcceptEULASlugs: autoAcceptEULASlugs,
+		newObjectStore:      newObjectStore,
+		uploadPartSize:      config.UploadPartSize,
+		uploadConcurrency:   config.UploadConcurrency,
+		HTTP:                httpClient,
 	}
 
 	client.Auth = &AuthService{client: client}
@@ -93,12 +211,20 @@ func NewClient(
 	client.UserGroups = &UserGroupsService{client: client}
 	client.ReleaseDependencies = &ReleaseDependenciesService{client: client}
 	client.DependencySpecifiers = &DependencySpecifiersService{client: client}
-	client.ReleaseTypes = &ReleaseTypesService{client: client}
+	client.ReleaseTypes = &ReleaseTypesService{client: client, cache: &releaseTypesCache{}}
 	client.ReleaseUpgradePaths = &ReleaseUpgradePathsService{client: client}
+	client.FederationToken = &FederationTokenService{client: client}
 
 	return client
 }
 
+// SetDownloadBytesPerSecond changes the bandwidth cap shared by all downloads
+// made through the client, including those already in progress.
+// Zero removes the cap.
+func (c Client) SetDownloadBytesPerSecond(bytesPerSecond int64) {
+	c.downloadThrottle.SetLimit(bytesPerSecond)
+}
+
 func (c Client) CreateRequest(
 	requestType string,
 	endpoint string,
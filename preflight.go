@@ -0,0 +1,83 @@
+package pivnet
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pivotal-cf/go-pivnet/download"
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+// preflight checks, before any bytes are fetched, that the content length
+// reported by the download server matches the product file's size and that
+// the destination has room for the download.
+func (p ProductFilesService) preflight(pf ProductFile, location *os.File, contentLength int64) error {
+	if pf.Size > 0 && contentLength >= 0 && int64(pf.Size) != contentLength {
+		return ErrSizeMismatch{
+			ProductFileID: pf.ID,
+			ExpectedBytes: int64(pf.Size),
+			ContentLength: contentLength,
+		}
+	}
+
+	required := contentLength
+	if required < 0 {
+		required = int64(pf.Size)
+	}
+
+	// Bytes already in the destination are overwritten rather than added
+	// to, so they do not need any more free space
+	stat, err := location.Stat()
+	if err != nil {
+		return err
+	}
+	required -= stat.Size()
+
+	// Free space is looked up for a directory, as some platforms do not
+	// accept a file path
+	return p.client.checkFreeSpace(filepath.Dir(location.Name()), required)
+}
+
+// preflightJobs checks that the destination directory has room for every
+// job whose product file size is known.
+func (p ProductFilesService) preflightJobs(destinationDir string, jobs []downloadJob) error {
+	var required int64
+	for _, job := range jobs {
+		required += int64(job.productFile.Size)
+	}
+
+	if required == 0 {
+		return nil
+	}
+
+	err := os.MkdirAll(destinationDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	return p.client.checkFreeSpace(destinationDir, required)
+}
+
+func (c Client) checkFreeSpace(path string, required int64) error {
+	if required <= 0 {
+		return nil
+	}
+
+	available, err := download.FreeSpace(path)
+	if err != nil {
+		// Not every filesystem reports its free space, so the check is
+		// best effort.
+		c.logger.Debug("Unable to determine free space", logger.Data{"path": path, "error": err.Error()})
+		return nil
+	}
+
+	if required > available {
+		return ErrInsufficientSpace{
+			Path:           path,
+			RequiredBytes:  required,
+			AvailableBytes: available,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package pivnet
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// documentationExtensions are the extensions of files given the
+// Documentation file type.
+var documentationExtensions = map[string]bool{
+	".doc":  true,
+	".docx": true,
+	".htm":  true,
+	".html": true,
+	".md":   true,
+	".pdf":  true,
+	".rtf":  true,
+	".txt":  true,
+}
+
+type LocalProductFileConfig struct {
+	ProductSlug string
+
+	// ReleaseID, when set, is the release whose version FileVersion defaults
+	// to.
+	ReleaseID int
+
+	FilePath string
+
+	// AWSObjectKeyPrefix is joined with the base name of FilePath to form
+	// the AWS object key, e.g. "product-files/some-product".
+	AWSObjectKeyPrefix string
+
+	// Overrides takes precedence over the derived value of each of its
+	// fields that is set.
+	Overrides CreateProductFileConfig
+}
+
+// ConfigFromLocalFile builds the config to create a product file for a local
+// file. The checksums are computed from the file, Name and the AWS object key
+// are derived from its base name and FileType is inferred from its name:
+// open source licenses by name, documentation by extension and everything
+// else as software.
+func (p ProductFilesService) ConfigFromLocalFile(config LocalProductFileConfig) (CreateProductFileConfig, error) {
+	md5Sum, sha256Sum, err := fileChecksums(config.FilePath)
+	if err != nil {
+		return CreateProductFileConfig{}, err
+	}
+
+	base := filepath.Base(config.FilePath)
+
+	created := config.Overrides
+	created.ProductSlug = config.ProductSlug
+
+	if created.AWSObjectKey == "" {
+		created.AWSObjectKey = path.Join(config.AWSObjectKeyPrefix, base)
+	}
+
+	if created.Name == "" {
+		created.Name = base
+	}
+
+	if created.FileType == "" {
+		created.FileType = fileTypeForName(base)
+	}
+
+	if created.MD5 == "" {
+		created.MD5 = md5Sum
+	}
+
+	if created.SHA256 == "" {
+		created.SHA256 = sha256Sum
+	}
+
+	if created.FileVersion == "" && config.ReleaseID != 0 {
+		release, err := ReleasesService{client: p.client, l: p.client.logger}.Get(config.ProductSlug, config.ReleaseID)
+		if err != nil {
+			return CreateProductFileConfig{}, err
+		}
+
+		created.FileVersion = release.Version
+	}
+
+	return created, nil
+}
+
+func fileTypeForName(name string) FileType {
+	lower := strings.ToLower(name)
+
+	if strings.Contains(lower, "license") ||
+		strings.Contains(lower, "licence") ||
+		strings.HasPrefix(lower, "osl") {
+		return FileTypeOpenSourceLicense
+	}
+
+	if documentationExtensions[filepath.Ext(lower)] {
+		return FileTypeDocumentation
+	}
+
+	return FileTypeSoftware
+}
+
+// fileChecksums returns the hex encoded MD5 and SHA256 of a file, reading it
+// once.
+func fileChecksums(filePath string) (string, string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+
+	_, err = io.Copy(io.MultiWriter(md5Hash, sha256Hash), f)
+	if err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(md5Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
@@ -0,0 +1,159 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - product file config from a local file", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+
+		tempDir   string
+		releaseID int
+	)
+
+	writeFile := func(name string, content string) string {
+		filePath := filepath.Join(tempDir, name)
+		err := ioutil.WriteFile(filePath, []byte(content), 0644)
+		Expect(err).NotTo(HaveOccurred())
+
+		return filePath
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, &loggerfakes.FakeLogger{})
+
+		releaseID = 1234
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+
+		err := os.RemoveAll(tempDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("derives the config from the file and the release", func() {
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d", apiPrefix, productSlug, releaseID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.Release{ID: releaseID, Version: "1.2.3"}),
+		)
+
+		filePath := writeFile("product-1.2.3.pivotal", "some content")
+
+		config, err := client.ProductFiles.ConfigFromLocalFile(pivnet.LocalProductFileConfig{
+			ProductSlug:        productSlug,
+			ReleaseID:          releaseID,
+			FilePath:           filePath,
+			AWSObjectKeyPrefix: "product-files/some-product",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(config).To(Equal(pivnet.CreateProductFileConfig{
+			ProductSlug:  productSlug,
+			AWSObjectKey: "product-files/some-product/product-1.2.3.pivotal",
+			Name:         "product-1.2.3.pivotal",
+			FileType:     pivnet.FileTypeSoftware,
+			FileVersion:  "1.2.3",
+			MD5:          "9893532233caff98cd083a116b013c0b",
+			SHA256:       "290f493c44f5d63d06b374d0a5abd292fae38b92cab2fae5efefe1b0e9347f56",
+		}))
+	})
+
+	It("infers the file type from the file name", func() {
+		for name, fileType := range map[string]pivnet.FileType{
+			"LICENSE.txt":           pivnet.FileTypeOpenSourceLicense,
+			"open_source_licence":   pivnet.FileTypeOpenSourceLicense,
+			"osl-product-1.2.3.txt": pivnet.FileTypeOpenSourceLicense,
+			"release-notes.PDF":     pivnet.FileTypeDocumentation,
+			"README.md":             pivnet.FileTypeDocumentation,
+			"cli-linux-1.2.3":       pivnet.FileTypeSoftware,
+			"product-1.2.3.zip":     pivnet.FileTypeSoftware,
+		} {
+			config, err := client.ProductFiles.ConfigFromLocalFile(pivnet.LocalProductFileConfig{
+				ProductSlug: productSlug,
+				FilePath:    writeFile(name, "some content"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(config.FileType).To(Equal(fileType), name)
+			Expect(config.AWSObjectKey).To(Equal(name))
+		}
+
+		Expect(server.ReceivedRequests()).To(BeEmpty())
+	})
+
+	Context("when overrides are given", func() {
+		It("uses them in place of the derived values without getting the release", func() {
+			config, err := client.ProductFiles.ConfigFromLocalFile(pivnet.LocalProductFileConfig{
+				ProductSlug:        productSlug,
+				ReleaseID:          releaseID,
+				FilePath:           writeFile("product-1.2.3.pivotal", "some content"),
+				AWSObjectKeyPrefix: "product-files/some-product",
+				Overrides: pivnet.CreateProductFileConfig{
+					Name:        "Some Product",
+					FileType:    pivnet.FileTypeDocumentation,
+					FileVersion: "1.2",
+					Description: "some description",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(config.Name).To(Equal("Some Product"))
+			Expect(config.FileType).To(Equal(pivnet.FileTypeDocumentation))
+			Expect(config.FileVersion).To(Equal("1.2"))
+			Expect(config.Description).To(Equal("some description"))
+			Expect(config.AWSObjectKey).To(Equal("product-files/some-product/product-1.2.3.pivotal"))
+			Expect(config.MD5).To(Equal("9893532233caff98cd083a116b013c0b"))
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when the file does not exist", func() {
+		It("returns an error", func() {
+			_, err := client.ProductFiles.ConfigFromLocalFile(pivnet.LocalProductFileConfig{
+				ProductSlug: productSlug,
+				FilePath:    filepath.Join(tempDir, "missing"),
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when getting the release fails", func() {
+		BeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+			)
+		})
+
+		It("forwards the error", func() {
+			_, err := client.ProductFiles.ConfigFromLocalFile(pivnet.LocalProductFileConfig{
+				ProductSlug: productSlug,
+				ReleaseID:   releaseID,
+				FilePath:    writeFile("product-1.2.3.pivotal", "some content"),
+			})
+			Expect(err).To(MatchError(ContainSubstring("foo message")))
+		})
+	})
+})
@@ -0,0 +1,178 @@
+package pivnet
+
+import (
+	"fmt"
+)
+
+// CreateOrGetOutcome says what CreateOrGet did to arrive at its product file.
+type CreateOrGetOutcome string
+
+const (
+	// ProductFileCreated means no existing product file matched, so one was
+	// created.
+	ProductFileCreated CreateOrGetOutcome = "created"
+
+	// ProductFileUpdated means an existing product file matched but its
+	// metadata differed from the config, so it was updated.
+	ProductFileUpdated CreateOrGetOutcome = "updated"
+
+	// ProductFileExisting means an existing product file matched the config
+	// and was returned unchanged.
+	ProductFileExisting CreateOrGetOutcome = "existing"
+)
+
+type CreateOrGetProductFileConfig struct {
+	CreateProductFileConfig
+
+	// MatchMD5 also requires an existing product file to have the MD5 of the
+	// config to match. A product file at the same object key with a
+	// different MD5 is then left alone and a new one is created.
+	MatchMD5 bool
+}
+
+type CreateOrGetProductFileResult struct {
+	ProductFile ProductFile
+	Outcome     CreateOrGetOutcome
+
+	// UpdatedFields lists the fields changed when the outcome is
+	// ProductFileUpdated.
+	UpdatedFields []ProductFileField
+}
+
+// CreateOrGet creates a product file unless the product already has one for
+// the same AWS object key, so that it is safe to retry. A matching product
+// file whose metadata differs from the config is updated to agree with it;
+// only the fields set on the config are compared. When several product
+// files match, the oldest is used.
+func (p ProductFilesService) CreateOrGet(config CreateOrGetProductFileConfig) (CreateOrGetProductFileResult, error) {
+	if config.AWSObjectKey == "" {
+		return CreateOrGetProductFileResult{}, fmt.Errorf("AWS object key must be set to find an existing product file")
+	}
+
+	if config.MatchMD5 && config.MD5 == "" {
+		return CreateOrGetProductFileResult{}, fmt.Errorf("MD5 must be set to match existing product files by MD5")
+	}
+
+	productFiles, err := p.List(config.ProductSlug)
+	if err != nil {
+		return CreateOrGetProductFileResult{}, err
+	}
+
+	md5 := ""
+	if config.MatchMD5 {
+		md5 = config.MD5
+	}
+
+	match := findProductFile(productFiles, config.AWSObjectKey, md5)
+	if match == nil {
+		created, err := p.Create(config.CreateProductFileConfig)
+		if err != nil {
+			return CreateOrGetProductFileResult{}, err
+		}
+
+		return CreateOrGetProductFileResult{
+			ProductFile: created,
+			Outcome:     ProductFileCreated,
+		}, nil
+	}
+
+	// Listing may not return every field, so compare against the full record
+	existing, err := p.Get(config.ProductSlug, match.ID)
+	if err != nil {
+		return CreateOrGetProductFileResult{}, err
+	}
+
+	desired, fields := productFileChanges(existing, config.CreateProductFileConfig)
+	if len(fields) == 0 {
+		return CreateOrGetProductFileResult{
+			ProductFile: existing,
+			Outcome:     ProductFileExisting,
+		}, nil
+	}
+
+	updated, err := p.UpdateFields(config.ProductSlug, desired, fields...)
+	if err != nil {
+		return CreateOrGetProductFileResult{}, err
+	}
+
+	return CreateOrGetProductFileResult{
+		ProductFile:   updated,
+		Outcome:       ProductFileUpdated,
+		UpdatedFields: fields,
+	}, nil
+}
+
+// findProductFile returns the oldest product file with the AWS object key,
+// and with the MD5 if it is not empty, or nil if there is none.
+func findProductFile(productFiles []ProductFile, awsObjectKey string, md5 string) *ProductFile {
+	var match *ProductFile
+	for i, pf := range productFiles {
+		if pf.AWSObjectKey != awsObjectKey {
+			continue
+		}
+
+		if md5 != "" && pf.MD5 != md5 {
+			continue
+		}
+
+		if match == nil || pf.ID < match.ID {
+			match = &productFiles[i]
+		}
+	}
+
+	return match
+}
+
+// productFileChanges returns existing with the fields set on config applied
+// and the fields whose values changed.
+func productFileChanges(existing ProductFile, config CreateProductFileConfig) (ProductFile, []ProductFileField) {
+	desired := existing
+	var fields []ProductFileField
+
+	setString := func(field ProductFileField, current *string, value string) {
+		if value != "" && *current != value {
+			*current = value
+			fields = append(fields, field)
+		}
+	}
+
+	setStrings := func(field ProductFileField, current *[]string, value []string) {
+		if len(value) > 0 && !stringsEqual(*current, value) {
+			*current = value
+			fields = append(fields, field)
+		}
+	}
+
+	setString(ProductFileFieldDescription, &desired.Description, config.Description)
+	setString(ProductFileFieldDocsURL, &desired.DocsURL, config.DocsURL)
+
+	if config.FileType != "" && desired.FileType != config.FileType {
+		desired.FileType = config.FileType
+		fields = append(fields, ProductFileFieldFileType)
+	}
+
+	setString(ProductFileFieldFileVersion, &desired.FileVersion, config.FileVersion)
+	setStrings(ProductFileFieldIncludedFiles, &desired.IncludedFiles, config.IncludedFiles)
+	setString(ProductFileFieldMD5, &desired.MD5, config.MD5)
+	setString(ProductFileFieldName, &desired.Name, config.Name)
+	setStrings(ProductFileFieldPlatforms, &desired.Platforms, config.Platforms)
+	setString(ProductFileFieldReleasedAt, &desired.ReleasedAt, config.ReleasedAt)
+	setString(ProductFileFieldSHA256, &desired.SHA256, config.SHA256)
+	setStrings(ProductFileFieldSystemRequirements, &desired.SystemRequirements, config.SystemRequirements)
+
+	return desired, fields
+}
+
+func stringsEqual(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,183 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - create or get product file", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+
+		existing []pivnet.ProductFile
+		config   pivnet.CreateOrGetProductFileConfig
+	)
+
+	productFilesPath := func() string {
+		return fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug)
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, &loggerfakes.FakeLogger{})
+
+		existing = []pivnet.ProductFile{
+			{ID: 20, AWSObjectKey: "product-files/some-product/other.zip"},
+			{ID: 12, AWSObjectKey: "product-files/some-product/product.pivotal", MD5: "some-md5", Name: "Product", FileVersion: "1.0"},
+			{ID: 10, AWSObjectKey: "product-files/some-product/product.pivotal", MD5: "old-md5", Name: "Product", FileVersion: "1.0"},
+		}
+
+		config = pivnet.CreateOrGetProductFileConfig{
+			CreateProductFileConfig: pivnet.CreateProductFileConfig{
+				ProductSlug:  productSlug,
+				AWSObjectKey: "product-files/some-product/product.pivotal",
+				MD5:          "some-md5",
+				Name:         "Product",
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET", productFilesPath(),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: existing}),
+		)
+
+		for _, pf := range existing {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/%d", productFilesPath(), pf.ID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: pf}),
+			)
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when a product file matches by object key", func() {
+		BeforeEach(func() {
+			config.MD5 = "old-md5"
+		})
+
+		It("returns the oldest match unchanged when its metadata agrees", func() {
+			result, err := client.ProductFiles.CreateOrGet(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Outcome).To(Equal(pivnet.ProductFileExisting))
+			Expect(result.ProductFile).To(Equal(existing[2]))
+			Expect(result.UpdatedFields).To(BeEmpty())
+		})
+	})
+
+	Context("when the matching product file has different metadata", func() {
+		BeforeEach(func() {
+			config.FileVersion = "1.1"
+			config.Platforms = []string{"Linux"}
+		})
+
+		It("updates the fields that differ", func() {
+			server.RouteToHandler("PATCH", fmt.Sprintf("%s/%d", productFilesPath(), 10),
+				ghttp.CombineHandlers(
+					ghttp.VerifyJSON(`{"product_file":{"file_version":"1.1","md5":"some-md5","platforms":["Linux"]}}`),
+					ghttp.RespondWith(http.StatusOK, `{"product_file":{"id":10,"file_version":"1.1"}}`),
+				),
+			)
+
+			result, err := client.ProductFiles.CreateOrGet(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Outcome).To(Equal(pivnet.ProductFileUpdated))
+			Expect(result.ProductFile).To(Equal(pivnet.ProductFile{ID: 10, FileVersion: "1.1"}))
+			Expect(result.UpdatedFields).To(Equal([]pivnet.ProductFileField{
+				pivnet.ProductFileFieldFileVersion,
+				pivnet.ProductFileFieldMD5,
+				pivnet.ProductFileFieldPlatforms,
+			}))
+		})
+	})
+
+	Context("when matching by MD5", func() {
+		BeforeEach(func() {
+			config.MatchMD5 = true
+		})
+
+		It("only matches product files with the same MD5", func() {
+			result, err := client.ProductFiles.CreateOrGet(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Outcome).To(Equal(pivnet.ProductFileExisting))
+			Expect(result.ProductFile.ID).To(Equal(12))
+		})
+
+		Context("when the MD5 is not set", func() {
+			BeforeEach(func() {
+				config.MD5 = ""
+			})
+
+			It("returns an error without making a request", func() {
+				_, err := client.ProductFiles.CreateOrGet(config)
+				Expect(err).To(MatchError("MD5 must be set to match existing product files by MD5"))
+
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when no product file matches", func() {
+		BeforeEach(func() {
+			config.AWSObjectKey = "product-files/some-product/new.pivotal"
+		})
+
+		It("creates the product file", func() {
+			server.RouteToHandler("POST", productFilesPath(),
+				ghttp.CombineHandlers(
+					ghttp.VerifyJSON(`{"product_file":{"aws_object_key":"product-files/some-product/new.pivotal","md5":"some-md5","name":"Product"}}`),
+					ghttp.RespondWith(http.StatusCreated, `{"product_file":{"id":30}}`),
+				),
+			)
+
+			result, err := client.ProductFiles.CreateOrGet(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result.Outcome).To(Equal(pivnet.ProductFileCreated))
+			Expect(result.ProductFile.ID).To(Equal(30))
+		})
+	})
+
+	Context("when the object key is not set", func() {
+		BeforeEach(func() {
+			config.AWSObjectKey = ""
+		})
+
+		It("returns an error without making a request", func() {
+			_, err := client.ProductFiles.CreateOrGet(config)
+			Expect(err).To(MatchError("AWS object key must be set to find an existing product file"))
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when listing the product files fails", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET", productFilesPath(),
+				ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+			)
+		})
+
+		It("forwards the error", func() {
+			_, err := client.ProductFiles.CreateOrGet(config)
+			Expect(err).To(MatchError(ContainSubstring("foo message")))
+		})
+	})
+})
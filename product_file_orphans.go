@@ -0,0 +1,200 @@
+package pivnet
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// OrphanedProductFile is a product file that belongs to no release and no
+// file group.
+type OrphanedProductFile struct {
+	ProductFile ProductFile
+
+	// Age is the time since the product file was last updated, or zero if
+	// that is not known.
+	Age time.Duration
+
+	// Size is in bytes, or zero if it is not known.
+	Size int
+}
+
+type DeleteOrphansConfig struct {
+	ProductSlug string
+
+	// MinAge spares orphans updated more recently, and orphans whose age is
+	// not known.
+	MinAge time.Duration
+
+	// Exclude are glob patterns, as understood by path.Match, matched
+	// against the product file name and the base name of its AWS object
+	// key. Matching orphans are spared.
+	Exclude []string
+
+	// ExcludeIDs are the IDs of orphans to spare.
+	ExcludeIDs []int
+
+	// MaxDeletes, when set, refuses to delete anything if more orphans than
+	// this would be deleted, guarding against a listing gone wrong.
+	MaxDeletes int
+
+	// DryRun reports what would be deleted without deleting it.
+	DryRun bool
+
+	// Concurrency is the number of releases scanned at once.
+	Concurrency int
+}
+
+type DeleteOrphansReport struct {
+	// Eligible are the orphans that were, or in a dry run would be, deleted.
+	Eligible []OrphanedProductFile
+
+	// Skipped are the orphans spared by the age threshold or exclusions.
+	Skipped []OrphanedProductFile
+
+	Deleted []OrphanedProductFile
+	Failed  []FailedOrphan
+}
+
+type FailedOrphan struct {
+	OrphanedProductFile
+	Err error
+}
+
+// FindOrphans returns the product files of a product that are not in any of
+// its releases or file groups, in order of ID. Releases are scanned without
+// the metadata cache, as stale metadata could make a product file in use
+// look orphaned.
+func (p ProductFilesService) FindOrphans(productSlug string) ([]OrphanedProductFile, error) {
+	return p.findOrphans(productSlug, 0)
+}
+
+func (p ProductFilesService) findOrphans(productSlug string, concurrency int) ([]OrphanedProductFile, error) {
+	productFiles, err := p.List(productSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := ReleasesService{client: p.client, l: p.client.logger}.List(productSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := p.scanReleases(ProductFileQuery{ProductSlug: productSlug, Concurrency: concurrency}, releases)
+	if err != nil {
+		return nil, err
+	}
+
+	// File groups that are in no release still hold their product files
+	fileGroups, err := FileGroupsService{client: p.client}.List(productSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[int]bool{}
+	for _, m := range metadata {
+		for _, pf := range m.ProductFiles {
+			used[pf.ID] = true
+		}
+
+		fileGroups = append(fileGroups, m.FileGroups...)
+	}
+
+	for _, fg := range fileGroups {
+		for _, pf := range fg.ProductFiles {
+			used[pf.ID] = true
+		}
+	}
+
+	now := time.Now()
+
+	var orphans []OrphanedProductFile
+	for _, pf := range productFiles {
+		if used[pf.ID] {
+			continue
+		}
+
+		orphan := OrphanedProductFile{
+			ProductFile: pf,
+			Size:        pf.Size,
+		}
+
+		updatedAt, err := time.Parse(time.RFC3339, pf.UpdatedAt)
+		if err == nil {
+			orphan.Age = now.Sub(updatedAt)
+		}
+
+		orphans = append(orphans, orphan)
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].ProductFile.ID < orphans[j].ProductFile.ID
+	})
+
+	return orphans, nil
+}
+
+// DeleteOrphans finds the orphaned product files of a product afresh and
+// deletes those not spared by the config, carrying on past failures. An
+// error is returned if any fail to delete.
+func (p ProductFilesService) DeleteOrphans(config DeleteOrphansConfig) (DeleteOrphansReport, error) {
+	err := ValidateFilePatterns(config.Exclude)
+	if err != nil {
+		return DeleteOrphansReport{}, err
+	}
+
+	orphans, err := p.findOrphans(config.ProductSlug, config.Concurrency)
+	if err != nil {
+		return DeleteOrphansReport{}, err
+	}
+
+	excludedIDs := map[int]bool{}
+	for _, id := range config.ExcludeIDs {
+		excludedIDs[id] = true
+	}
+
+	var report DeleteOrphansReport
+	for _, orphan := range orphans {
+		spared := excludedIDs[orphan.ProductFile.ID] ||
+			!matchesFilters(orphan.ProductFile, nil, config.Exclude) ||
+			(config.MinAge > 0 && (orphan.Age == 0 || orphan.Age < config.MinAge))
+
+		if spared {
+			report.Skipped = append(report.Skipped, orphan)
+		} else {
+			report.Eligible = append(report.Eligible, orphan)
+		}
+	}
+
+	if config.MaxDeletes > 0 && len(report.Eligible) > config.MaxDeletes {
+		return report, fmt.Errorf(
+			"refusing to delete %d orphaned product files, more than the maximum of %d",
+			len(report.Eligible),
+			config.MaxDeletes,
+		)
+	}
+
+	if config.DryRun {
+		return report, nil
+	}
+
+	for _, orphan := range report.Eligible {
+		_, err := p.Delete(config.ProductSlug, orphan.ProductFile.ID)
+		if err != nil {
+			report.Failed = append(report.Failed, FailedOrphan{OrphanedProductFile: orphan, Err: err})
+			continue
+		}
+
+		report.Deleted = append(report.Deleted, orphan)
+	}
+
+	if len(report.Failed) > 0 {
+		return report, fmt.Errorf(
+			"%d of %d orphaned product files failed to delete",
+			len(report.Failed),
+			len(report.Eligible),
+		)
+	}
+
+	return report, nil
+}
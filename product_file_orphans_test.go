@@ -0,0 +1,224 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - orphaned product files", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+
+		all        []pivnet.ProductFile
+		deleted    []int
+		deleteFail map[int]bool
+	)
+
+	ids := func(orphans []pivnet.OrphanedProductFile) []int {
+		var result []int
+		for _, orphan := range orphans {
+			result = append(result, orphan.ProductFile.ID)
+		}
+
+		return result
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, &loggerfakes.FakeLogger{})
+
+		recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+		all = []pivnet.ProductFile{
+			{ID: 10, AWSObjectKey: "product-files/some-product/in-release.zip"},
+			{ID: 11, AWSObjectKey: "product-files/some-product/in-release-group.zip"},
+			{ID: 12, AWSObjectKey: "product-files/some-product/in-unattached-group.zip"},
+			{ID: 16, AWSObjectKey: "product-files/some-product/keep-me.zip", UpdatedAt: "2017-01-01T00:00:00Z"},
+			{ID: 13, AWSObjectKey: "product-files/some-product/old.zip", UpdatedAt: "2017-01-01T00:00:00Z", Size: 1024},
+			{ID: 14, AWSObjectKey: "product-files/some-product/recent.zip", UpdatedAt: recent},
+			{ID: 15, AWSObjectKey: "product-files/some-product/unknown-age.zip"},
+		}
+
+		deleted = nil
+		deleteFail = map[int]bool{}
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: all}),
+		)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: []pivnet.Release{{ID: 1}}}),
+		)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/1/product_files", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: all[:1]}),
+		)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/1/file_groups", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{FileGroups: []pivnet.FileGroup{
+				{ID: 50, ProductFiles: all[1:2]},
+			}}),
+		)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/file_groups", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{FileGroups: []pivnet.FileGroup{
+				{ID: 51, ProductFiles: all[2:3]},
+			}}),
+		)
+
+		for _, pf := range all {
+			pf := pf
+			server.RouteToHandler("DELETE", fmt.Sprintf("%s/products/%s/product_files/%d", apiPrefix, productSlug, pf.ID),
+				func(w http.ResponseWriter, req *http.Request) {
+					if deleteFail[pf.ID] {
+						ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"})(w, req)
+						return
+					}
+
+					deleted = append(deleted, pf.ID)
+					ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: pf})(w, req)
+				},
+			)
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("FindOrphans", func() {
+		It("returns the product files in no release or file group with their age and size", func() {
+			orphans, err := client.ProductFiles.FindOrphans(productSlug)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ids(orphans)).To(Equal([]int{13, 14, 15, 16}))
+
+			Expect(orphans[0].Size).To(Equal(1024))
+			Expect(orphans[0].Age).To(BeNumerically(">", 365*24*time.Hour))
+			Expect(orphans[1].Age).To(BeNumerically("~", time.Hour, time.Minute))
+			Expect(orphans[2].Age).To(BeZero())
+		})
+
+		Context("when listing the product files fails", func() {
+			JustBeforeEach(func() {
+				server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+					ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+				)
+			})
+
+			It("forwards the error", func() {
+				_, err := client.ProductFiles.FindOrphans(productSlug)
+				Expect(err).To(MatchError(ContainSubstring("foo message")))
+			})
+		})
+	})
+
+	Describe("DeleteOrphans", func() {
+		var config pivnet.DeleteOrphansConfig
+
+		BeforeEach(func() {
+			config = pivnet.DeleteOrphansConfig{
+				ProductSlug: productSlug,
+				MinAge:      24 * time.Hour,
+				Exclude:     []string{"keep-*"},
+			}
+		})
+
+		It("deletes the orphans old enough and not excluded", func() {
+			report, err := client.ProductFiles.DeleteOrphans(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ids(report.Eligible)).To(Equal([]int{13}))
+			Expect(ids(report.Skipped)).To(Equal([]int{14, 15, 16}))
+			Expect(ids(report.Deleted)).To(Equal([]int{13}))
+			Expect(deleted).To(Equal([]int{13}))
+		})
+
+		It("spares orphans excluded by ID", func() {
+			config.MinAge = 0
+			config.ExcludeIDs = []int{14}
+
+			report, err := client.ProductFiles.DeleteOrphans(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ids(report.Deleted)).To(Equal([]int{13, 15}))
+		})
+
+		Context("when it is a dry run", func() {
+			BeforeEach(func() {
+				config.DryRun = true
+			})
+
+			It("reports what would be deleted without deleting it", func() {
+				report, err := client.ProductFiles.DeleteOrphans(config)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(ids(report.Eligible)).To(Equal([]int{13}))
+				Expect(report.Deleted).To(BeEmpty())
+				Expect(deleted).To(BeEmpty())
+			})
+		})
+
+		Context("when more orphans would be deleted than allowed", func() {
+			BeforeEach(func() {
+				config.MinAge = 0
+				config.Exclude = nil
+				config.MaxDeletes = 3
+			})
+
+			It("returns an error without deleting anything", func() {
+				report, err := client.ProductFiles.DeleteOrphans(config)
+				Expect(err).To(MatchError("refusing to delete 4 orphaned product files, more than the maximum of 3"))
+
+				Expect(report.Eligible).To(HaveLen(4))
+				Expect(deleted).To(BeEmpty())
+			})
+		})
+
+		Context("when a delete fails", func() {
+			BeforeEach(func() {
+				config.MinAge = 0
+				config.Exclude = nil
+				deleteFail[14] = true
+			})
+
+			It("carries on and reports the failure", func() {
+				report, err := client.ProductFiles.DeleteOrphans(config)
+				Expect(err).To(MatchError("1 of 4 orphaned product files failed to delete"))
+
+				Expect(ids(report.Deleted)).To(Equal([]int{13, 15, 16}))
+				Expect(report.Failed).To(HaveLen(1))
+				Expect(report.Failed[0].ProductFile.ID).To(Equal(14))
+				Expect(report.Failed[0].Err).To(MatchError(ContainSubstring("foo message")))
+			})
+		})
+
+		Context("when an exclusion pattern is invalid", func() {
+			BeforeEach(func() {
+				config.Exclude = []string{"["}
+			})
+
+			It("returns an error without making a request", func() {
+				_, err := client.ProductFiles.DeleteOrphans(config)
+				Expect(err).To(MatchError(ContainSubstring(`invalid pattern "["`)))
+
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+})
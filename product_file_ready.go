@@ -0,0 +1,179 @@
+package pivnet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const (
+	FileTransferStatusComplete = "complete"
+
+	DefaultWaitTimeout         = 30 * time.Minute
+	DefaultWaitPollInterval    = 5 * time.Second
+	DefaultWaitMaxPollInterval = time.Minute
+)
+
+type WaitUntilReadyConfig struct {
+	// Timeout defaults to DefaultWaitTimeout
+	Timeout time.Duration
+
+	// PollInterval is the delay before the second poll. It doubles after
+	// every poll, up to MaxPollInterval. Defaults to DefaultWaitPollInterval
+	// and DefaultWaitMaxPollInterval respectively.
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+
+	// OnPoll, when set, is called with each product file every time it is
+	// polled, until it is ready.
+	OnPoll func(productFile ProductFile)
+}
+
+// WaitUntilReady polls a product file until its transfer is complete and it
+// is ready to serve. It returns ErrProductFileNotReady if the transfer fails,
+// the timeout elapses or ctx is cancelled first.
+func (p ProductFilesService) WaitUntilReady(
+	ctx context.Context,
+	productSlug string,
+	productFileID int,
+	config WaitUntilReadyConfig,
+) (ProductFile, error) {
+	productFiles, err := p.waitUntilReady(ctx, productSlug, []int{productFileID}, config)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	return productFiles[0], nil
+}
+
+// WaitUntilReleaseReady waits for every product file in a release, including
+// those in its file groups, as WaitUntilReady does. The timeout applies to
+// the release as a whole.
+func (p ProductFilesService) WaitUntilReleaseReady(
+	ctx context.Context,
+	productSlug string,
+	releaseID int,
+	config WaitUntilReadyConfig,
+) ([]ProductFile, error) {
+	productFiles, err := p.ListForRelease(productSlug, releaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileGroups, err := FileGroupsService{client: p.client}.ListForRelease(productSlug, releaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fileGroup := range fileGroups {
+		productFiles = append(productFiles, fileGroup.ProductFiles...)
+	}
+
+	var ids []int
+	seen := make(map[int]bool)
+	for _, pf := range productFiles {
+		if !seen[pf.ID] {
+			seen[pf.ID] = true
+			ids = append(ids, pf.ID)
+		}
+	}
+
+	return p.waitUntilReady(ctx, productSlug, ids, config)
+}
+
+func (p ProductFilesService) waitUntilReady(
+	ctx context.Context,
+	productSlug string,
+	productFileIDs []int,
+	config WaitUntilReadyConfig,
+) ([]ProductFile, error) {
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWaitTimeout
+	}
+
+	interval := config.PollInterval
+	if interval <= 0 {
+		interval = DefaultWaitPollInterval
+	}
+
+	maxInterval := config.MaxPollInterval
+	if maxInterval <= 0 {
+		maxInterval = DefaultWaitMaxPollInterval
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	polled := make([]ProductFile, len(productFileIDs))
+	pending := make([]int, len(productFileIDs))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	notReady := func(pf ProductFile, reason string) error {
+		return ErrProductFileNotReady{
+			ProductFileID:      pf.ID,
+			FileTransferStatus: pf.FileTransferStatus,
+			ReadyToServe:       pf.ReadyToServe,
+			Reason:             reason,
+		}
+	}
+
+	for {
+		var stillPending []int
+		for _, i := range pending {
+			pf, err := p.Get(productSlug, productFileIDs[i])
+			if err != nil {
+				return nil, err
+			}
+			polled[i] = pf
+
+			if config.OnPoll != nil {
+				config.OnPoll(pf)
+			}
+
+			if strings.HasPrefix(strings.ToLower(pf.FileTransferStatus), "fail") {
+				return nil, notReady(pf, "file transfer failed")
+			}
+
+			if pf.FileTransferStatus != FileTransferStatusComplete || !pf.ReadyToServe {
+				stillPending = append(stillPending, i)
+			}
+		}
+		pending = stillPending
+
+		if len(pending) == 0 {
+			return polled, nil
+		}
+
+		p.client.logger.Debug("Waiting for product files to become ready", logger.Data{
+			"product":      productSlug,
+			"pending":      len(pending),
+			"pollInterval": interval.String(),
+		})
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, notReady(polled[pending[0]], fmt.Sprintf("timed out after %s", timeout))
+		}
+
+		wait := interval
+		if wait > remaining {
+			wait = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, notReady(polled[pending[0]], ctx.Err().Error())
+		case <-time.After(wait):
+		}
+
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
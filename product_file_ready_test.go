@@ -0,0 +1,218 @@
+package pivnet_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - wait until product files are ready", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+
+		releaseID int
+		config    pivnet.WaitUntilReadyConfig
+
+		mutex    sync.Mutex
+		statuses map[int][]pivnet.ProductFile
+		polls    map[int]int
+	)
+
+	status := func(id int, transferStatus string, readyToServe bool) pivnet.ProductFile {
+		return pivnet.ProductFile{ID: id, FileTransferStatus: transferStatus, ReadyToServe: readyToServe}
+	}
+
+	routeProductFile := func(id int) {
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/product_files/%d", apiPrefix, productSlug, id),
+			func(w http.ResponseWriter, req *http.Request) {
+				mutex.Lock()
+				sequence := statuses[id]
+				pf := sequence[0]
+				if len(sequence) > 1 {
+					statuses[id] = sequence[1:]
+				}
+				polls[id]++
+				mutex.Unlock()
+
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: pf})(w, req)
+			},
+		)
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, &loggerfakes.FakeLogger{})
+
+		releaseID = 1234
+		config = pivnet.WaitUntilReadyConfig{
+			Timeout:         time.Second,
+			PollInterval:    time.Millisecond,
+			MaxPollInterval: 4 * time.Millisecond,
+		}
+
+		statuses = map[int][]pivnet.ProductFile{
+			100: {
+				status(100, "in_progress", false),
+				status(100, "complete", false),
+				status(100, "complete", true),
+			},
+		}
+		polls = make(map[int]int)
+	})
+
+	JustBeforeEach(func() {
+		for id := range statuses {
+			routeProductFile(id)
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("WaitUntilReady", func() {
+		It("polls until the transfer is complete and the file is ready to serve", func() {
+			var observed []pivnet.ProductFile
+			config.OnPoll = func(pf pivnet.ProductFile) {
+				observed = append(observed, pf)
+			}
+
+			pf, err := client.ProductFiles.WaitUntilReady(context.Background(), productSlug, 100, config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(pf).To(Equal(status(100, "complete", true)))
+			Expect(observed).To(Equal([]pivnet.ProductFile{
+				status(100, "in_progress", false),
+				status(100, "complete", false),
+				status(100, "complete", true),
+			}))
+		})
+
+		Context("when the transfer fails", func() {
+			BeforeEach(func() {
+				statuses[100] = []pivnet.ProductFile{
+					status(100, "in_progress", false),
+					status(100, "failed", false),
+				}
+			})
+
+			It("returns an error with the last status", func() {
+				_, err := client.ProductFiles.WaitUntilReady(context.Background(), productSlug, 100, config)
+				Expect(err).To(Equal(pivnet.ErrProductFileNotReady{
+					ProductFileID:      100,
+					FileTransferStatus: "failed",
+					Reason:             "file transfer failed",
+				}))
+			})
+		})
+
+		Context("when the file does not become ready in time", func() {
+			BeforeEach(func() {
+				config.Timeout = 20 * time.Millisecond
+				statuses[100] = []pivnet.ProductFile{status(100, "in_progress", false)}
+			})
+
+			It("returns an error with the last status", func() {
+				_, err := client.ProductFiles.WaitUntilReady(context.Background(), productSlug, 100, config)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrProductFileNotReady{}))
+
+				notReady := err.(pivnet.ErrProductFileNotReady)
+				Expect(notReady.FileTransferStatus).To(Equal("in_progress"))
+				Expect(notReady.Reason).To(Equal("timed out after 20ms"))
+			})
+		})
+
+		Context("when the context is cancelled", func() {
+			BeforeEach(func() {
+				config.PollInterval = time.Hour
+				statuses[100] = []pivnet.ProductFile{status(100, "in_progress", false)}
+			})
+
+			It("stops waiting", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				config.OnPoll = func(pivnet.ProductFile) {
+					cancel()
+				}
+
+				_, err := client.ProductFiles.WaitUntilReady(ctx, productSlug, 100, config)
+				Expect(err).To(MatchError(ContainSubstring("context canceled")))
+				Expect(polls[100]).To(Equal(1))
+			})
+		})
+
+		Context("when getting the product file fails", func() {
+			JustBeforeEach(func() {
+				server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/product_files/%d", apiPrefix, productSlug, 100),
+					ghttp.RespondWith(http.StatusTeapot, `{"message":"foo message"}`),
+				)
+			})
+
+			It("forwards the error", func() {
+				_, err := client.ProductFiles.WaitUntilReady(context.Background(), productSlug, 100, config)
+				Expect(err).To(MatchError(ContainSubstring("foo message")))
+			})
+		})
+	})
+
+	Describe("WaitUntilReleaseReady", func() {
+		BeforeEach(func() {
+			statuses[101] = []pivnet.ProductFile{status(101, "complete", true)}
+			statuses[102] = []pivnet.ProductFile{
+				status(102, "in_progress", false),
+				status(102, "complete", true),
+			}
+		})
+
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{
+					ProductFiles: []pivnet.ProductFile{{ID: 100}, {ID: 101}},
+				}),
+			)
+
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/file_groups", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{
+					FileGroups: []pivnet.FileGroup{{ID: 1, ProductFiles: []pivnet.ProductFile{{ID: 101}, {ID: 102}}}},
+				}),
+			)
+		})
+
+		It("waits for every product file in the release and its file groups", func() {
+			productFiles, err := client.ProductFiles.WaitUntilReleaseReady(context.Background(), productSlug, releaseID, config)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(productFiles).To(Equal([]pivnet.ProductFile{
+				status(100, "complete", true),
+				status(101, "complete", true),
+				status(102, "complete", true),
+			}))
+
+			Expect(polls).To(Equal(map[int]int{100: 3, 101: 1, 102: 2}))
+		})
+
+		Context("when a product file fails to transfer", func() {
+			BeforeEach(func() {
+				statuses[102] = []pivnet.ProductFile{status(102, "failed", false)}
+			})
+
+			It("returns an error for that product file", func() {
+				_, err := client.ProductFiles.WaitUntilReleaseReady(context.Background(), productSlug, releaseID, config)
+				Expect(err).To(MatchError(ContainSubstring("product file 102 is not ready to serve")))
+			})
+		})
+	})
+})
@@ -0,0 +1,430 @@
+package pivnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+const concurrentReleaseScans = 4
+
+// ProductFileQuery selects product files across the releases of a product.
+// Empty filters match everything; a product file must match every filter
+// that is set.
+//
+// Listings of product files may omit their platforms, file version and MD5.
+// When filtering on one of those, the full record is fetched for each
+// candidate whose listing omits it. Full records are not kept in the
+// metadata cache.
+type ProductFileQuery struct {
+	ProductSlug string
+
+	// Name is a regular expression matched against the product file name.
+	Name string
+
+	FileType FileType
+
+	// Platforms must all be listed by a product file, ignoring case.
+	Platforms []string
+
+	FileVersion  string
+	MD5          string
+	AWSObjectKey string
+
+	// Concurrency is the number of releases scanned, or full product file
+	// records fetched, at once.
+	Concurrency int
+
+	// MetadataCacheDir, when set, keeps the product files and file groups of
+	// each release scanned, so that a later search only fetches those of
+	// releases that have been updated since.
+	MetadataCacheDir string
+
+	// MetadataCacheMaxAge, when set, also refetches releases whose cached
+	// metadata is older, as changes to file groups may not update a release.
+	MetadataCacheMaxAge time.Duration
+}
+
+// ProductFileMatch is a product file found by a search.
+type ProductFileMatch struct {
+	ProductFile ProductFile
+
+	// Releases contain the product file, directly or through a file group.
+	Releases []Release
+
+	// FileGroups contain the product file in one of the releases. Their
+	// product files are omitted.
+	FileGroups []FileGroup
+}
+
+// releaseMetadata is the product files and file groups of a release, as
+// kept in the metadata cache.
+type releaseMetadata struct {
+	Release      Release       `json:"release"`
+	ProductFiles []ProductFile `json:"product_files"`
+	FileGroups   []FileGroup   `json:"file_groups"`
+	FetchedAt    time.Time     `json:"fetched_at"`
+}
+
+// Search scans the releases of a product for product files matching the
+// query, including those in file groups, and returns them in order of ID.
+func (p ProductFilesService) Search(query ProductFileQuery) ([]ProductFileMatch, error) {
+	var namePattern *regexp.Regexp
+	if query.Name != "" {
+		var err error
+		namePattern, err = regexp.Compile(query.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %s", query.Name, err)
+		}
+	}
+
+	releases, err := ReleasesService{client: p.client, l: p.client.logger}.List(query.ProductSlug)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := p.scanReleases(query, releases)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := p.fetchDetails(query, namePattern, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := map[int]*ProductFileMatch{}
+
+	add := func(pf ProductFile, release Release, fileGroup *FileGroup) {
+		if full, ok := details[pf.ID]; ok {
+			pf = full
+		}
+
+		if !query.matches(pf, namePattern) {
+			return
+		}
+
+		match, ok := matches[pf.ID]
+		if !ok {
+			match = &ProductFileMatch{ProductFile: pf}
+			matches[pf.ID] = match
+		}
+
+		if len(match.Releases) == 0 || match.Releases[len(match.Releases)-1].ID != release.ID {
+			match.Releases = append(match.Releases, release)
+		}
+
+		if fileGroup == nil {
+			return
+		}
+
+		for _, fg := range match.FileGroups {
+			if fg.ID == fileGroup.ID {
+				return
+			}
+		}
+
+		fg := *fileGroup
+		fg.ProductFiles = nil
+		match.FileGroups = append(match.FileGroups, fg)
+	}
+
+	for i, m := range metadata {
+		for _, pf := range m.ProductFiles {
+			add(pf, releases[i], nil)
+		}
+
+		for j := range m.FileGroups {
+			for _, pf := range m.FileGroups[j].ProductFiles {
+				add(pf, releases[i], &m.FileGroups[j])
+			}
+		}
+	}
+
+	results := make([]ProductFileMatch, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, *match)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ProductFile.ID < results[j].ProductFile.ID
+	})
+
+	return results, nil
+}
+
+func (q ProductFileQuery) matches(pf ProductFile, namePattern *regexp.Regexp) bool {
+	if namePattern != nil && !namePattern.MatchString(pf.Name) {
+		return false
+	}
+
+	if q.FileType != "" && pf.FileType != q.FileType {
+		return false
+	}
+
+	if q.FileVersion != "" && pf.FileVersion != q.FileVersion {
+		return false
+	}
+
+	if q.MD5 != "" && !strings.EqualFold(pf.MD5, q.MD5) {
+		return false
+	}
+
+	if q.AWSObjectKey != "" && pf.AWSObjectKey != q.AWSObjectKey {
+		return false
+	}
+
+	for _, platform := range q.Platforms {
+		found := false
+		for _, p := range pf.Platforms {
+			if strings.EqualFold(p, platform) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// needsDetails reports whether the query filters on a field the listed
+// product file omits, so that it can only be matched against the full
+// record.
+func (q ProductFileQuery) needsDetails(pf ProductFile) bool {
+	return (q.FileVersion != "" && pf.FileVersion == "") ||
+		(q.MD5 != "" && pf.MD5 == "") ||
+		(len(q.Platforms) > 0 && len(pf.Platforms) == 0)
+}
+
+// fetchDetails returns the full records, by ID, of the listed product files
+// that omit a field the query filters on and match it on name, file type and
+// AWS object key.
+func (p ProductFilesService) fetchDetails(
+	query ProductFileQuery,
+	namePattern *regexp.Regexp,
+	metadata []releaseMetadata,
+) (map[int]ProductFile, error) {
+	// Only the fields the listing omits are left to match
+	listed := query
+	listed.FileVersion = ""
+	listed.MD5 = ""
+	listed.Platforms = nil
+
+	candidates := map[int]bool{}
+	consider := func(pf ProductFile) {
+		if query.needsDetails(pf) && listed.matches(pf, namePattern) {
+			candidates[pf.ID] = true
+		}
+	}
+
+	for _, m := range metadata {
+		for _, pf := range m.ProductFiles {
+			consider(pf)
+		}
+
+		for _, fg := range m.FileGroups {
+			for _, pf := range fg.ProductFiles {
+				consider(pf)
+			}
+		}
+	}
+
+	concurrency := query.Concurrency
+	if concurrency < 1 {
+		concurrency = concurrentReleaseScans
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	var mutex sync.Mutex
+	details := make(map[int]ProductFile, len(candidates))
+	var firstErr error
+
+	var wg sync.WaitGroup
+	for id := range candidates {
+		id := id
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			pf, err := p.Get(query.ProductSlug, id)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+
+			details[id] = pf
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return details, nil
+}
+
+// scanReleases returns the metadata of each release, several at a time,
+// from the metadata cache where it is still current.
+func (p ProductFilesService) scanReleases(query ProductFileQuery, releases []Release) ([]releaseMetadata, error) {
+	concurrency := query.Concurrency
+	if concurrency < 1 {
+		concurrency = concurrentReleaseScans
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	metadata := make([]releaseMetadata, len(releases))
+	errs := make([]error, len(releases))
+
+	var wg sync.WaitGroup
+	for i, release := range releases {
+		i, release := i, release
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			metadata[i], errs[i] = p.releaseMetadata(query, release)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return metadata, nil
+}
+
+func (p ProductFilesService) releaseMetadata(query ProductFileQuery, release Release) (releaseMetadata, error) {
+	cachePath := ""
+	if query.MetadataCacheDir != "" {
+		cachePath = filepath.Join(
+			query.MetadataCacheDir,
+			query.ProductSlug,
+			fmt.Sprintf("release-%d.json", release.ID),
+		)
+
+		cached, ok := readReleaseMetadata(cachePath)
+		if ok && cached.current(release, query.MetadataCacheMaxAge) {
+			return cached, nil
+		}
+	}
+
+	productFiles, err := p.ListForRelease(query.ProductSlug, release.ID)
+	if err != nil {
+		return releaseMetadata{}, err
+	}
+
+	fileGroups, err := FileGroupsService{client: p.client}.ListForRelease(query.ProductSlug, release.ID)
+	if err != nil {
+		return releaseMetadata{}, err
+	}
+
+	m := releaseMetadata{
+		Release:      release,
+		ProductFiles: productFiles,
+		FileGroups:   fileGroups,
+		FetchedAt:    time.Now().UTC(),
+	}
+
+	if cachePath != "" {
+		err = m.write(cachePath)
+		if err != nil {
+			// The cache only saves requests, so the search carries on
+			p.client.logger.Info("Failed to write release metadata cache", logger.Data{
+				"path":  cachePath,
+				"error": err.Error(),
+			})
+		}
+	}
+
+	return m, nil
+}
+
+// current reports whether cached metadata still describes the release. It
+// cannot tell for releases without an update time.
+func (m releaseMetadata) current(release Release, maxAge time.Duration) bool {
+	if release.UpdatedAt == "" || m.Release.UpdatedAt != release.UpdatedAt {
+		return false
+	}
+
+	return maxAge == 0 || time.Since(m.FetchedAt) < maxAge
+}
+
+// readReleaseMetadata reads cached metadata, treating a missing or
+// unreadable entry as absent.
+func readReleaseMetadata(path string) (releaseMetadata, bool) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return releaseMetadata{}, false
+	}
+
+	var m releaseMetadata
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return releaseMetadata{}, false
+	}
+
+	return m, true
+}
+
+func (m releaseMetadata) write(path string) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling a known-good body
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".release-metadata.tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,265 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - search product files", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+
+		releases     []pivnet.Release
+		productFiles map[int][]pivnet.ProductFile
+		fileGroups   map[int][]pivnet.FileGroup
+
+		// details are the full records of product files whose listings
+		// omit fields
+		details map[int]pivnet.ProductFile
+
+		linux   pivnet.ProductFile
+		windows pivnet.ProductFile
+		docs    pivnet.ProductFile
+		grouped pivnet.ProductFile
+	)
+
+	releaseRequests := func() int {
+		count := 0
+		for _, req := range server.ReceivedRequests() {
+			if strings.HasPrefix(req.URL.Path, fmt.Sprintf("%s/products/%s/releases/", apiPrefix, productSlug)) {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, &loggerfakes.FakeLogger{})
+
+		releases = []pivnet.Release{
+			{ID: 1, Version: "1.0.0", UpdatedAt: "2017-01-01T00:00:00Z"},
+			{ID: 2, Version: "1.1.0", UpdatedAt: "2017-02-01T00:00:00Z"},
+		}
+
+		linux = pivnet.ProductFile{
+			ID:           10,
+			Name:         "CLI - Linux",
+			AWSObjectKey: "product-files/some-product/cli-linux-amd64",
+			FileType:     pivnet.FileTypeSoftware,
+			Platforms:    []string{"Linux"},
+			MD5:          "ABCDEF",
+		}
+		windows = pivnet.ProductFile{
+			ID:           11,
+			Name:         "CLI - Windows",
+			AWSObjectKey: "product-files/some-product/cli-windows-amd64.exe",
+			FileType:     pivnet.FileTypeSoftware,
+			Platforms:    []string{"Windows"},
+		}
+		docs = pivnet.ProductFile{
+			ID:           12,
+			Name:         "Release Notes",
+			AWSObjectKey: "product-files/some-product/release-notes.pdf",
+			FileType:     pivnet.FileTypeDocumentation,
+		}
+		grouped = pivnet.ProductFile{
+			ID:           13,
+			Name:         "Agent - Linux",
+			AWSObjectKey: "product-files/some-product/agent-linux-amd64",
+			FileType:     pivnet.FileTypeSoftware,
+			Platforms:    []string{"linux", "arm64"},
+		}
+
+		productFiles = map[int][]pivnet.ProductFile{
+			1: {linux, docs},
+			2: {linux, windows},
+		}
+
+		fileGroups = map[int][]pivnet.FileGroup{
+			1: nil,
+			2: {{ID: 50, Name: "Agents", ProductFiles: []pivnet.ProductFile{grouped}}},
+		}
+
+		details = map[int]pivnet.ProductFile{}
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases", apiPrefix, productSlug),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: releases}),
+		)
+
+		for _, release := range releases {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, release.ID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: productFiles[release.ID]}),
+			)
+
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/file_groups", apiPrefix, productSlug, release.ID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FileGroupsResponse{FileGroups: fileGroups[release.ID]}),
+			)
+		}
+
+		for _, pf := range []pivnet.ProductFile{linux, windows, docs, grouped} {
+			full, ok := details[pf.ID]
+			if !ok {
+				full = pf
+			}
+
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/product_files/%d", apiPrefix, productSlug, pf.ID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: full}),
+			)
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("returns the matching product files with the releases and file groups containing them", func() {
+		matches, err := client.ProductFiles.Search(pivnet.ProductFileQuery{
+			ProductSlug: productSlug,
+			Platforms:   []string{"LINUX"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(matches).To(Equal([]pivnet.ProductFileMatch{
+			{
+				ProductFile: linux,
+				Releases:    releases,
+			},
+			{
+				ProductFile: grouped,
+				Releases:    []pivnet.Release{releases[1]},
+				FileGroups:  []pivnet.FileGroup{{ID: 50, Name: "Agents"}},
+			},
+		}))
+	})
+
+	It("applies every filter that is set", func() {
+		matches, err := client.ProductFiles.Search(pivnet.ProductFileQuery{
+			ProductSlug: productSlug,
+			Name:        "^CLI",
+			FileType:    pivnet.FileTypeSoftware,
+			MD5:         "abcdef",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(matches).To(HaveLen(1))
+		Expect(matches[0].ProductFile).To(Equal(linux))
+
+		matches, err = client.ProductFiles.Search(pivnet.ProductFileQuery{
+			ProductSlug:  productSlug,
+			AWSObjectKey: docs.AWSObjectKey,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(matches).To(HaveLen(1))
+		Expect(matches[0].ProductFile).To(Equal(docs))
+		Expect(matches[0].Releases).To(Equal([]pivnet.Release{releases[0]}))
+	})
+
+	Context("when listings omit a field being filtered on", func() {
+		BeforeEach(func() {
+			details[windows.ID] = windows
+
+			listed := windows
+			listed.Platforms = nil
+			productFiles[2] = []pivnet.ProductFile{linux, listed}
+		})
+
+		It("matches against the full record", func() {
+			matches, err := client.ProductFiles.Search(pivnet.ProductFileQuery{
+				ProductSlug: productSlug,
+				Platforms:   []string{"windows"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(matches).To(HaveLen(1))
+			Expect(matches[0].ProductFile).To(Equal(windows))
+			Expect(matches[0].Releases).To(Equal([]pivnet.Release{releases[1]}))
+		})
+	})
+
+	Context("when the name pattern is invalid", func() {
+		It("returns an error without making a request", func() {
+			_, err := client.ProductFiles.Search(pivnet.ProductFileQuery{
+				ProductSlug: productSlug,
+				Name:        "(",
+			})
+			Expect(err).To(MatchError(ContainSubstring(`invalid name pattern "("`)))
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when a metadata cache directory is given", func() {
+		var cacheDir string
+
+		BeforeEach(func() {
+			var err error
+			cacheDir, err = ioutil.TempDir("", "")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			err := os.RemoveAll(cacheDir)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		search := func() []pivnet.ProductFileMatch {
+			matches, err := client.ProductFiles.Search(pivnet.ProductFileQuery{
+				ProductSlug:      productSlug,
+				Platforms:        []string{"linux"},
+				MetadataCacheDir: cacheDir,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			return matches
+		}
+
+		It("only fetches the metadata of releases updated since the last search", func() {
+			first := search()
+			Expect(releaseRequests()).To(Equal(4))
+
+			Expect(search()).To(Equal(first))
+			Expect(releaseRequests()).To(Equal(4))
+
+			releases[1].UpdatedAt = "2017-03-01T00:00:00Z"
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases", apiPrefix, productSlug),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ReleasesResponse{Releases: releases}),
+			)
+
+			search()
+			Expect(releaseRequests()).To(Equal(6))
+		})
+	})
+
+	Context("when listing a release's product files fails", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, 2),
+				ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+			)
+		})
+
+		It("forwards the error", func() {
+			_, err := client.ProductFiles.Search(pivnet.ProductFileQuery{ProductSlug: productSlug})
+			Expect(err).To(MatchError(ContainSubstring("foo message")))
+		})
+	})
+})
@@ -0,0 +1,106 @@
+package pivnet
+
+import (
+	"fmt"
+)
+
+// ProductFileField names a mutable field of a product file as it is sent to
+// the API.
+type ProductFileField string
+
+const (
+	ProductFileFieldDescription        ProductFileField = "description"
+	ProductFileFieldDocsURL            ProductFileField = "docs_url"
+	ProductFileFieldFileType           ProductFileField = "file_type"
+	ProductFileFieldFileVersion        ProductFileField = "file_version"
+	ProductFileFieldIncludedFiles      ProductFileField = "included_files"
+	ProductFileFieldMD5                ProductFileField = "md5"
+	ProductFileFieldName               ProductFileField = "name"
+	ProductFileFieldPlatforms          ProductFileField = "platforms"
+	ProductFileFieldReleasedAt         ProductFileField = "released_at"
+	ProductFileFieldSHA256             ProductFileField = "sha256"
+	ProductFileFieldSystemRequirements ProductFileField = "system_requirements"
+)
+
+// ProductFileFields lists every mutable field of a product file.
+var ProductFileFields = []ProductFileField{
+	ProductFileFieldDescription,
+	ProductFileFieldDocsURL,
+	ProductFileFieldFileType,
+	ProductFileFieldFileVersion,
+	ProductFileFieldIncludedFiles,
+	ProductFileFieldMD5,
+	ProductFileFieldName,
+	ProductFileFieldPlatforms,
+	ProductFileFieldReleasedAt,
+	ProductFileFieldSHA256,
+	ProductFileFieldSystemRequirements,
+}
+
+// UpdateFields sets exactly the given fields of a product file to their
+// values on productFile, including empty values, so a field can be cleared.
+// Fields not given are not changed.
+func (p ProductFilesService) UpdateFields(
+	productSlug string,
+	productFile ProductFile,
+	fields ...ProductFileField,
+) (ProductFile, error) {
+	if len(fields) == 0 {
+		return ProductFile{}, fmt.Errorf("no fields given to update product file %d", productFile.ID)
+	}
+
+	values := map[string]interface{}{}
+	for _, field := range fields {
+		value, err := productFileFieldValue(productFile, field)
+		if err != nil {
+			return ProductFile{}, err
+		}
+
+		values[string(field)] = value
+	}
+
+	body := map[string]interface{}{
+		"product_file": values,
+	}
+
+	return p.patch(productSlug, productFile.ID, body)
+}
+
+func productFileFieldValue(productFile ProductFile, field ProductFileField) (interface{}, error) {
+	switch field {
+	case ProductFileFieldDescription:
+		return productFile.Description, nil
+	case ProductFileFieldDocsURL:
+		return productFile.DocsURL, nil
+	case ProductFileFieldFileType:
+		return productFile.FileType, nil
+	case ProductFileFieldFileVersion:
+		return productFile.FileVersion, nil
+	case ProductFileFieldIncludedFiles:
+		return nonNilStrings(productFile.IncludedFiles), nil
+	case ProductFileFieldMD5:
+		return productFile.MD5, nil
+	case ProductFileFieldName:
+		return productFile.Name, nil
+	case ProductFileFieldPlatforms:
+		return nonNilStrings(productFile.Platforms), nil
+	case ProductFileFieldReleasedAt:
+		return productFile.ReleasedAt, nil
+	case ProductFileFieldSHA256:
+		return productFile.SHA256, nil
+	case ProductFileFieldSystemRequirements:
+		return nonNilStrings(productFile.SystemRequirements), nil
+	default:
+		return nil, fmt.Errorf("product file field %q cannot be updated", field)
+	}
+}
+
+// nonNilStrings returns an empty slice for nil, so that clearing a list is
+// sent as [] rather than null.
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+
+	return s
+}
@@ -0,0 +1,132 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - update product file fields", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+
+		productFile pivnet.ProductFile
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, &loggerfakes.FakeLogger{})
+
+		productFile = pivnet.ProductFile{
+			ID:          1234,
+			Description: "some-description",
+			DocsURL:     "",
+			Name:        "some-file-name",
+			Platforms:   nil,
+			ReleasedAt:  "2017-01-01",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends exactly the given fields, including empty values", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("PATCH", fmt.Sprintf(
+					"%s/products/%s/product_files/%d",
+					apiPrefix,
+					productSlug,
+					productFile.ID,
+				)),
+				ghttp.VerifyJSON(`{
+					"product_file": {
+						"description": "some-description",
+						"docs_url": "",
+						"platforms": [],
+						"released_at": "2017-01-01"
+					}
+				}`),
+				ghttp.RespondWith(http.StatusOK, `{"product_file":{"id":1234,"description":"some-description"}}`),
+			),
+		)
+
+		updated, err := client.ProductFiles.UpdateFields(
+			productSlug,
+			productFile,
+			pivnet.ProductFileFieldDescription,
+			pivnet.ProductFileFieldDocsURL,
+			pivnet.ProductFileFieldPlatforms,
+			pivnet.ProductFileFieldReleasedAt,
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated).To(Equal(pivnet.ProductFile{ID: 1234, Description: "some-description"}))
+	})
+
+	It("can send every mutable field", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyJSON(`{
+					"product_file": {
+						"description": "some-description",
+						"docs_url": "",
+						"file_type": "",
+						"file_version": "",
+						"included_files": [],
+						"md5": "",
+						"name": "some-file-name",
+						"platforms": [],
+						"released_at": "2017-01-01",
+						"sha256": "",
+						"system_requirements": []
+					}
+				}`),
+				ghttp.RespondWith(http.StatusOK, `{"product_file":{"id":1234}}`),
+			),
+		)
+
+		_, err := client.ProductFiles.UpdateFields(productSlug, productFile, pivnet.ProductFileFields...)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("when no fields are given", func() {
+		It("returns an error without making a request", func() {
+			_, err := client.ProductFiles.UpdateFields(productSlug, productFile)
+			Expect(err).To(MatchError("no fields given to update product file 1234"))
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when a field cannot be updated", func() {
+		It("returns an error without making a request", func() {
+			_, err := client.ProductFiles.UpdateFields(productSlug, productFile, "aws_object_key")
+			Expect(err).To(MatchError(`product file field "aws_object_key" cannot be updated`))
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when the server responds with a non-200 status code", func() {
+		It("returns an error", func() {
+			server.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+			)
+
+			_, err := client.ProductFiles.UpdateFields(productSlug, productFile, pivnet.ProductFileFieldName)
+			Expect(err).To(MatchError(ContainSubstring("foo message")))
+		})
+	})
+})
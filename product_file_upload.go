@@ -0,0 +1,118 @@
+package pivnet
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/upload"
+)
+
+type UploadProductFileConfig struct {
+	// FilePath is the local file uploaded to the product's bucket under
+	// AWSObjectKey.
+	FilePath string
+
+	// CreateProductFileConfig describes the product file record. Its
+	// checksums are computed from the file when unset.
+	CreateProductFileConfig
+}
+
+// Upload uploads a local file to the product's bucket with credentials from
+// a federation token, then creates the product file record for it.
+//
+// The file is uploaded in parts, several at a time. If the upload fails or
+// ctx is cancelled, calling Upload again with the same AWS object key resumes
+// it, skipping the parts already uploaded.
+func (p ProductFilesService) Upload(ctx context.Context, config UploadProductFileConfig) (ProductFile, error) {
+	createConfig, err := p.uploadProductFileObject(ctx, config)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	return p.Create(createConfig)
+}
+
+// uploadProductFileObject validates the config, filling in its checksums,
+// and uploads the file, returning the config for the product file record.
+func (p ProductFilesService) uploadProductFileObject(ctx context.Context, config UploadProductFileConfig) (CreateProductFileConfig, error) {
+	if config.AWSObjectKey == "" {
+		return CreateProductFileConfig{}, fmt.Errorf("AWS object key must not be empty")
+	}
+
+	if config.MD5 == "" || config.SHA256 == "" {
+		md5Sum, sha256Sum, err := fileChecksums(config.FilePath)
+		if err != nil {
+			return CreateProductFileConfig{}, err
+		}
+
+		if config.MD5 == "" {
+			config.MD5 = md5Sum
+		}
+
+		if config.SHA256 == "" {
+			config.SHA256 = sha256Sum
+		}
+	}
+
+	err := config.CreateProductFileConfig.Validate()
+	if err != nil {
+		return CreateProductFileConfig{}, err
+	}
+
+	err = p.uploadObject(ctx, config.ProductSlug, config.FilePath, config.AWSObjectKey)
+	if err != nil {
+		return CreateProductFileConfig{}, err
+	}
+
+	return config.CreateProductFileConfig, nil
+}
+
+// uploadObject uploads a local file to the product's bucket with
+// credentials from a federation token, resuming an earlier upload of the
+// same AWS object key.
+func (p ProductFilesService) uploadObject(ctx context.Context, productSlug string, filePath string, awsObjectKey string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	token, err := FederationTokenService{client: p.client}.GenerateFederationToken(productSlug)
+	if err != nil {
+		return err
+	}
+
+	uploader := upload.New(p.client.newObjectStore(token))
+	if p.client.uploadPartSize > 0 {
+		uploader.PartSize = p.client.uploadPartSize
+	}
+	if p.client.uploadConcurrency > 0 {
+		uploader.Concurrency = p.client.uploadConcurrency
+	}
+
+	p.client.logger.Info("Uploading product file", logger.Data{
+		"filePath":     filePath,
+		"awsObjectKey": awsObjectKey,
+		"bucket":       token.Bucket,
+		"size":         stat.Size(),
+	})
+
+	return uploader.Upload(ctx, awsObjectKey, f, stat.Size())
+}
+
+func newS3ObjectStore(token FederationToken) upload.ObjectStore {
+	return upload.NewS3(upload.S3Config{
+		Region:          token.Region,
+		Bucket:          token.Bucket,
+		AccessKeyID:     token.AccessKeyID,
+		SecretAccessKey: token.SecretAccessKey,
+		SessionToken:    token.SessionToken,
+	})
+}
@@ -0,0 +1,190 @@
+package pivnet_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+	"github.com/pivotal-cf/go-pivnet/upload"
+	"github.com/pivotal-cf/go-pivnet/upload/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - upload product file", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+		store  *fakes.ObjectStore
+
+		tokens  []pivnet.FederationToken
+		content []byte
+		file    *os.File
+
+		mutex         sync.Mutex
+		uploadedParts map[int][]byte
+		createdBody   map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		store = &fakes.ObjectStore{}
+		tokens = nil
+
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:           server.URL(),
+			Token:          "my-auth-token",
+			UserAgent:      "pivnet-resource/0.1.0 (some-url)",
+			UploadPartSize: upload.MinPartSize,
+			NewObjectStore: func(token pivnet.FederationToken) upload.ObjectStore {
+				tokens = append(tokens, token)
+				return store
+			},
+		}, &loggerfakes.FakeLogger{})
+
+		content = make([]byte, upload.MinPartSize+10)
+		for i := range content {
+			content[i] = byte(i % 251)
+		}
+
+		var err error
+		file, err = ioutil.TempFile("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = file.Write(content)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		uploadedParts = make(map[int][]byte)
+		store.CreateMultipartUploadReturns("some-upload-id", nil)
+		store.UploadPartStub = func(ctx context.Context, key string, uploadID string, partNumber int, body io.ReadSeeker, size int64) (string, error) {
+			b, err := ioutil.ReadAll(body)
+			Expect(err).NotTo(HaveOccurred())
+
+			mutex.Lock()
+			uploadedParts[partNumber] = b
+			mutex.Unlock()
+
+			return fmt.Sprintf(`"%x"`, md5.Sum(b)), nil
+		}
+
+		server.RouteToHandler("POST", apiPrefix+"/federation_token",
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FederationToken{
+				AccessKeyID: "some-access-key-id",
+				Bucket:      "some-bucket",
+				Region:      "some-region",
+			}),
+		)
+
+		createdBody = nil
+		server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+			func(w http.ResponseWriter, req *http.Request) {
+				Expect(json.NewDecoder(req.Body).Decode(&createdBody)).To(Succeed())
+
+				w.WriteHeader(http.StatusCreated)
+				Expect(json.NewEncoder(w).Encode(pivnet.ProductFileResponse{
+					ProductFile: pivnet.ProductFile{ID: 1234, AWSObjectKey: "product-files/some-product/some-file"},
+				})).To(Succeed())
+			},
+		)
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.Remove(file.Name())
+	})
+
+	It("uploads the file with the product's federation token and creates the product file", func() {
+		productFile, err := client.ProductFiles.Upload(context.Background(), pivnet.UploadProductFileConfig{
+			FilePath: file.Name(),
+			CreateProductFileConfig: pivnet.CreateProductFileConfig{
+				ProductSlug:  productSlug,
+				AWSObjectKey: "product-files/some-product/some-file",
+				Name:         "Some File",
+				FileVersion:  "1.0",
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(productFile.ID).To(Equal(1234))
+
+		Expect(tokens).To(Equal([]pivnet.FederationToken{{
+			AccessKeyID: "some-access-key-id",
+			Bucket:      "some-bucket",
+			Region:      "some-region",
+		}}))
+
+		Expect(uploadedParts).To(HaveLen(2))
+		Expect(append(uploadedParts[1], uploadedParts[2]...)).To(Equal(content))
+
+		_, key := store.CreateMultipartUploadArgsForCall(0)
+		Expect(key).To(Equal("product-files/some-product/some-file"))
+		Expect(store.CompleteMultipartUploadCallCount()).To(Equal(1))
+
+		productFileBody := createdBody["product_file"].(map[string]interface{})
+		Expect(productFileBody["md5"]).To(Equal(fmt.Sprintf("%x", md5.Sum(content))))
+		Expect(productFileBody["name"]).To(Equal("Some File"))
+	})
+
+	Context("when the upload fails", func() {
+		BeforeEach(func() {
+			store.CompleteMultipartUploadReturns(errors.New("some upload error"))
+		})
+
+		It("returns the error without creating the product file", func() {
+			_, err := client.ProductFiles.Upload(context.Background(), pivnet.UploadProductFileConfig{
+				FilePath: file.Name(),
+				CreateProductFileConfig: pivnet.CreateProductFileConfig{
+					ProductSlug:  productSlug,
+					AWSObjectKey: "product-files/some-product/some-file",
+					Name:         "Some File",
+				},
+			})
+			Expect(err).To(MatchError("some upload error"))
+
+			Expect(createdBody).To(BeNil())
+		})
+	})
+
+	Context("when the AWS object key is empty", func() {
+		It("returns an error without uploading", func() {
+			_, err := client.ProductFiles.Upload(context.Background(), pivnet.UploadProductFileConfig{
+				FilePath: file.Name(),
+				CreateProductFileConfig: pivnet.CreateProductFileConfig{
+					ProductSlug: productSlug,
+				},
+			})
+			Expect(err).To(MatchError("AWS object key must not be empty"))
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when the config is invalid", func() {
+		It("returns an error without uploading", func() {
+			_, err := client.ProductFiles.Upload(context.Background(), pivnet.UploadProductFileConfig{
+				FilePath: file.Name(),
+				CreateProductFileConfig: pivnet.CreateProductFileConfig{
+					ProductSlug:  productSlug,
+					AWSObjectKey: "product-files/some-product/some-file",
+					Name:         "Some File",
+					FileType:     "Sofware",
+				},
+			})
+			Expect(err).To(BeAssignableToTypeOf(pivnet.ErrInvalidConfig{}))
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+			Expect(store.CreateMultipartUploadCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -2,12 +2,24 @@ package pivnet
 
 import (
 	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/pivotal-cf/go-pivnet/cache"
+	"github.com/pivotal-cf/go-pivnet/download"
 	"github.com/pivotal-cf/go-pivnet/logger"
 )
 
@@ -20,10 +32,11 @@ type CreateProductFileConfig struct {
 	AWSObjectKey       string
 	Description        string
 	DocsURL            string
-	FileType           string
+	FileType           FileType
 	FileVersion        string
 	IncludedFiles      []string
 	MD5                string
+	SHA256             string
 	Name               string
 	Platforms          []string
 	ReleasedAt         string
@@ -44,7 +57,7 @@ type ProductFile struct {
 	Description        string   `json:"description,omitempty" yaml:"description,omitempty"`
 	DocsURL            string   `json:"docs_url,omitempty" yaml:"docs_url,omitempty"`
 	FileTransferStatus string   `json:"file_transfer_status,omitempty" yaml:"file_transfer_status,omitempty"`
-	FileType           string   `json:"file_type,omitempty" yaml:"file_type,omitempty"`
+	FileType           FileType `json:"file_type,omitempty" yaml:"file_type,omitempty"`
 	FileVersion        string   `json:"file_version,omitempty" yaml:"file_version,omitempty"`
 	HasSignatureFile   bool     `json:"has_signature_file,omitempty" yaml:"has_signature_file,omitempty"`
 	IncludedFiles      []string `json:"included_files,omitempty" yaml:"included_files,omitempty"`
@@ -53,8 +66,10 @@ type ProductFile struct {
 	Platforms          []string `json:"platforms,omitempty" yaml:"platforms,omitempty"`
 	ReadyToServe       bool     `json:"ready_to_serve,omitempty" yaml:"ready_to_serve,omitempty"`
 	ReleasedAt         string   `json:"released_at,omitempty" yaml:"released_at,omitempty"`
+	SHA256             string   `json:"sha256,omitempty" yaml:"sha256,omitempty"`
 	Size               int      `json:"size,omitempty" yaml:"size,omitempty"`
 	SystemRequirements []string `json:"system_requirements,omitempty" yaml:"system_requirements,omitempty"`
+	UpdatedAt          string   `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
 	Links              *Links   `json:"_links,omitempty" yaml:"_links,omitempty"`
 }
 
@@ -66,12 +81,102 @@ func (p ProductFile) DownloadLink() (string, error) {
 	return p.Links.Download["href"], nil
 }
 
+// CacheKey returns the key identifying the product file in a download cache,
+// preferring the strongest available checksum. It returns false if the
+// product file carries nothing that identifies its contents.
+func (p ProductFile) CacheKey() (cache.Key, bool) {
+	switch {
+	case p.SHA256 != "":
+		return cache.Key{Algorithm: cache.AlgorithmSHA256, Value: p.SHA256, Size: int64(p.Size)}, true
+	case p.MD5 != "":
+		return cache.Key{Algorithm: cache.AlgorithmMD5, Value: p.MD5, Size: int64(p.Size)}, true
+	case p.ID != 0 && p.UpdatedAt != "":
+		return cache.Key{
+			Algorithm: cache.AlgorithmNone,
+			Value:     fmt.Sprintf("%d-%s", p.ID, p.UpdatedAt),
+			Size:      int64(p.Size),
+		}, true
+	default:
+		return cache.Key{}, false
+	}
+}
+
+// Verify checks that the contents of location match the size and checksums
+// recorded for the product file. Checks for which the product file carries
+// no value are skipped.
+func (p ProductFile) Verify(location *os.File) error {
+	stat, err := location.Stat()
+	if err != nil {
+		return err
+	}
+
+	if p.Size > 0 && stat.Size() != int64(p.Size) {
+		return fmt.Errorf(
+			"size mismatch for %s: expected %d bytes, got %d",
+			location.Name(),
+			p.Size,
+			stat.Size(),
+		)
+	}
+
+	if p.SHA256 == "" && p.MD5 == "" {
+		return nil
+	}
+
+	sha256Hash := sha256.New()
+	md5Hash := md5.New()
+
+	_, err = io.Copy(
+		io.MultiWriter(sha256Hash, md5Hash),
+		io.NewSectionReader(location, 0, stat.Size()),
+	)
+	if err != nil {
+		return err
+	}
+
+	if p.SHA256 != "" {
+		actual := hex.EncodeToString(sha256Hash.Sum(nil))
+		if !strings.EqualFold(actual, p.SHA256) {
+			return fmt.Errorf(
+				"SHA256 mismatch for %s: expected %s, got %s",
+				location.Name(),
+				p.SHA256,
+				actual,
+			)
+		}
+	}
+
+	if p.MD5 != "" {
+		actual := hex.EncodeToString(md5Hash.Sum(nil))
+		if !strings.EqualFold(actual, p.MD5) {
+			return fmt.Errorf(
+				"MD5 mismatch for %s: expected %s, got %s",
+				location.Name(),
+				p.MD5,
+				actual,
+			)
+		}
+	}
+
+	return nil
+}
+
+// FileType is the kind of content a product file holds.
+type FileType string
+
 const (
-	FileTypeSoftware          = "Software"
-	FileTypeDocumentation     = "Documentation"
-	FileTypeOpenSourceLicense = "Open Source License"
+	FileTypeSoftware          FileType = "Software"
+	FileTypeDocumentation     FileType = "Documentation"
+	FileTypeOpenSourceLicense FileType = "Open Source License"
 )
 
+// FileTypes lists every file type a product file may have.
+var FileTypes = []FileType{
+	FileTypeSoftware,
+	FileTypeDocumentation,
+	FileTypeOpenSourceLicense,
+}
+
 func (p ProductFilesService) List(productSlug string) ([]ProductFile, error) {
 	url := fmt.Sprintf("/products/%s/product_files", productSlug)
 
@@ -193,6 +298,7 @@ func (p ProductFilesService) Create(config CreateProductFileConfig) (ProductFile
 			FileVersion:        config.FileVersion,
 			IncludedFiles:      config.IncludedFiles,
 			MD5:                config.MD5,
+			SHA256:             config.SHA256,
 			Name:               config.Name,
 			Platforms:          config.Platforms,
 			ReleasedAt:         config.ReleasedAt,
@@ -227,19 +333,32 @@ func (p ProductFilesService) Create(config CreateProductFileConfig) (ProductFile
 	return response.ProductFile, nil
 }
 
+// Update sets the mutable fields of a product file that are set on
+// productFile; fields left empty are not changed. Use UpdateFields to clear a
+// field.
 func (p ProductFilesService) Update(productSlug string, productFile ProductFile) (ProductFile, error) {
-	url := fmt.Sprintf("/products/%s/product_files/%d", productSlug, productFile.ID)
-
 	body := createUpdateProductFileBody{
 		ProductFile: ProductFile{
-			Description: productFile.Description,
-			FileType:    productFile.FileType,
-			FileVersion: productFile.FileVersion,
-			MD5:         productFile.MD5,
-			Name:        productFile.Name,
+			Description:        productFile.Description,
+			DocsURL:            productFile.DocsURL,
+			FileType:           productFile.FileType,
+			FileVersion:        productFile.FileVersion,
+			IncludedFiles:      productFile.IncludedFiles,
+			MD5:                productFile.MD5,
+			Name:               productFile.Name,
+			Platforms:          productFile.Platforms,
+			ReleasedAt:         productFile.ReleasedAt,
+			SHA256:             productFile.SHA256,
+			SystemRequirements: productFile.SystemRequirements,
 		},
 	}
 
+	return p.patch(productSlug, productFile.ID, body)
+}
+
+func (p ProductFilesService) patch(productSlug string, productFileID int, body interface{}) (ProductFile, error) {
+	url := fmt.Sprintf("/products/%s/product_files/%d", productSlug, productFileID)
+
 	b, err := json.Marshal(body)
 	if err != nil {
 		// Untested as we cannot force an error because we are marshalling
@@ -450,6 +569,12 @@ func (p ProductFilesService) RemoveFromFileGroup(
 	return nil
 }
 
+// DownloadForRelease downloads a product file into location. The contents
+// are only read back and checked against the product file's size and
+// checksums when a download cache is configured, so location may be opened
+// write-only otherwise. Use DownloadForReleaseToPath for a download that is
+// always verified, or DownloadForReleaseToPathWithManifest to also record
+// the download in a manifest.
 func (p ProductFilesService) DownloadForRelease(
 	location *os.File,
 	productSlug string,
@@ -466,41 +591,315 @@ func (p ProductFilesService) DownloadForRelease(
 		return err
 	}
 
-	downloadLink, err := pf.DownloadLink()
+	_, err = p.download(
+		context.Background(),
+		location,
+		productSlug,
+		releaseID,
+		pf,
+		false,
+		p.client.downloader,
+		p.client.newProgressListener(progressWriter),
+	)
+	return err
+}
+
+// DownloadForReleaseToPath downloads a product file to filePath without ever
+// leaving a partial file there. The contents are written to a temporary file
+// in the same directory, verified against the product file's size and
+// checksums, synced to disk and then renamed into place. The temporary file
+// is removed if the download fails or ctx is cancelled. Contents taken from
+// the download cache are copied rather than linked, so the file at filePath
+// may be modified without affecting the cache.
+func (p ProductFilesService) DownloadForReleaseToPath(
+	ctx context.Context,
+	filePath string,
+	productSlug string,
+	releaseID int,
+	productFileID int,
+	progressWriter io.Writer,
+) error {
+	pf, err := p.GetForRelease(
+		productSlug,
+		releaseID,
+		productFileID,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.downloadToPath(
+		ctx,
+		filePath,
+		productSlug,
+		releaseID,
+		pf,
+		p.client.downloader,
+		p.client.newProgressListener(progressWriter),
+	)
+	return err
+}
+
+// DownloadForReleaseToPathWithManifest downloads a product file as
+// DownloadForReleaseToPath does, then writes a manifest recording it to
+// manifestPath, as YAML if it ends in .yml or .yaml and as JSON otherwise.
+func (p ProductFilesService) DownloadForReleaseToPathWithManifest(
+	ctx context.Context,
+	filePath string,
+	manifestPath string,
+	productSlug string,
+	releaseID int,
+	productFileID int,
+	progressWriter io.Writer,
+) error {
+	pf, err := p.GetForRelease(
+		productSlug,
+		releaseID,
+		productFileID,
+	)
 	if err != nil {
 		return err
 	}
 
+	sourceHost, err := p.downloadToPath(
+		ctx,
+		filePath,
+		productSlug,
+		releaseID,
+		pf,
+		p.client.downloader,
+		p.client.newProgressListener(progressWriter),
+	)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := newReleaseManifest(p.client, productSlug, releaseID)
+	if err != nil {
+		return err
+	}
+
+	jobs := []downloadJob{{
+		productSlug: productSlug,
+		releaseID:   releaseID,
+		productFile: pf,
+		path:        filePath,
+	}}
+
+	results := []downloadResult{{
+		sourceHost:   sourceHost,
+		downloadedAt: time.Now().UTC(),
+	}}
+
+	return writeManifest(manifest, manifestPath, jobs, results)
+}
+
+// downloadToPath returns the host the file was downloaded from, or an empty
+// string if it came from the download cache.
+func (p ProductFilesService) downloadToPath(
+	ctx context.Context,
+	filePath string,
+	productSlug string,
+	releaseID int,
+	pf ProductFile,
+	downloader download.Client,
+	listener download.ProgressListener,
+) (string, error) {
+	dir, base := filepath.Split(filePath)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := ioutil.TempFile(dir, fmt.Sprintf(".%s.tmp-", base))
+	if err != nil {
+		return "", err
+	}
+
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	sourceHost, err := p.download(ctx, tmp, productSlug, releaseID, pf, true, downloader, listener)
+	if err != nil {
+		return "", err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		return "", err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(tmp.Name(), filePath)
+	if err != nil {
+		return "", err
+	}
+
+	succeeded = true
+
+	return sourceHost, nil
+}
+
+// download checks the contents against the product file's size and
+// checksums as they are read from the download cache. Fresh downloads are
+// checked when verify is set or before they are added to the cache. It
+// returns the host the file was downloaded from, or an empty string if it
+// came from the download cache.
+func (p ProductFilesService) download(
+	ctx context.Context,
+	location *os.File,
+	productSlug string,
+	releaseID int,
+	pf ProductFile,
+	verify bool,
+	downloader download.Client,
+	listener download.ProgressListener,
+) (string, error) {
+	downloadLink, err := pf.DownloadLink()
+	if err != nil {
+		return "", err
+	}
+
+	cacheKey, cacheable := pf.CacheKey()
+	cacheable = cacheable && p.client.downloadCache != nil
+
+	if cacheable {
+		found, err := p.client.downloadCache.Get(cacheKey, location)
+		if err != nil {
+			return "", err
+		}
+
+		if found {
+			p.client.logger.Debug("Using cached file", logger.Data{"cacheKey": cacheKey})
+			return "", nil
+		}
+	}
+
 	p.client.logger.Debug("Downloading file", logger.Data{"downloadLink": downloadLink})
 
-	p.client.HTTP.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+	fetcher := &hostRecordingLinkFetcher{
+		fetcher: NewProductFileLinkFetcher(downloadLink, p.client),
+	}
+
+	downloader.Preflight = func(location *os.File, contentLength int64) error {
+		return p.preflight(pf, location, contentLength)
+	}
+
+	err = downloader.GetWithContext(ctx, location, fetcher, listener)
+	if _, ok := err.(ErrUnavailableForLegalReasons); ok {
+		accepted, acceptErr := p.acceptEULA(productSlug, releaseID)
+		if acceptErr != nil {
+			return "", acceptErr
+		}
+
+		if accepted {
+			err = downloader.GetWithContext(ctx, location, fetcher, listener)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Only verified contents may be cached, since later downloads trust
+	// the cache rather than the server
+	if verify || cacheable {
+		err = pf.Verify(location)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if cacheable {
+		// The download has succeeded, so failing to populate the cache is
+		// not fatal.
+		err = p.client.downloadCache.Put(cacheKey, location)
+		if err != nil {
+			p.client.logger.Info("Failed to cache file", logger.Data{"cacheKey": cacheKey, "error": err.Error()})
+		}
+	}
+
+	return fetcher.Host(), nil
+}
+
+// ProductFileLinkFetcher exchanges a product file download link for a signed
+// URL to the file contents. Each call returns a freshly signed URL.
+type ProductFileLinkFetcher struct {
+	downloadLink string
+	client       Client
+}
+
+func NewProductFileLinkFetcher(downloadLink string, client Client) ProductFileLinkFetcher {
+	return ProductFileLinkFetcher{
+		downloadLink: downloadLink,
+		client:       client,
+	}
+}
+
+func (p ProductFileLinkFetcher) NewDownloadLink() (string, error) {
+	// Copy the HTTP client so that disabling redirects does not affect
+	// requests made concurrently through the original client.
+	httpClient := *p.client.HTTP
+	httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		return http.ErrUseLastResponse
 	}
 
-	resp, err := p.client.MakeRequest(
+	client := p.client
+	client.HTTP = &httpClient
+
+	resp, err := client.MakeRequest(
 		"POST",
-		downloadLink,
+		p.downloadLink,
 		http.StatusFound,
 		nil,
 	)
 	if err != nil {
-		// Untested as we cannot force CreateRequest to return an error.
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	p.client.HTTP.CheckRedirect = nil
+	location := resp.Header.Get("Location")
 
-	p.client.logger.Debug("Fetching File", logger.Data{"location": resp.Header.Get("Location")})
+	p.client.logger.Debug("Fetching File", logger.Data{"location": location})
 
-	err = p.client.downloader.Get(
-		location,
-		resp.Header.Get("Location"),
-		progressWriter,
-	)
+	return location, nil
+}
+
+// hostRecordingLinkFetcher remembers the host of the most recent signed URL
+// so that the source of a download can be reported.
+type hostRecordingLinkFetcher struct {
+	fetcher ProductFileLinkFetcher
+
+	mutex sync.Mutex
+	host  string
+}
+
+func (f *hostRecordingLinkFetcher) NewDownloadLink() (string, error) {
+	link, err := f.fetcher.NewDownloadLink()
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	u, err := url.Parse(link)
+	if err == nil {
+		f.mutex.Lock()
+		f.host = u.Host
+		f.mutex.Unlock()
+	}
+
+	return link, nil
+}
+
+func (f *hostRecordingLinkFetcher) Host() string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.host
 }
@@ -1,14 +1,24 @@
 package pivnet_test
 
 import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"regexp"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/onsi/gomega/ghttp"
 	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/cache"
+	"github.com/pivotal-cf/go-pivnet/download"
+	"github.com/pivotal-cf/go-pivnet/download/fakes"
 	"github.com/pivotal-cf/go-pivnet/logger"
 	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
 
@@ -440,6 +450,7 @@ var _ = Describe("PivnetClient - product files", func() {
 				FileVersion:        "some-file-version",
 				IncludedFiles:      []string{"file1", "file2"},
 				MD5:                "some-md5",
+				SHA256:             "some-sha256",
 				Name:               "some-file-name",
 				Platforms:          []string{"platform-1", "platform-2"},
 				ReleasedAt:         "released-at",
@@ -455,6 +466,7 @@ var _ = Describe("PivnetClient - product files", func() {
 					FileVersion:        createProductFileConfig.FileVersion,
 					IncludedFiles:      createProductFileConfig.IncludedFiles,
 					MD5:                createProductFileConfig.MD5,
+					SHA256:             createProductFileConfig.SHA256,
 					Name:               createProductFileConfig.Name,
 					Platforms:          createProductFileConfig.Platforms,
 					ReleasedAt:         createProductFileConfig.ReleasedAt,
@@ -472,6 +484,7 @@ var _ = Describe("PivnetClient - product files", func() {
 					FileVersion:        createProductFileConfig.FileVersion,
 					IncludedFiles:      createProductFileConfig.IncludedFiles,
 					MD5:                createProductFileConfig.MD5,
+					SHA256:             createProductFileConfig.SHA256,
 					Name:               createProductFileConfig.Name,
 					Platforms:          createProductFileConfig.Platforms,
 					ReleasedAt:         createProductFileConfig.ReleasedAt,
@@ -578,21 +591,35 @@ var _ = Describe("PivnetClient - product files", func() {
 
 		BeforeEach(func() {
 			productFile = pivnet.ProductFile{
-				ID:          1234,
-				Description: "some-description",
-				FileVersion: "some-file-version",
-				FileType:    "some-file-type",
-				MD5:         "some-md5",
-				Name:        "some-file-name",
+				ID:                 1234,
+				AWSObjectKey:       "some-aws-object-key",
+				Description:        "some-description",
+				DocsURL:            "some-docs-url",
+				FileVersion:        "some-file-version",
+				FileType:           "some-file-type",
+				IncludedFiles:      []string{"some-included-file"},
+				MD5:                "some-md5",
+				Name:               "some-file-name",
+				Platforms:          []string{"some-platform"},
+				ReleasedAt:         "2017-01-01",
+				SHA256:             "some-sha256",
+				Size:               1024,
+				SystemRequirements: []string{"some-system-requirement"},
 			}
 
 			expectedRequestBody = requestBody{
 				ProductFile: pivnet.ProductFile{
-					Description: productFile.Description,
-					FileType:    productFile.FileType,
-					FileVersion: productFile.FileVersion,
-					MD5:         productFile.MD5,
-					Name:        productFile.Name,
+					Description:        productFile.Description,
+					DocsURL:            productFile.DocsURL,
+					FileType:           productFile.FileType,
+					FileVersion:        productFile.FileVersion,
+					IncludedFiles:      productFile.IncludedFiles,
+					MD5:                productFile.MD5,
+					Name:               productFile.Name,
+					Platforms:          productFile.Platforms,
+					ReleasedAt:         productFile.ReleasedAt,
+					SHA256:             productFile.SHA256,
+					SystemRequirements: productFile.SystemRequirements,
 				},
 			}
 		})
@@ -1087,6 +1114,101 @@ var _ = Describe("PivnetClient - product files", func() {
 				})
 			})
 		})
+
+		Describe("Verify", func() {
+			var (
+				location *os.File
+				contents []byte
+			)
+
+			BeforeEach(func() {
+				contents = []byte("some file contents")
+
+				var err error
+				location, err = ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = location.Write(contents)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				location.Close()
+				os.Remove(location.Name())
+			})
+
+			It("succeeds when the size and checksums match", func() {
+				md5Sum := md5.Sum(contents)
+				sha256Sum := sha256.Sum256(contents)
+
+				productFile = pivnet.ProductFile{
+					Size:   len(contents),
+					MD5:    hex.EncodeToString(md5Sum[:]),
+					SHA256: hex.EncodeToString(sha256Sum[:]),
+				}
+
+				err := productFile.Verify(location)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("succeeds when there is nothing to verify", func() {
+				err := productFile.Verify(location)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			Context("when the size does not match", func() {
+				It("returns an error", func() {
+					productFile = pivnet.ProductFile{Size: 1}
+
+					err := productFile.Verify(location)
+					Expect(err).To(MatchError(ContainSubstring("size mismatch")))
+				})
+			})
+
+			Context("when the SHA256 does not match", func() {
+				It("returns an error", func() {
+					productFile = pivnet.ProductFile{SHA256: "some-sha256"}
+
+					err := productFile.Verify(location)
+					Expect(err).To(MatchError(ContainSubstring("SHA256 mismatch")))
+				})
+			})
+		})
+
+		Describe("CacheKey", func() {
+			It("prefers the SHA256 checksum", func() {
+				productFile = pivnet.ProductFile{ID: 1234, MD5: "some-md5", SHA256: "some-sha256", Size: 100}
+
+				key, ok := productFile.CacheKey()
+				Expect(ok).To(BeTrue())
+				Expect(key).To(Equal(cache.Key{Algorithm: cache.AlgorithmSHA256, Value: "some-sha256", Size: 100}))
+			})
+
+			It("falls back to the MD5 checksum", func() {
+				productFile = pivnet.ProductFile{ID: 1234, MD5: "some-md5"}
+
+				key, ok := productFile.CacheKey()
+				Expect(ok).To(BeTrue())
+				Expect(key).To(Equal(cache.Key{Algorithm: cache.AlgorithmMD5, Value: "some-md5"}))
+			})
+
+			It("falls back to the ID and update time", func() {
+				productFile = pivnet.ProductFile{ID: 1234, UpdatedAt: "2017-01-01T00:00:00Z", Size: 100}
+
+				key, ok := productFile.CacheKey()
+				Expect(ok).To(BeTrue())
+				Expect(key).To(Equal(cache.Key{Algorithm: cache.AlgorithmNone, Value: "1234-2017-01-01T00:00:00Z", Size: 100}))
+			})
+
+			Context("when nothing identifies the contents", func() {
+				It("returns false", func() {
+					productFile = pivnet.ProductFile{ID: 1234}
+
+					_, ok := productFile.CacheKey()
+					Expect(ok).To(BeFalse())
+				})
+			})
+		})
 	})
 
 	Describe("DownloadForRelease", func() {
@@ -1104,12 +1226,16 @@ var _ = Describe("PivnetClient - product files", func() {
 			getResponse   interface{}
 
 			downloadLinkResponseStatusCode int
+
+			headContentLength string
 		)
 
 		BeforeEach(func() {
 			releaseID = 1234
 			productFileID = 2345
 
+			headContentLength = "18"
+
 			downloadLink = "/some/download/link"
 
 			downloadLinkResponseBody = []byte("some file contents")
@@ -1175,33 +1301,13 @@ var _ = Describe("PivnetClient - product files", func() {
 					ghttp.VerifyRequest("HEAD", "/download"),
 					ghttp.RespondWith(http.StatusOK, nil,
 						http.Header{
-							"Content-Length": []string{"18"},
+							"Content-Length": []string{headContentLength},
 						},
 					),
 				),
 			)
 
-			cloudfront.RouteToHandler("GET", "/download", ghttp.CombineHandlers(
-				http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-					ex := regexp.MustCompile(`bytes=(\d+)-(\d+)`)
-					matches := ex.FindStringSubmatch(req.Header.Get("Range"))
-
-					start, err := strconv.Atoi(matches[1])
-					if err != nil {
-						Fail(err.Error())
-					}
-
-					end, err := strconv.Atoi(matches[2])
-					if err != nil {
-						Fail(err.Error())
-					}
-
-					w.WriteHeader(http.StatusPartialContent)
-					_, err = w.Write(downloadLinkResponseBody[start : end+1])
-					Expect(err).NotTo(HaveOccurred())
-				}),
-			),
-			)
+			cloudfront.RouteToHandler("GET", "/download", serveRanges(downloadLinkResponseBody))
 		})
 
 		It("writes file contents to provided writer", func() {
@@ -1223,6 +1329,542 @@ var _ = Describe("PivnetClient - product files", func() {
 			Expect(contents).To(Equal(downloadLinkResponseBody))
 		})
 
+		Context("when the product file has a checksum", func() {
+			BeforeEach(func() {
+				sha256Sum := sha256.Sum256(downloadLinkResponseBody)
+				productFile := getResponse.(pivnet.ProductFileResponse)
+				productFile.ProductFile.SHA256 = hex.EncodeToString(sha256Sum[:])
+				getResponse = productFile
+			})
+
+			It("writes to a file opened write-only without reading it back", func() {
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.Remove(tmpFile.Name())
+
+				err = tmpFile.Close()
+				Expect(err).NotTo(HaveOccurred())
+
+				location, err := os.OpenFile(tmpFile.Name(), os.O_WRONLY, 0)
+				Expect(err).NotTo(HaveOccurred())
+				defer location.Close()
+
+				err = client.ProductFiles.DownloadForRelease(
+					location,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := ioutil.ReadFile(tmpFile.Name())
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(contents).To(Equal(downloadLinkResponseBody))
+			})
+		})
+
+		Describe("to a path", func() {
+			var (
+				dir string
+			)
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				err := os.RemoveAll(dir)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("writes the file contents to the path, leaving no temporary files", func() {
+				filePath := filepath.Join(dir, "some-file")
+
+				err := client.ProductFiles.DownloadForReleaseToPath(
+					context.Background(),
+					filePath,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := ioutil.ReadFile(filePath)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(contents).To(Equal(downloadLinkResponseBody))
+
+				entries, err := ioutil.ReadDir(dir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entries).To(HaveLen(1))
+			})
+
+			Context("when a manifest path is given", func() {
+				JustBeforeEach(func() {
+					server.AppendHandlers(
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest(
+								"GET",
+								fmt.Sprintf("%s/products/%s/releases/%d", apiPrefix, productSlug, releaseID),
+							),
+							ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.Release{ID: releaseID, Version: "1.0.0"}),
+						),
+					)
+				})
+
+				It("writes a manifest recording the downloaded file", func() {
+					filePath := filepath.Join(dir, "some-file")
+					manifestPath := filepath.Join(dir, "manifest.yml")
+
+					err := client.ProductFiles.DownloadForReleaseToPathWithManifest(
+						context.Background(),
+						filePath,
+						manifestPath,
+						productSlug,
+						releaseID,
+						productFileID,
+						GinkgoWriter,
+					)
+					Expect(err).NotTo(HaveOccurred())
+
+					manifest, err := pivnet.ReadManifest(manifestPath)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(manifest.ReleaseVersion).To(Equal("1.0.0"))
+					Expect(manifest.Files).To(HaveLen(1))
+					Expect(manifest.Files[0].ProductFileID).To(Equal(1234))
+					Expect(manifest.Files[0].Path).To(Equal("some-file"))
+					Expect(manifest.Files[0].Size).To(Equal(int64(len(downloadLinkResponseBody))))
+					Expect(manifest.Files[0].SourceHost).To(Equal(strings.TrimPrefix(cloudfront.URL(), "http://")))
+
+					Expect(manifest.Verify(dir)).To(Succeed())
+				})
+			})
+
+			Context("when the downloaded contents do not match the checksum", func() {
+				BeforeEach(func() {
+					getResponse = pivnet.ProductFileResponse{
+						pivnet.ProductFile{
+							ID:           1234,
+							AWSObjectKey: "something",
+							MD5:          "not-the-md5",
+							Links: &pivnet.Links{
+								Download: map[string]string{
+									"href": downloadLink,
+								},
+							},
+						},
+					}
+				})
+
+				It("returns an error and leaves nothing at the path", func() {
+					filePath := filepath.Join(dir, "some-file")
+
+					err := client.ProductFiles.DownloadForReleaseToPath(
+						context.Background(),
+						filePath,
+						productSlug,
+						releaseID,
+						productFileID,
+						GinkgoWriter,
+					)
+					Expect(err).To(MatchError(ContainSubstring("MD5 mismatch")))
+
+					entries, err := ioutil.ReadDir(dir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(entries).To(BeEmpty())
+				})
+			})
+
+			Context("when the context is cancelled", func() {
+				It("returns an error and leaves nothing at the path", func() {
+					filePath := filepath.Join(dir, "some-file")
+
+					ctx, cancel := context.WithCancel(context.Background())
+					cancel()
+
+					err := client.ProductFiles.DownloadForReleaseToPath(
+						ctx,
+						filePath,
+						productSlug,
+						releaseID,
+						productFileID,
+						GinkgoWriter,
+					)
+					Expect(err).To(HaveOccurred())
+
+					entries, err := ioutil.ReadDir(dir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(entries).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("when the download server reports a different size to the product file", func() {
+			BeforeEach(func() {
+				getResponse = pivnet.ProductFileResponse{
+					pivnet.ProductFile{
+						ID:           1234,
+						AWSObjectKey: "something",
+						Size:         20,
+						Links: &pivnet.Links{
+							Download: map[string]string{
+								"href": downloadLink,
+							},
+						},
+					},
+				}
+			})
+
+			It("returns an error without fetching any bytes", func() {
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.Remove(tmpFile.Name())
+
+				err = client.ProductFiles.DownloadForRelease(
+					tmpFile,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).To(Equal(pivnet.ErrSizeMismatch{
+					ProductFileID: 1234,
+					ExpectedBytes: 20,
+					ContentLength: 18,
+				}))
+
+				Expect(cloudfront.ReceivedRequests()).To(HaveLen(1))
+			})
+		})
+
+		Context("when the destination does not have room for the download", func() {
+			BeforeEach(func() {
+				headContentLength = strconv.Itoa(1 << 50)
+				getResponse = pivnet.ProductFileResponse{
+					pivnet.ProductFile{
+						ID:           1234,
+						AWSObjectKey: "something",
+						Size:         1 << 50,
+						Links: &pivnet.Links{
+							Download: map[string]string{
+								"href": downloadLink,
+							},
+						},
+					},
+				}
+			})
+
+			It("returns an error without fetching any bytes", func() {
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+				defer os.Remove(tmpFile.Name())
+
+				err = client.ProductFiles.DownloadForRelease(
+					tmpFile,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).To(BeAssignableToTypeOf(pivnet.ErrInsufficientSpace{}))
+				Expect(err.(pivnet.ErrInsufficientSpace).RequiredBytes).To(Equal(int64(1 << 50)))
+				Expect(err.(pivnet.ErrInsufficientSpace).Path).To(Equal(filepath.Dir(tmpFile.Name())))
+				Expect(err).To(MatchError(ContainSubstring("not enough free space")))
+
+				Expect(cloudfront.ReceivedRequests()).To(HaveLen(1))
+			})
+		})
+
+		Context("when a download cache is configured", func() {
+			var (
+				cacheDir string
+			)
+
+			BeforeEach(func() {
+				var err error
+				cacheDir, err = ioutil.TempDir("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				sum := md5.Sum(downloadLinkResponseBody)
+
+				getResponse = pivnet.ProductFileResponse{
+					pivnet.ProductFile{
+						ID:           1234,
+						AWSObjectKey: "something",
+						MD5:          hex.EncodeToString(sum[:]),
+						Links: &pivnet.Links{
+							Download: map[string]string{
+								"href": downloadLink,
+							},
+						},
+					},
+				}
+
+				newClientConfig.DownloadCacheDir = cacheDir
+				client = pivnet.NewClient(newClientConfig, fakeLogger)
+			})
+
+			AfterEach(func() {
+				err := os.RemoveAll(cacheDir)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("reuses the cached file instead of downloading it again", func() {
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = client.ProductFiles.DownloadForRelease(
+					tmpFile,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				cloudfrontRequests := len(cloudfront.ReceivedRequests())
+
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest(
+							"GET",
+							fmt.Sprintf(
+								"%s/products/%s/releases/%d/product_files/%d",
+								apiPrefix,
+								productSlug,
+								releaseID,
+								productFileID,
+							),
+						),
+						ghttp.RespondWithJSONEncoded(getStatusCode, getResponse),
+					),
+				)
+
+				cachedFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = client.ProductFiles.DownloadForRelease(
+					cachedFile,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := ioutil.ReadFile(cachedFile.Name())
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(contents).To(Equal(downloadLinkResponseBody))
+				Expect(cloudfront.ReceivedRequests()).To(HaveLen(cloudfrontRequests))
+			})
+
+			Context("when the download does not match its checksum", func() {
+				BeforeEach(func() {
+					productFile := getResponse.(pivnet.ProductFileResponse).ProductFile
+					productFile.MD5 = "some-other-md5"
+					getResponse = pivnet.ProductFileResponse{productFile}
+				})
+
+				It("returns an error without caching the file", func() {
+					tmpFile, err := ioutil.TempFile("", "")
+					Expect(err).NotTo(HaveOccurred())
+
+					err = client.ProductFiles.DownloadForRelease(
+						tmpFile,
+						productSlug,
+						releaseID,
+						productFileID,
+						GinkgoWriter,
+					)
+					Expect(err).To(MatchError(ContainSubstring("MD5 mismatch")))
+
+					entries, err := filepath.Glob(filepath.Join(cacheDir, "entries", "*"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(entries).To(BeEmpty())
+				})
+			})
+
+			It("places the cached file at a path instead of downloading it again", func() {
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = client.ProductFiles.DownloadForRelease(
+					tmpFile,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				cloudfrontRequests := len(cloudfront.ReceivedRequests())
+
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest(
+							"GET",
+							fmt.Sprintf(
+								"%s/products/%s/releases/%d/product_files/%d",
+								apiPrefix,
+								productSlug,
+								releaseID,
+								productFileID,
+							),
+						),
+						ghttp.RespondWithJSONEncoded(getStatusCode, getResponse),
+					),
+				)
+
+				filePath := filepath.Join(cacheDir, "some-file")
+
+				err = client.ProductFiles.DownloadForReleaseToPath(
+					context.Background(),
+					filePath,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := ioutil.ReadFile(filePath)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(contents).To(Equal(downloadLinkResponseBody))
+				Expect(cloudfront.ReceivedRequests()).To(HaveLen(cloudfrontRequests))
+
+				// Modifying the placed file must not affect the cached copy
+				f, err := os.OpenFile(filePath, os.O_WRONLY, 0)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = f.Write([]byte("modified"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(f.Close()).To(Succeed())
+
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest(
+							"GET",
+							fmt.Sprintf(
+								"%s/products/%s/releases/%d/product_files/%d",
+								apiPrefix,
+								productSlug,
+								releaseID,
+								productFileID,
+							),
+						),
+						ghttp.RespondWithJSONEncoded(getStatusCode, getResponse),
+					),
+				)
+
+				cachedFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = client.ProductFiles.DownloadForRelease(
+					cachedFile,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err = ioutil.ReadFile(cachedFile.Name())
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(contents).To(Equal(downloadLinkResponseBody))
+				Expect(cloudfront.ReceivedRequests()).To(HaveLen(cloudfrontRequests))
+			})
+		})
+
+		Context("when a progress listener is configured", func() {
+			var (
+				listener *fakes.ProgressListener
+			)
+
+			BeforeEach(func() {
+				listener = &fakes.ProgressListener{}
+
+				newClientConfig.NewProgressListener = func(progressWriter io.Writer) download.ProgressListener {
+					return listener
+				}
+				client = pivnet.NewClient(newClientConfig, fakeLogger)
+			})
+
+			It("reports progress to the listener", func() {
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = client.ProductFiles.DownloadForRelease(
+					tmpFile,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(listener.StartedCallCount()).To(Equal(1))
+				Expect(listener.StartedArgsForCall(0).TotalBytes).To(Equal(int64(18)))
+				Expect(listener.FinishedCallCount()).To(Equal(1))
+				Expect(listener.FinishedArgsForCall(0).TransferredBytes).To(Equal(int64(18)))
+			})
+		})
+
+		Context("when the signed download URL expires during the download", func() {
+			It("fetches a fresh download URL and completes the download", func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", fmt.Sprintf(
+							"%s%s",
+							apiPrefix,
+							downloadLink,
+						)),
+						ghttp.RespondWith(http.StatusFound, []byte(`{}`),
+							http.Header{
+								"Location": []string{cloudfrontDownloadLocation + "?fresh=true"},
+							},
+						),
+					),
+				)
+
+				cloudfront.RouteToHandler("GET", "/download", http.HandlerFunc(
+					func(w http.ResponseWriter, req *http.Request) {
+						if req.URL.Query().Get("fresh") == "" {
+							w.WriteHeader(http.StatusForbidden)
+							return
+						}
+
+						serveRanges(downloadLinkResponseBody).ServeHTTP(w, req)
+					},
+				))
+
+				tmpFile, err := ioutil.TempFile("", "")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = client.ProductFiles.DownloadForRelease(
+					tmpFile,
+					productSlug,
+					releaseID,
+					productFileID,
+					GinkgoWriter,
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := ioutil.ReadFile(tmpFile.Name())
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(contents).To(Equal(downloadLinkResponseBody))
+			})
+		})
+
 		Context("when productFile.DownloadLink() returns an error", func() {
 			BeforeEach(func() {
 				getResponse = pivnet.ProductFileResponse{
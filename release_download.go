@@ -0,0 +1,91 @@
+package pivnet
+
+import (
+	"path/filepath"
+)
+
+type DownloadReleaseConfig struct {
+	ProductSlug    string
+	ReleaseID      int
+	DestinationDir string
+
+	// Include and Exclude are glob patterns, as understood by path.Match,
+	// matched against the product file name and the base name of its AWS
+	// object key. A file is downloaded if it matches any Include pattern, or
+	// Include is empty, and matches no Exclude pattern.
+	Include []string
+	Exclude []string
+
+	DownloadOptions
+}
+
+// DownloadRelease downloads the product files of a release that match the
+// configured filters into the destination directory, several at a time.
+// Each file is named after the base name of its AWS object key; files whose
+// names would clash are prefixed with their product file ID.
+// An error is returned if the product files cannot be listed or if any of
+// them fail to download; the report describes the outcome for every file.
+func (p ProductFilesService) DownloadRelease(config DownloadReleaseConfig) (DownloadReport, error) {
+	err := ValidateFilePatterns(config.Include)
+	if err != nil {
+		return DownloadReport{}, err
+	}
+
+	err = ValidateFilePatterns(config.Exclude)
+	if err != nil {
+		return DownloadReport{}, err
+	}
+
+	productFiles, err := p.ListForRelease(config.ProductSlug, config.ReleaseID)
+	if err != nil {
+		return DownloadReport{}, err
+	}
+
+	var report DownloadReport
+	var jobs []downloadJob
+	for _, pf := range productFiles {
+		if !matchesFilters(pf, config.Include, config.Exclude) {
+			report.Skipped = append(report.Skipped, pf)
+			continue
+		}
+
+		jobs = append(jobs, downloadJob{
+			productSlug: config.ProductSlug,
+			releaseID:   config.ReleaseID,
+			productFile: pf,
+			path:        filepath.Join(config.DestinationDir, pf.FileName()),
+		})
+	}
+
+	disambiguatePaths(jobs)
+
+	err = p.preflightJobs(config.DestinationDir, jobs)
+	if err != nil {
+		return report, err
+	}
+
+	results := p.downloadAll(jobs, config.DownloadOptions)
+	downloadErr := report.add(jobs, results)
+
+	if config.ManifestPath != "" {
+		manifest, err := newReleaseManifest(p.client, config.ProductSlug, config.ReleaseID)
+		if err != nil {
+			return report, err
+		}
+
+		err = writeManifest(manifest, config.ManifestPath, jobs, results)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	return report, downloadErr
+}
+
+func matchesFilters(pf ProductFile, include []string, exclude []string) bool {
+	if len(include) > 0 && !pf.MatchesFilePatterns(include) {
+		return false
+	}
+
+	return !pf.MatchesFilePatterns(exclude)
+}
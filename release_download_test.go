@@ -0,0 +1,271 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - download release", func() {
+	var (
+		server     *ghttp.Server
+		cloudfront *ghttp.Server
+		client     pivnet.Client
+
+		newClientConfig pivnet.ClientConfig
+		fakeLogger      logger.Logger
+
+		releaseID      int
+		destinationDir string
+		productFiles   []pivnet.ProductFile
+		contents       map[string][]byte
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		cloudfront = ghttp.NewServer()
+
+		fakeLogger = &loggerfakes.FakeLogger{}
+		newClientConfig = pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}
+		client = pivnet.NewClient(newClientConfig, fakeLogger)
+
+		releaseID = 1234
+
+		var err error
+		destinationDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		contents = releaseFileContents()
+
+		productFiles = []pivnet.ProductFile{
+			downloadableProductFile(server, 100, "product-1.0.pivotal"),
+			downloadableProductFile(server, 101, "cli-linux-1.0"),
+			downloadableProductFile(server, 102, "cli-windows-1.0.exe"),
+		}
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, releaseID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: productFiles}),
+		)
+
+		for _, pf := range productFiles {
+			routeDownload(server, cloudfront, pf, contents[filepath.Base(pf.AWSObjectKey)])
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		cloudfront.Close()
+
+		err := os.RemoveAll(destinationDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("downloads the product files matching the filters into the destination directory", func() {
+		report, err := client.ProductFiles.DownloadRelease(pivnet.DownloadReleaseConfig{
+			ProductSlug:     productSlug,
+			ReleaseID:       releaseID,
+			DestinationDir:  destinationDir,
+			Include:         []string{"*.pivotal", "cli-*"},
+			Exclude:         []string{"*windows*"},
+			DownloadOptions: pivnet.DownloadOptions{Connections: 2},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.Succeeded).To(ConsistOf(
+			pivnet.DownloadedProductFile{
+				ProductFile: productFiles[0],
+				Path:        filepath.Join(destinationDir, "product-1.0.pivotal"),
+			},
+			pivnet.DownloadedProductFile{
+				ProductFile: productFiles[1],
+				Path:        filepath.Join(destinationDir, "cli-linux-1.0"),
+			},
+		))
+		Expect(report.Skipped).To(Equal([]pivnet.ProductFile{productFiles[2]}))
+		Expect(report.Failed).To(BeEmpty())
+
+		for _, downloaded := range report.Succeeded {
+			content, err := ioutil.ReadFile(downloaded.Path)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(content).To(Equal(contents[filepath.Base(downloaded.Path)]))
+		}
+
+		_, err = os.Stat(filepath.Join(destinationDir, "cli-windows-1.0.exe"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("matches patterns against the product file name", func() {
+		report, err := client.ProductFiles.DownloadRelease(pivnet.DownloadReleaseConfig{
+			ProductSlug:    productSlug,
+			ReleaseID:      releaseID,
+			DestinationDir: destinationDir,
+			Include:        []string{"Product File 102"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.Succeeded).To(HaveLen(1))
+		Expect(report.Succeeded[0].ProductFile).To(Equal(productFiles[2]))
+		Expect(report.Skipped).To(HaveLen(2))
+	})
+
+	Context("when product files in different prefixes have the same base name", func() {
+		BeforeEach(func() {
+			productFiles = append(productFiles, downloadableProductFile(server, 200, "other/cli-linux-1.0"))
+		})
+
+		JustBeforeEach(func() {
+			routeDownload(server, cloudfront, productFiles[3], []byte("some other linux cli contents"))
+		})
+
+		It("prefixes their names with their IDs so that neither replaces the other", func() {
+			report, err := client.ProductFiles.DownloadRelease(pivnet.DownloadReleaseConfig{
+				ProductSlug:    productSlug,
+				ReleaseID:      releaseID,
+				DestinationDir: destinationDir,
+				Include:        []string{"cli-linux-*"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(report.Succeeded).To(ConsistOf(
+				pivnet.DownloadedProductFile{
+					ProductFile: productFiles[1],
+					Path:        filepath.Join(destinationDir, "101-cli-linux-1.0"),
+				},
+				pivnet.DownloadedProductFile{
+					ProductFile: productFiles[3],
+					Path:        filepath.Join(destinationDir, "200-cli-linux-1.0"),
+				},
+			))
+
+			content, err := ioutil.ReadFile(filepath.Join(destinationDir, "101-cli-linux-1.0"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(content).To(Equal(contents["cli-linux-1.0"]))
+
+			content, err = ioutil.ReadFile(filepath.Join(destinationDir, "200-cli-linux-1.0"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(content).To(Equal([]byte("some other linux cli contents")))
+		})
+	})
+
+	Context("when a manifest path is given", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.Release{ID: releaseID, Version: "1.0.0"}),
+			)
+		})
+
+		It("writes a manifest of the downloaded product files", func() {
+			manifestPath := filepath.Join(destinationDir, "manifest.yaml")
+
+			_, err := client.ProductFiles.DownloadRelease(pivnet.DownloadReleaseConfig{
+				ProductSlug:     productSlug,
+				ReleaseID:       releaseID,
+				DestinationDir:  destinationDir,
+				Include:         []string{"*.pivotal"},
+				DownloadOptions: pivnet.DownloadOptions{ManifestPath: manifestPath},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			manifest, err := pivnet.ReadManifest(manifestPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(manifest.ProductSlug).To(Equal(productSlug))
+			Expect(manifest.ReleaseID).To(Equal(releaseID))
+			Expect(manifest.ReleaseVersion).To(Equal("1.0.0"))
+			Expect(manifest.Files).To(HaveLen(1))
+			Expect(manifest.Files[0].ProductFileID).To(Equal(productFiles[0].ID))
+			Expect(manifest.Files[0].Path).To(Equal("product-1.0.pivotal"))
+			Expect(manifest.Files[0].SourceHost).To(Equal(strings.TrimPrefix(cloudfront.URL(), "http://")))
+		})
+	})
+
+	Context("when a product file fails to download", func() {
+		BeforeEach(func() {
+			contents["cli-linux-1.0"] = nil
+		})
+
+		It("reports the failure, removes the partial file and returns an error", func() {
+			report, err := client.ProductFiles.DownloadRelease(pivnet.DownloadReleaseConfig{
+				ProductSlug:    productSlug,
+				ReleaseID:      releaseID,
+				DestinationDir: destinationDir,
+			})
+			Expect(err).To(MatchError("1 of 3 product files failed to download"))
+
+			Expect(report.Succeeded).To(HaveLen(2))
+			Expect(report.Failed).To(HaveLen(1))
+			Expect(report.Failed[0].ProductFile).To(Equal(productFiles[1]))
+			Expect(report.Failed[0].Err).To(HaveOccurred())
+
+			_, err = os.Stat(report.Failed[0].Path)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	Context("when the destination does not have room for the selected product files", func() {
+		BeforeEach(func() {
+			productFiles[0].Size = 1 << 50
+		})
+
+		It("returns an error without downloading anything", func() {
+			_, err := client.ProductFiles.DownloadRelease(pivnet.DownloadReleaseConfig{
+				ProductSlug:    productSlug,
+				ReleaseID:      releaseID,
+				DestinationDir: destinationDir,
+				Include:        []string{"*.pivotal"},
+			})
+			Expect(err).To(BeAssignableToTypeOf(pivnet.ErrInsufficientSpace{}))
+
+			Expect(cloudfront.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when a pattern is invalid", func() {
+		It("returns an error without downloading anything", func() {
+			_, err := client.ProductFiles.DownloadRelease(pivnet.DownloadReleaseConfig{
+				ProductSlug:    productSlug,
+				ReleaseID:      releaseID,
+				DestinationDir: destinationDir,
+				Exclude:        []string{"["},
+			})
+			Expect(err).To(MatchError(ContainSubstring(`invalid pattern "["`)))
+
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when listing the product files fails", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+			)
+		})
+
+		It("forwards the error", func() {
+			_, err := client.ProductFiles.DownloadRelease(pivnet.DownloadReleaseConfig{
+				ProductSlug:    productSlug,
+				ReleaseID:      releaseID,
+				DestinationDir: destinationDir,
+			})
+			Expect(err).To(MatchError(ContainSubstring("foo message")))
+		})
+	})
+})
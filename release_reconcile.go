@@ -0,0 +1,309 @@
+package pivnet
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ReconcileAction string
+
+const (
+	ReconcileCreate ReconcileAction = "create"
+	ReconcileUpdate ReconcileAction = "update"
+	ReconcileAttach ReconcileAction = "attach"
+	ReconcileDetach ReconcileAction = "detach"
+)
+
+// DesiredProductFile is a product file a release should have. Either ID
+// names an existing product file, or Config describes one by its AWS object
+// key, which is updated to agree with the fields set on Config if it exists
+// and created otherwise.
+type DesiredProductFile struct {
+	ID     int
+	Config CreateProductFileConfig
+}
+
+type ReconcileReleaseConfig struct {
+	ProductSlug  string
+	ReleaseID    int
+	ProductFiles []DesiredProductFile
+
+	// KeepUnlisted leaves product files attached to the release that are not
+	// desired instead of detaching them.
+	KeepUnlisted bool
+}
+
+// ReconcileStep is one change in a reconcile plan.
+type ReconcileStep struct {
+	Action ReconcileAction
+
+	// ProductFile is the product file the step acts on. For an update it
+	// holds the new values of Fields. For a create, and an attach of a
+	// product file still to be created, it is empty until applied.
+	ProductFile ProductFile
+
+	// Config is the product file to create, and identifies it by AWS object
+	// key for the attach that follows.
+	Config CreateProductFileConfig
+
+	// Fields are the fields changed by an update.
+	Fields []ProductFileField
+
+	// Err is set when the step failed to apply.
+	Err error
+}
+
+func (s ReconcileStep) String() string {
+	switch {
+	case s.Action == ReconcileUpdate:
+		fields := make([]string, len(s.Fields))
+		for i, field := range s.Fields {
+			fields[i] = string(field)
+		}
+
+		return fmt.Sprintf("update %s: %s", s.target(), strings.Join(fields, ", "))
+	default:
+		return fmt.Sprintf("%s %s", s.Action, s.target())
+	}
+}
+
+func (s ReconcileStep) target() string {
+	if s.ProductFile.ID == 0 {
+		return s.Config.AWSObjectKey
+	}
+
+	if s.ProductFile.AWSObjectKey == "" {
+		return fmt.Sprintf("product file %d", s.ProductFile.ID)
+	}
+
+	return fmt.Sprintf("product file %d (%s)", s.ProductFile.ID, s.ProductFile.AWSObjectKey)
+}
+
+// ReconcilePlan holds the steps that make a release's product files match
+// the desired product files: creates, then updates, then attaches, then
+// detaches.
+type ReconcilePlan struct {
+	ProductSlug string
+	ReleaseID   int
+	Steps       []ReconcileStep
+}
+
+// Failed returns the steps that failed to apply.
+func (p ReconcilePlan) Failed() []ReconcileStep {
+	var failed []ReconcileStep
+	for _, step := range p.Steps {
+		if step.Err != nil {
+			failed = append(failed, step)
+		}
+	}
+
+	return failed
+}
+
+// String describes the plan one step per line, and the error of each step
+// that failed to apply.
+func (p ReconcilePlan) String() string {
+	if len(p.Steps) == 0 {
+		return fmt.Sprintf("release %d of %s: no changes\n", p.ReleaseID, p.ProductSlug)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "release %d of %s: %d changes\n", p.ReleaseID, p.ProductSlug, len(p.Steps))
+	for _, step := range p.Steps {
+		fmt.Fprintf(&b, "  %s\n", step)
+		if step.Err != nil {
+			fmt.Fprintf(&b, "    failed: %s\n", step.Err)
+		}
+	}
+
+	return b.String()
+}
+
+// PlanReconcile compares the product files of a release with the desired
+// product files and returns the steps needed to make them match. Nothing is
+// changed.
+func (p ProductFilesService) PlanReconcile(config ReconcileReleaseConfig) (ReconcilePlan, error) {
+	attached, err := p.ListForRelease(config.ProductSlug, config.ReleaseID)
+	if err != nil {
+		return ReconcilePlan{}, err
+	}
+
+	attachedIDs := map[int]bool{}
+	for _, pf := range attached {
+		attachedIDs[pf.ID] = true
+	}
+
+	var all []ProductFile
+	for _, desired := range config.ProductFiles {
+		if desired.ID == 0 {
+			all, err = p.List(config.ProductSlug)
+			if err != nil {
+				return ReconcilePlan{}, err
+			}
+
+			break
+		}
+	}
+
+	var creates, updates, attaches, detaches []ReconcileStep
+	desiredIDs := map[int]bool{}
+	desiredKeys := map[string]bool{}
+
+	for _, desired := range config.ProductFiles {
+		var existing ProductFile
+
+		switch {
+		case desired.ID != 0:
+			if desiredIDs[desired.ID] {
+				continue
+			}
+
+			if attachedIDs[desired.ID] {
+				desiredIDs[desired.ID] = true
+				continue
+			}
+
+			existing, err = p.Get(config.ProductSlug, desired.ID)
+			if err != nil {
+				return ReconcilePlan{}, err
+			}
+
+		case desired.Config.AWSObjectKey != "":
+			if desiredKeys[desired.Config.AWSObjectKey] {
+				return ReconcilePlan{}, fmt.Errorf("AWS object key %s is desired more than once", desired.Config.AWSObjectKey)
+			}
+			desiredKeys[desired.Config.AWSObjectKey] = true
+
+			createConfig := desired.Config
+			createConfig.ProductSlug = config.ProductSlug
+
+			match := findProductFile(all, createConfig.AWSObjectKey, "")
+			if match == nil {
+				err = createConfig.Validate()
+				if err != nil {
+					return ReconcilePlan{}, err
+				}
+
+				creates = append(creates, ReconcileStep{Action: ReconcileCreate, Config: createConfig})
+				attaches = append(attaches, ReconcileStep{Action: ReconcileAttach, Config: createConfig})
+				continue
+			}
+
+			// Listing may not return every field, so compare against the
+			// full record
+			existing, err = p.Get(config.ProductSlug, match.ID)
+			if err != nil {
+				return ReconcilePlan{}, err
+			}
+
+			changed, fields := productFileChanges(existing, createConfig)
+			if len(fields) > 0 {
+				updates = append(updates, ReconcileStep{
+					Action:      ReconcileUpdate,
+					ProductFile: changed,
+					Config:      createConfig,
+					Fields:      fields,
+				})
+			}
+
+		default:
+			return ReconcilePlan{}, fmt.Errorf("desired product file must have an ID or an AWS object key")
+		}
+
+		if desiredIDs[existing.ID] {
+			continue
+		}
+		desiredIDs[existing.ID] = true
+
+		if !attachedIDs[existing.ID] {
+			attaches = append(attaches, ReconcileStep{Action: ReconcileAttach, ProductFile: existing})
+		}
+	}
+
+	if !config.KeepUnlisted {
+		for _, pf := range attached {
+			if !desiredIDs[pf.ID] {
+				detaches = append(detaches, ReconcileStep{Action: ReconcileDetach, ProductFile: pf})
+			}
+		}
+	}
+
+	plan := ReconcilePlan{
+		ProductSlug: config.ProductSlug,
+		ReleaseID:   config.ReleaseID,
+	}
+
+	plan.Steps = append(plan.Steps, creates...)
+	plan.Steps = append(plan.Steps, updates...)
+	plan.Steps = append(plan.Steps, attaches...)
+	plan.Steps = append(plan.Steps, detaches...)
+
+	return plan, nil
+}
+
+// ApplyReconcile applies every step of a plan, carrying on past failures.
+// The returned plan records the outcome of each step; an error is returned
+// if any failed. An attach of a product file whose create failed fails too.
+func (p ProductFilesService) ApplyReconcile(plan ReconcilePlan) (ReconcilePlan, error) {
+	applied := plan
+	applied.Steps = make([]ReconcileStep, len(plan.Steps))
+	copy(applied.Steps, plan.Steps)
+
+	created := map[string]ProductFile{}
+
+	for i := range applied.Steps {
+		step := &applied.Steps[i]
+		step.Err = nil
+
+		switch step.Action {
+		case ReconcileCreate:
+			pf, err := p.Create(step.Config)
+			if err != nil {
+				step.Err = err
+				continue
+			}
+
+			step.ProductFile = pf
+			created[step.Config.AWSObjectKey] = pf
+
+		case ReconcileUpdate:
+			pf, err := p.UpdateFields(plan.ProductSlug, step.ProductFile, step.Fields...)
+			if err != nil {
+				step.Err = err
+				continue
+			}
+
+			step.ProductFile = pf
+
+		case ReconcileAttach:
+			if step.ProductFile.ID == 0 {
+				pf, ok := created[step.Config.AWSObjectKey]
+				if !ok {
+					step.Err = fmt.Errorf("product file %s was not created", step.Config.AWSObjectKey)
+					continue
+				}
+
+				step.ProductFile = pf
+			}
+
+			step.Err = p.AddToRelease(plan.ProductSlug, plan.ReleaseID, step.ProductFile.ID)
+
+		case ReconcileDetach:
+			step.Err = p.RemoveFromRelease(plan.ProductSlug, plan.ReleaseID, step.ProductFile.ID)
+
+		default:
+			step.Err = fmt.Errorf("unknown reconcile action %q", step.Action)
+		}
+	}
+
+	failed := applied.Failed()
+	if len(failed) > 0 {
+		return applied, fmt.Errorf(
+			"%d of %d reconcile steps failed",
+			len(failed),
+			len(applied.Steps),
+		)
+	}
+
+	return applied, nil
+}
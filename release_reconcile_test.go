@@ -0,0 +1,257 @@
+package pivnet_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - reconcile release product files", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+
+		releaseID int
+		all       []pivnet.ProductFile
+		attached  []pivnet.ProductFile
+		desired   []pivnet.DesiredProductFile
+
+		mutex    sync.Mutex
+		added    []int
+		removed  []int
+		createOK bool
+	)
+
+	productFilesPath := func() string {
+		return fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug)
+	}
+
+	recordID := func(ids *[]int) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			var body struct {
+				ProductFile pivnet.ProductFile `json:"product_file"`
+			}
+			err := json.NewDecoder(req.Body).Decode(&body)
+			Expect(err).NotTo(HaveOccurred())
+
+			mutex.Lock()
+			*ids = append(*ids, body.ProductFile.ID)
+			mutex.Unlock()
+
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, &loggerfakes.FakeLogger{})
+
+		releaseID = 1234
+		added = nil
+		removed = nil
+		createOK = true
+
+		all = []pivnet.ProductFile{
+			{ID: 10, AWSObjectKey: "product-files/some-product/kept.zip", Name: "Kept"},
+			{ID: 11, AWSObjectKey: "product-files/some-product/stale.zip", Name: "Stale"},
+			{ID: 12, AWSObjectKey: "product-files/some-product/changed.zip", Name: "Changed", FileVersion: "1.0"},
+			{ID: 13, AWSObjectKey: "product-files/some-product/by-id.zip", Name: "By ID"},
+			{ID: 14, AWSObjectKey: "product-files/some-product/unchanged.zip", Name: "Unchanged"},
+		}
+		attached = all[:3]
+
+		desired = []pivnet.DesiredProductFile{
+			{ID: 10},
+			{Config: pivnet.CreateProductFileConfig{AWSObjectKey: "product-files/some-product/changed.zip", FileVersion: "1.1"}},
+			{ID: 13},
+			{Config: pivnet.CreateProductFileConfig{AWSObjectKey: "product-files/some-product/unchanged.zip", Name: "Unchanged"}},
+			{Config: pivnet.CreateProductFileConfig{AWSObjectKey: "product-files/some-product/new.zip", Name: "New"}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, releaseID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: attached}),
+		)
+
+		server.RouteToHandler("GET", productFilesPath(),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: all}),
+		)
+
+		for _, pf := range all {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/%d", productFilesPath(), pf.ID),
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{ProductFile: pf}),
+			)
+		}
+
+		server.RouteToHandler("POST", productFilesPath(), func(w http.ResponseWriter, req *http.Request) {
+			if !createOK {
+				ghttp.RespondWithJSONEncoded(http.StatusUnprocessableEntity, pivnetErr{Message: "create failed"})(w, req)
+				return
+			}
+
+			ghttp.RespondWith(http.StatusCreated, `{"product_file":{"id":15,"aws_object_key":"product-files/some-product/new.zip"}}`)(w, req)
+		})
+
+		server.RouteToHandler("PATCH", fmt.Sprintf("%s/%d", productFilesPath(), 12),
+			ghttp.CombineHandlers(
+				ghttp.VerifyJSON(`{"product_file":{"file_version":"1.1"}}`),
+				ghttp.RespondWith(http.StatusOK, `{"product_file":{"id":12,"file_version":"1.1"}}`),
+			),
+		)
+
+		server.RouteToHandler("PATCH", fmt.Sprintf("%s/products/%s/releases/%d/add_product_file", apiPrefix, productSlug, releaseID), recordID(&added))
+		server.RouteToHandler("PATCH", fmt.Sprintf("%s/products/%s/releases/%d/remove_product_file", apiPrefix, productSlug, releaseID), recordID(&removed))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	plan := func() pivnet.ReconcilePlan {
+		plan, err := client.ProductFiles.PlanReconcile(pivnet.ReconcileReleaseConfig{
+			ProductSlug:  productSlug,
+			ReleaseID:    releaseID,
+			ProductFiles: desired,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		return plan
+	}
+
+	It("plans the creates, updates, attaches and detaches without changing anything", func() {
+		p := plan()
+
+		Expect(p.String()).To(Equal(fmt.Sprintf(`release %d of %s: 6 changes
+  create product-files/some-product/new.zip
+  update product file 12 (product-files/some-product/changed.zip): file_version
+  attach product file 13 (product-files/some-product/by-id.zip)
+  attach product file 14 (product-files/some-product/unchanged.zip)
+  attach product-files/some-product/new.zip
+  detach product file 11 (product-files/some-product/stale.zip)
+`, releaseID, productSlug)))
+
+		for _, req := range server.ReceivedRequests() {
+			Expect(req.Method).To(Equal("GET"))
+		}
+	})
+
+	It("applies the plan", func() {
+		applied, err := client.ProductFiles.ApplyReconcile(plan())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(applied.Failed()).To(BeEmpty())
+		Expect(applied.Steps[0].ProductFile.ID).To(Equal(15))
+		Expect(applied.Steps[4].ProductFile.ID).To(Equal(15))
+
+		Expect(added).To(Equal([]int{13, 14, 15}))
+		Expect(removed).To(Equal([]int{11}))
+	})
+
+	Context("when the release already matches", func() {
+		BeforeEach(func() {
+			desired = []pivnet.DesiredProductFile{{ID: 10}, {ID: 11}, {ID: 12}}
+		})
+
+		It("plans no changes", func() {
+			p := plan()
+
+			Expect(p.Steps).To(BeEmpty())
+			Expect(p.String()).To(Equal(fmt.Sprintf("release %d of %s: no changes\n", releaseID, productSlug)))
+		})
+	})
+
+	Context("when unlisted product files are kept", func() {
+		It("does not plan detaches", func() {
+			p, err := client.ProductFiles.PlanReconcile(pivnet.ReconcileReleaseConfig{
+				ProductSlug:  productSlug,
+				ReleaseID:    releaseID,
+				ProductFiles: desired,
+				KeepUnlisted: true,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, step := range p.Steps {
+				Expect(step.Action).NotTo(Equal(pivnet.ReconcileDetach))
+			}
+		})
+	})
+
+	Context("when a step fails", func() {
+		BeforeEach(func() {
+			createOK = false
+		})
+
+		It("carries on and reports the failed steps", func() {
+			applied, err := client.ProductFiles.ApplyReconcile(plan())
+			Expect(err).To(MatchError("2 of 6 reconcile steps failed"))
+
+			failed := applied.Failed()
+			Expect(failed).To(HaveLen(2))
+			Expect(failed[0].Action).To(Equal(pivnet.ReconcileCreate))
+			Expect(failed[0].Err).To(MatchError(ContainSubstring("create failed")))
+			Expect(failed[1].Action).To(Equal(pivnet.ReconcileAttach))
+			Expect(failed[1].Err).To(MatchError("product file product-files/some-product/new.zip was not created"))
+
+			Expect(added).To(Equal([]int{13, 14}))
+			Expect(removed).To(Equal([]int{11}))
+
+			Expect(applied.String()).To(ContainSubstring("    failed: product file product-files/some-product/new.zip was not created\n"))
+		})
+	})
+
+	Context("when a product file to create is invalid", func() {
+		BeforeEach(func() {
+			desired[4].Config.Name = ""
+		})
+
+		It("returns an error", func() {
+			_, err := client.ProductFiles.PlanReconcile(pivnet.ReconcileReleaseConfig{
+				ProductSlug:  productSlug,
+				ReleaseID:    releaseID,
+				ProductFiles: desired,
+			})
+			Expect(err).To(BeAssignableToTypeOf(pivnet.ErrInvalidConfig{}))
+		})
+	})
+
+	Context("when a desired product file has neither an ID nor an AWS object key", func() {
+		It("returns an error", func() {
+			_, err := client.ProductFiles.PlanReconcile(pivnet.ReconcileReleaseConfig{
+				ProductSlug:  productSlug,
+				ReleaseID:    releaseID,
+				ProductFiles: []pivnet.DesiredProductFile{{}},
+			})
+			Expect(err).To(MatchError("desired product file must have an ID or an AWS object key"))
+		})
+	})
+
+	Context("when listing the release's product files fails", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files", apiPrefix, productSlug, releaseID),
+				ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+			)
+		})
+
+		It("forwards the error", func() {
+			_, err := client.ProductFiles.PlanReconcile(pivnet.ReconcileReleaseConfig{
+				ProductSlug:  productSlug,
+				ReleaseID:    releaseID,
+				ProductFiles: desired,
+			})
+			Expect(err).To(MatchError(ContainSubstring("foo message")))
+		})
+	})
+})
@@ -8,10 +8,26 @@ import (
 
 type ReleaseTypesService struct {
 	client Client
+	cache  *releaseTypesCache
 }
 
 type ReleaseType string
 
+// The release types in common use. The server is authoritative; see
+// ReleaseTypesService.ValidateReleaseType.
+const (
+	ReleaseTypeAllInOne    ReleaseType = "All-In-One"
+	ReleaseTypeMajor       ReleaseType = "Major Release"
+	ReleaseTypeMinor       ReleaseType = "Minor Release"
+	ReleaseTypeService     ReleaseType = "Service Release"
+	ReleaseTypeMaintenance ReleaseType = "Maintenance Release"
+	ReleaseTypeSecurity    ReleaseType = "Security Release"
+	ReleaseTypeAlpha       ReleaseType = "Alpha Release"
+	ReleaseTypeBeta        ReleaseType = "Beta Release"
+	ReleaseTypeEdge        ReleaseType = "Edge Release"
+	ReleaseTypeDeveloper   ReleaseType = "Developer Release"
+)
+
 type ReleaseTypesResponse struct {
 	ReleaseTypes []ReleaseType `json:"release_types" yaml:"release_types"`
 }
@@ -0,0 +1,57 @@
+package pivnet
+
+import (
+	"fmt"
+	"sync"
+)
+
+// releaseTypesCache holds the release types fetched from the server, which
+// change rarely enough to fetch once per client.
+type releaseTypesCache struct {
+	mutex        sync.Mutex
+	releaseTypes []ReleaseType
+}
+
+// GetCached returns the release types, fetching them on the first call and
+// returning the same result after that. A failed fetch is not cached.
+func (r ReleaseTypesService) GetCached() ([]ReleaseType, error) {
+	if r.cache == nil {
+		return r.Get()
+	}
+
+	r.cache.mutex.Lock()
+	defer r.cache.mutex.Unlock()
+
+	if r.cache.releaseTypes != nil {
+		return r.cache.releaseTypes, nil
+	}
+
+	releaseTypes, err := r.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.releaseTypes = releaseTypes
+
+	return releaseTypes, nil
+}
+
+// ValidateReleaseType checks that releaseType is one of the release types
+// known to the server, using the cached release types.
+func (r ReleaseTypesService) ValidateReleaseType(releaseType ReleaseType) error {
+	releaseTypes, err := r.GetCached()
+	if err != nil {
+		return err
+	}
+
+	for _, known := range releaseTypes {
+		if releaseType == known {
+			return nil
+		}
+	}
+
+	return ErrInvalidConfig{
+		Kind:     "release type",
+		Problems: []string{fmt.Sprintf("%q must be one of %q", releaseType, releaseTypes)},
+	}
+}
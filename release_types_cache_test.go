@@ -0,0 +1,71 @@
+package pivnet_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - cached release types", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, &loggerfakes.FakeLogger{})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("fetches the release types once", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", fmt.Sprintf("%s/releases/release_types", apiPrefix)),
+				ghttp.RespondWith(http.StatusOK, `{"release_types": ["Major Release","Minor Release"]}`),
+			),
+		)
+
+		err := client.ReleaseTypes.ValidateReleaseType(pivnet.ReleaseTypeMajor)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = client.ReleaseTypes.ValidateReleaseType("Minor Relase")
+		Expect(err).To(MatchError(`invalid release type: "Minor Relase" must be one of ["Major Release" "Minor Release"]`))
+
+		releaseTypes, err := client.ReleaseTypes.GetCached()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(releaseTypes).To(Equal([]pivnet.ReleaseType{pivnet.ReleaseTypeMajor, pivnet.ReleaseTypeMinor}))
+
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	Context("when fetching the release types fails", func() {
+		It("returns the error and fetches again on the next call", func() {
+			server.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+				ghttp.RespondWith(http.StatusOK, `{"release_types": ["Major Release"]}`),
+			)
+
+			err := client.ReleaseTypes.ValidateReleaseType(pivnet.ReleaseTypeMajor)
+			Expect(err).To(MatchError(ContainSubstring("foo message")))
+
+			err = client.ReleaseTypes.ValidateReleaseType(pivnet.ReleaseTypeMajor)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+})
@@ -50,7 +50,7 @@ type Release struct {
 type CreateReleaseConfig struct {
 	ProductSlug           string
 	Version               string
-	ReleaseType           string
+	ReleaseType           ReleaseType
 	ReleaseDate           string
 	EULASlug              string
 	Description           string
@@ -110,7 +110,7 @@ func (r ReleasesService) Create(config CreateReleaseConfig) (Release, error) {
 			},
 			OSSCompliant:          "confirm",
 			ReleaseDate:           config.ReleaseDate,
-			ReleaseType:           ReleaseType(config.ReleaseType),
+			ReleaseType:           config.ReleaseType,
 			Version:               config.Version,
 			Description:           config.Description,
 			ReleaseNotesURL:       config.ReleaseNotesURL,
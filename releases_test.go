@@ -194,7 +194,7 @@ var _ = Describe("PivnetClient - product files", func() {
 						Availability: "Admins Only",
 						OSSCompliant: "confirm",
 						ReleaseDate:  expectedReleaseDate,
-						ReleaseType:  pivnet.ReleaseType(createReleaseConfig.ReleaseType),
+						ReleaseType:  createReleaseConfig.ReleaseType,
 						EULA: &pivnet.EULA{
 							Slug: createReleaseConfig.EULASlug,
 						},
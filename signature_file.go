@@ -0,0 +1,187 @@
+package pivnet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SignatureFileExtensions are the extensions accepted for detached
+// signatures.
+var SignatureFileExtensions = []string{".asc", ".sig"}
+
+type UploadSignatureFileConfig struct {
+	ProductSlug   string
+	ProductFileID int
+
+	// FilePath is the local detached signature, which must have one of
+	// SignatureFileExtensions.
+	FilePath string
+
+	// AWSObjectKey defaults to the AWS object key of the product file with
+	// the extension of FilePath appended.
+	AWSObjectKey string
+}
+
+type UploadSignedProductFileConfig struct {
+	UploadProductFileConfig
+
+	// SignatureFilePath is the local detached signature of FilePath.
+	SignatureFilePath string
+}
+
+type addSignatureFileBody struct {
+	SignatureFile signatureFile `json:"signature_file"`
+}
+
+type signatureFile struct {
+	AWSObjectKey string `json:"aws_object_key"`
+}
+
+// AddSignatureFile links a signature already uploaded to the product's
+// bucket to a product file.
+func (p ProductFilesService) AddSignatureFile(
+	productSlug string,
+	productFileID int,
+	awsObjectKey string,
+) error {
+	if awsObjectKey == "" {
+		return fmt.Errorf("signature file AWS object key must not be empty")
+	}
+
+	url := fmt.Sprintf(
+		"/products/%s/product_files/%d/add_signature_file",
+		productSlug,
+		productFileID,
+	)
+
+	body := addSignatureFileBody{
+		SignatureFile: signatureFile{
+			AWSObjectKey: awsObjectKey,
+		},
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling
+		// a known-good body
+		return err
+	}
+
+	resp, err := p.client.MakeRequest(
+		"PATCH",
+		url,
+		http.StatusNoContent,
+		bytes.NewReader(b),
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// UploadSignatureFile uploads a detached signature to the product's bucket
+// and links it to an existing product file, returning the product file as
+// it is afterwards.
+func (p ProductFilesService) UploadSignatureFile(ctx context.Context, config UploadSignatureFileConfig) (ProductFile, error) {
+	err := checkSignatureFile(config.FilePath)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	awsObjectKey := config.AWSObjectKey
+	if awsObjectKey == "" {
+		productFile, err := p.Get(config.ProductSlug, config.ProductFileID)
+		if err != nil {
+			return ProductFile{}, err
+		}
+
+		if productFile.AWSObjectKey == "" {
+			return ProductFile{}, fmt.Errorf("product file %d has no AWS object key to derive the signature file's from", config.ProductFileID)
+		}
+
+		awsObjectKey = productFile.AWSObjectKey + filepath.Ext(config.FilePath)
+	}
+
+	err = p.uploadObject(ctx, config.ProductSlug, config.FilePath, awsObjectKey)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	err = p.AddSignatureFile(config.ProductSlug, config.ProductFileID, awsObjectKey)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	productFile, err := p.Get(config.ProductSlug, config.ProductFileID)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	if !productFile.HasSignatureFile {
+		return productFile, fmt.Errorf("product file %d does not have a signature file after adding one", config.ProductFileID)
+	}
+
+	return productFile, nil
+}
+
+// UploadSigned uploads a product file as Upload does, then its detached
+// signature as UploadSignatureFile does. The signature is checked before
+// anything is uploaded. The product file record is created with CreateOrGet,
+// so re-running after the signature step fails reuses the record created
+// the first time.
+func (p ProductFilesService) UploadSigned(ctx context.Context, config UploadSignedProductFileConfig) (ProductFile, error) {
+	err := checkSignatureFile(config.SignatureFilePath)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	createConfig, err := p.uploadProductFileObject(ctx, config.UploadProductFileConfig)
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	result, err := p.CreateOrGet(CreateOrGetProductFileConfig{
+		CreateProductFileConfig: createConfig,
+	})
+	if err != nil {
+		return ProductFile{}, err
+	}
+
+	return p.UploadSignatureFile(ctx, UploadSignatureFileConfig{
+		ProductSlug:   config.ProductSlug,
+		ProductFileID: result.ProductFile.ID,
+		FilePath:      config.SignatureFilePath,
+		AWSObjectKey:  config.AWSObjectKey + filepath.Ext(config.SignatureFilePath),
+	})
+}
+
+func checkSignatureFile(filePath string) error {
+	ext := strings.ToLower(filepath.Ext(filePath))
+
+	valid := false
+	for _, known := range SignatureFileExtensions {
+		if ext == known {
+			valid = true
+			break
+		}
+	}
+
+	if !valid {
+		return fmt.Errorf(
+			"signature file %s must have one of the extensions %s",
+			filePath,
+			strings.Join(SignatureFileExtensions, ", "),
+		)
+	}
+
+	_, err := os.Stat(filePath)
+	return err
+}
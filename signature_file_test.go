@@ -0,0 +1,287 @@
+package pivnet_test
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+	"github.com/pivotal-cf/go-pivnet/upload"
+	"github.com/pivotal-cf/go-pivnet/upload/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - signature files", func() {
+	var (
+		server *ghttp.Server
+		client pivnet.Client
+		store  *fakes.ObjectStore
+
+		tempDir       string
+		artifactPath  string
+		signaturePath string
+
+		productFileID    int
+		hasSignatureFile bool
+		addedSignatures  []string
+		listed           []pivnet.ProductFile
+		created          int
+	)
+
+	uploadedKeys := func() []string {
+		var keys []string
+		for i := 0; i < store.CreateMultipartUploadCallCount(); i++ {
+			_, key := store.CreateMultipartUploadArgsForCall(i)
+			keys = append(keys, key)
+		}
+
+		return keys
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		store = &fakes.ObjectStore{}
+		store.CreateMultipartUploadReturns("some-upload-id", nil)
+		store.UploadPartReturns(`"some-etag"`, nil)
+
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+			NewObjectStore: func(token pivnet.FederationToken) upload.ObjectStore {
+				return store
+			},
+		}, &loggerfakes.FakeLogger{})
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		artifactPath = filepath.Join(tempDir, "product.pivotal")
+		Expect(ioutil.WriteFile(artifactPath, []byte("some content"), 0644)).To(Succeed())
+
+		signaturePath = filepath.Join(tempDir, "product.pivotal.asc")
+		Expect(ioutil.WriteFile(signaturePath, []byte("some signature"), 0644)).To(Succeed())
+
+		productFileID = 1234
+		hasSignatureFile = false
+		addedSignatures = nil
+		listed = []pivnet.ProductFile{}
+		created = 0
+
+		server.RouteToHandler("POST", apiPrefix+"/federation_token",
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.FederationToken{Bucket: "some-bucket"}),
+		)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+			func(w http.ResponseWriter, req *http.Request) {
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFilesResponse{ProductFiles: listed})(w, req)
+			},
+		)
+
+		server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/product_files", apiPrefix, productSlug),
+			func(w http.ResponseWriter, req *http.Request) {
+				created++
+
+				ghttp.RespondWithJSONEncoded(http.StatusCreated, pivnet.ProductFileResponse{
+					ProductFile: pivnet.ProductFile{ID: productFileID, AWSObjectKey: "product-files/some-product/product.pivotal"},
+				})(w, req)
+			},
+		)
+
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/product_files/%d", apiPrefix, productSlug, productFileID),
+			func(w http.ResponseWriter, req *http.Request) {
+				ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{
+					ProductFile: pivnet.ProductFile{
+						ID:               productFileID,
+						AWSObjectKey:     "product-files/some-product/product.pivotal",
+						Name:             "Product",
+						MD5:              fmt.Sprintf("%x", md5.Sum([]byte("some content"))),
+						SHA256:           fmt.Sprintf("%x", sha256.Sum256([]byte("some content"))),
+						HasSignatureFile: hasSignatureFile,
+					},
+				})(w, req)
+			},
+		)
+
+		server.RouteToHandler("PATCH", fmt.Sprintf("%s/products/%s/product_files/%d/add_signature_file", apiPrefix, productSlug, productFileID),
+			func(w http.ResponseWriter, req *http.Request) {
+				var body struct {
+					SignatureFile struct {
+						AWSObjectKey string `json:"aws_object_key"`
+					} `json:"signature_file"`
+				}
+				Expect(json.NewDecoder(req.Body).Decode(&body)).To(Succeed())
+
+				addedSignatures = append(addedSignatures, body.SignatureFile.AWSObjectKey)
+				hasSignatureFile = true
+
+				w.WriteHeader(http.StatusNoContent)
+			},
+		)
+	})
+
+	AfterEach(func() {
+		server.Close()
+
+		err := os.RemoveAll(tempDir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("UploadSignatureFile", func() {
+		It("uploads the signature next to the product file and links it", func() {
+			productFile, err := client.ProductFiles.UploadSignatureFile(context.Background(), pivnet.UploadSignatureFileConfig{
+				ProductSlug:   productSlug,
+				ProductFileID: productFileID,
+				FilePath:      signaturePath,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(productFile.HasSignatureFile).To(BeTrue())
+			Expect(uploadedKeys()).To(Equal([]string{"product-files/some-product/product.pivotal.asc"}))
+			Expect(addedSignatures).To(Equal([]string{"product-files/some-product/product.pivotal.asc"}))
+		})
+
+		It("uses the AWS object key given", func() {
+			_, err := client.ProductFiles.UploadSignatureFile(context.Background(), pivnet.UploadSignatureFileConfig{
+				ProductSlug:   productSlug,
+				ProductFileID: productFileID,
+				FilePath:      signaturePath,
+				AWSObjectKey:  "product-files/some-product/signatures/product.asc",
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(uploadedKeys()).To(Equal([]string{"product-files/some-product/signatures/product.asc"}))
+			Expect(addedSignatures).To(Equal([]string{"product-files/some-product/signatures/product.asc"}))
+		})
+
+		Context("when the signature file does not have a signature extension", func() {
+			It("returns an error without uploading", func() {
+				_, err := client.ProductFiles.UploadSignatureFile(context.Background(), pivnet.UploadSignatureFileConfig{
+					ProductSlug:   productSlug,
+					ProductFileID: productFileID,
+					FilePath:      artifactPath,
+				})
+				Expect(err).To(MatchError(fmt.Sprintf("signature file %s must have one of the extensions .asc, .sig", artifactPath)))
+
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when the product file does not report a signature file afterwards", func() {
+			BeforeEach(func() {
+				server.RouteToHandler("PATCH", fmt.Sprintf("%s/products/%s/product_files/%d/add_signature_file", apiPrefix, productSlug, productFileID),
+					ghttp.RespondWith(http.StatusNoContent, nil),
+				)
+			})
+
+			It("returns an error", func() {
+				_, err := client.ProductFiles.UploadSignatureFile(context.Background(), pivnet.UploadSignatureFileConfig{
+					ProductSlug:   productSlug,
+					ProductFileID: productFileID,
+					FilePath:      signaturePath,
+				})
+				Expect(err).To(MatchError("product file 1234 does not have a signature file after adding one"))
+			})
+		})
+
+		Context("when linking the signature fails", func() {
+			BeforeEach(func() {
+				server.RouteToHandler("PATCH", fmt.Sprintf("%s/products/%s/product_files/%d/add_signature_file", apiPrefix, productSlug, productFileID),
+					ghttp.RespondWithJSONEncoded(http.StatusTeapot, pivnetErr{Message: "foo message"}),
+				)
+			})
+
+			It("forwards the error", func() {
+				_, err := client.ProductFiles.UploadSignatureFile(context.Background(), pivnet.UploadSignatureFileConfig{
+					ProductSlug:   productSlug,
+					ProductFileID: productFileID,
+					FilePath:      signaturePath,
+				})
+				Expect(err).To(MatchError(ContainSubstring("foo message")))
+			})
+		})
+	})
+
+	Describe("UploadSigned", func() {
+		It("uploads the product file and its signature and returns the signed product file", func() {
+			productFile, err := client.ProductFiles.UploadSigned(context.Background(), pivnet.UploadSignedProductFileConfig{
+				UploadProductFileConfig: pivnet.UploadProductFileConfig{
+					FilePath: artifactPath,
+					CreateProductFileConfig: pivnet.CreateProductFileConfig{
+						ProductSlug:  productSlug,
+						AWSObjectKey: "product-files/some-product/product.pivotal",
+						Name:         "Product",
+					},
+				},
+				SignatureFilePath: signaturePath,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(productFile.ID).To(Equal(productFileID))
+			Expect(productFile.HasSignatureFile).To(BeTrue())
+			Expect(created).To(Equal(1))
+			Expect(uploadedKeys()).To(Equal([]string{
+				"product-files/some-product/product.pivotal",
+				"product-files/some-product/product.pivotal.asc",
+			}))
+		})
+
+		Context("when the product file was created by an earlier attempt", func() {
+			BeforeEach(func() {
+				listed = []pivnet.ProductFile{
+					{ID: productFileID, AWSObjectKey: "product-files/some-product/product.pivotal"},
+				}
+			})
+
+			It("signs the existing product file instead of creating another", func() {
+				productFile, err := client.ProductFiles.UploadSigned(context.Background(), pivnet.UploadSignedProductFileConfig{
+					UploadProductFileConfig: pivnet.UploadProductFileConfig{
+						FilePath: artifactPath,
+						CreateProductFileConfig: pivnet.CreateProductFileConfig{
+							ProductSlug:  productSlug,
+							AWSObjectKey: "product-files/some-product/product.pivotal",
+							Name:         "Product",
+						},
+					},
+					SignatureFilePath: signaturePath,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(productFile.ID).To(Equal(productFileID))
+				Expect(productFile.HasSignatureFile).To(BeTrue())
+				Expect(created).To(BeZero())
+			})
+		})
+
+		Context("when the signature file is missing", func() {
+			It("returns an error without uploading", func() {
+				_, err := client.ProductFiles.UploadSigned(context.Background(), pivnet.UploadSignedProductFileConfig{
+					UploadProductFileConfig: pivnet.UploadProductFileConfig{
+						FilePath: artifactPath,
+						CreateProductFileConfig: pivnet.CreateProductFileConfig{
+							ProductSlug:  productSlug,
+							AWSObjectKey: "product-files/some-product/product.pivotal",
+							Name:         "Product",
+						},
+					},
+					SignatureFilePath: filepath.Join(tempDir, "missing.sig"),
+				})
+				Expect(err).To(HaveOccurred())
+
+				Expect(server.ReceivedRequests()).To(BeEmpty())
+				Expect(store.CreateMultipartUploadCallCount()).To(Equal(0))
+			})
+		})
+	})
+})
@@ -0,0 +1,176 @@
+package pivnet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/go-pivnet/logger"
+)
+
+// SignedURL is a signed URL to the contents of a product file, for handing
+// to tools that download on their own.
+type SignedURL struct {
+	URL string
+
+	// ExpiresAt is the zero time when the expiry cannot be parsed from URL
+	ExpiresAt time.Time
+
+	// ContentLength is -1 when the server does not report it
+	ContentLength int64
+
+	// SignedHeaderNames are the names of the headers, other than Host, that
+	// the URL's signature covers. Requests to URL must include them, with
+	// the values agreed with whoever signed it; their values cannot be
+	// recovered from the URL, e.g. the customer key of an object encrypted
+	// with SSE-C.
+	SignedHeaderNames []string
+}
+
+// ResolveSignedURL exchanges a product file's download link for a signed URL
+// to its contents, without downloading them. An unaccepted EULA is accepted
+// and the exchange retried if the EULA is allowed by
+// ClientConfig.AutoAcceptEULASlugs.
+func (p ProductFilesService) ResolveSignedURL(
+	ctx context.Context,
+	productSlug string,
+	releaseID int,
+	productFileID int,
+) (SignedURL, error) {
+	pf, err := p.GetForRelease(productSlug, releaseID, productFileID)
+	if err != nil {
+		return SignedURL{}, err
+	}
+
+	downloadLink, err := pf.DownloadLink()
+	if err != nil {
+		return SignedURL{}, err
+	}
+
+	fetcher := NewProductFileLinkFetcher(downloadLink, p.client)
+
+	location, err := fetcher.NewDownloadLink()
+	if _, ok := err.(ErrUnavailableForLegalReasons); ok {
+		accepted, acceptErr := p.acceptEULA(productSlug, releaseID)
+		if acceptErr != nil {
+			return SignedURL{}, acceptErr
+		}
+
+		if accepted {
+			location, err = fetcher.NewDownloadLink()
+		}
+	}
+	if err != nil {
+		return SignedURL{}, err
+	}
+
+	signedURL, contentLength, err := p.client.downloader.Probe(ctx, location)
+	if err != nil {
+		return SignedURL{}, err
+	}
+
+	resolved := SignedURL{
+		URL:               signedURL,
+		ContentLength:     contentLength,
+		SignedHeaderNames: []string{},
+	}
+
+	u, err := url.Parse(signedURL)
+	if err != nil {
+		// Untested as the URL has already been requested successfully
+		return SignedURL{}, err
+	}
+
+	query := u.Query()
+	resolved.ExpiresAt = signedURLExpiry(query)
+
+	for _, param := range []string{"X-Amz-SignedHeaders", "X-Goog-SignedHeaders"} {
+		for _, name := range strings.Split(query.Get(param), ";") {
+			if name != "" && !strings.EqualFold(name, "host") {
+				resolved.SignedHeaderNames = append(resolved.SignedHeaderNames, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	p.client.logger.Debug("Resolved signed URL", logger.Data{
+		"host":          u.Host,
+		"expiresAt":     resolved.ExpiresAt,
+		"contentLength": contentLength,
+	})
+
+	return resolved, nil
+}
+
+// signedURLExpiry understands AWS and Google Cloud Storage presigned URLs,
+// and CloudFront signed URLs with either a canned or a custom policy.
+func signedURLExpiry(query url.Values) time.Time {
+	for _, prefix := range []string{"X-Amz-", "X-Goog-"} {
+		date := query.Get(prefix + "Date")
+		expires := query.Get(prefix + "Expires")
+		if date == "" || expires == "" {
+			continue
+		}
+
+		signedAt, err := time.Parse("20060102T150405Z", date)
+		if err != nil {
+			return time.Time{}
+		}
+
+		seconds, err := strconv.Atoi(expires)
+		if err != nil {
+			return time.Time{}
+		}
+
+		return signedAt.Add(time.Duration(seconds) * time.Second)
+	}
+
+	if expires := query.Get("Expires"); expires != "" {
+		seconds, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil {
+			return time.Time{}
+		}
+
+		return time.Unix(seconds, 0).UTC()
+	}
+
+	if policy := query.Get("Policy"); policy != "" {
+		return cloudFrontPolicyExpiry(policy)
+	}
+
+	return time.Time{}
+}
+
+func cloudFrontPolicyExpiry(policy string) time.Time {
+	// CloudFront replaces the characters that are invalid in a query string
+	decoded, err := base64.StdEncoding.DecodeString(strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(policy))
+	if err != nil {
+		return time.Time{}
+	}
+
+	var parsed struct {
+		Statement []struct {
+			Condition struct {
+				DateLessThan struct {
+					EpochTime int64 `json:"AWS:EpochTime"`
+				} `json:"DateLessThan"`
+			} `json:"Condition"`
+		} `json:"Statement"`
+	}
+
+	err = json.Unmarshal(decoded, &parsed)
+	if err != nil || len(parsed.Statement) == 0 {
+		return time.Time{}
+	}
+
+	epochTime := parsed.Statement[0].Condition.DateLessThan.EpochTime
+	if epochTime == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(epochTime, 0).UTC()
+}
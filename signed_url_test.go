@@ -0,0 +1,176 @@
+package pivnet_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/go-pivnet"
+	"github.com/pivotal-cf/go-pivnet/logger/loggerfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PivnetClient - signed URLs", func() {
+	var (
+		server     *ghttp.Server
+		cloudfront *ghttp.Server
+		client     pivnet.Client
+
+		releaseID     int
+		productFileID int
+
+		query    string
+		headFile http.HandlerFunc
+		getFile  http.HandlerFunc
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		cloudfront = ghttp.NewServer()
+
+		client = pivnet.NewClient(pivnet.ClientConfig{
+			Host:      server.URL(),
+			Token:     "my-auth-token",
+			UserAgent: "pivnet-resource/0.1.0 (some-url)",
+		}, &loggerfakes.FakeLogger{})
+
+		releaseID = 1234
+		productFileID = 100
+
+		query = "Expires=1791000000&Signature=some-signature&Key-Pair-Id=some-key"
+		headFile = ghttp.RespondWith(http.StatusOK, nil, http.Header{
+			"Content-Length": []string{"4096"},
+		})
+		getFile = func(w http.ResponseWriter, req *http.Request) {
+			Fail("the file contents should not be requested")
+		}
+	})
+
+	JustBeforeEach(func() {
+		server.RouteToHandler("GET", fmt.Sprintf("%s/products/%s/releases/%d/product_files/%d", apiPrefix, productSlug, releaseID, productFileID),
+			ghttp.RespondWithJSONEncoded(http.StatusOK, pivnet.ProductFileResponse{
+				ProductFile: pivnet.ProductFile{
+					ID: productFileID,
+					Links: &pivnet.Links{
+						Download: map[string]string{
+							"href": fmt.Sprintf("%s%s/products/%s/product_files/%d/download", server.URL(), apiPrefix, productSlug, productFileID),
+						},
+					},
+				},
+			}),
+		)
+
+		server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/product_files/%d/download", apiPrefix, productSlug, productFileID),
+			ghttp.RespondWith(http.StatusFound, []byte(`{}`), http.Header{
+				"Location": []string{cloudfront.URL() + "/some-product.pivotal?" + query},
+			}),
+		)
+
+		cloudfront.RouteToHandler("HEAD", "/some-product.pivotal", headFile)
+		cloudfront.RouteToHandler("GET", "/some-product.pivotal", getFile)
+	})
+
+	AfterEach(func() {
+		server.Close()
+		cloudfront.Close()
+	})
+
+	It("returns the signed URL, its expiry and content length without downloading the file", func() {
+		signedURL, err := client.ProductFiles.ResolveSignedURL(context.Background(), productSlug, releaseID, productFileID)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(signedURL.URL).To(Equal(cloudfront.URL() + "/some-product.pivotal?" + query))
+		Expect(signedURL.ExpiresAt).To(Equal(time.Unix(1791000000, 0).UTC()))
+		Expect(signedURL.ContentLength).To(Equal(int64(4096)))
+		Expect(signedURL.SignedHeaderNames).To(BeEmpty())
+	})
+
+	Context("when the URL is an AWS presigned URL", func() {
+		BeforeEach(func() {
+			query = "X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Date=20261018T120000Z&X-Amz-Expires=3600" +
+				"&X-Amz-SignedHeaders=host%3Bx-amz-server-side-encryption-customer-algorithm&X-Amz-Signature=abc"
+		})
+
+		It("returns the expiry and the names of the signed headers", func() {
+			signedURL, err := client.ProductFiles.ResolveSignedURL(context.Background(), productSlug, releaseID, productFileID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(signedURL.ExpiresAt).To(Equal(time.Date(2026, 10, 18, 13, 0, 0, 0, time.UTC)))
+			Expect(signedURL.SignedHeaderNames).To(Equal([]string{"X-Amz-Server-Side-Encryption-Customer-Algorithm"}))
+		})
+	})
+
+	Context("when the URL is a CloudFront URL with a custom policy", func() {
+		BeforeEach(func() {
+			policy := base64.StdEncoding.EncodeToString([]byte(
+				`{"Statement":[{"Resource":"https://example.com/*","Condition":{"DateLessThan":{"AWS:EpochTime":1791003600}}}]}`,
+			))
+			query = "Policy=" + strings.NewReplacer("+", "-", "=", "_", "/", "~").Replace(policy) + "&Signature=some-signature"
+		})
+
+		It("returns the expiry from the policy", func() {
+			signedURL, err := client.ProductFiles.ResolveSignedURL(context.Background(), productSlug, releaseID, productFileID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(signedURL.ExpiresAt).To(Equal(time.Unix(1791003600, 0).UTC()))
+		})
+	})
+
+	Context("when the expiry cannot be parsed", func() {
+		BeforeEach(func() {
+			query = "token=some-token"
+		})
+
+		It("returns a zero expiry", func() {
+			signedURL, err := client.ProductFiles.ResolveSignedURL(context.Background(), productSlug, releaseID, productFileID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(signedURL.ExpiresAt.IsZero()).To(BeTrue())
+		})
+	})
+
+	Context("when the server refuses HEAD requests", func() {
+		var rangeHeader string
+
+		BeforeEach(func() {
+			headFile = ghttp.RespondWith(http.StatusForbidden, nil)
+			getFile = func(w http.ResponseWriter, req *http.Request) {
+				rangeHeader = req.Header.Get("Range")
+
+				w.Header().Set("Content-Range", "bytes 0-0/4096")
+				w.WriteHeader(http.StatusPartialContent)
+				_, err := w.Write([]byte("x"))
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("reads the content length from a single byte range", func() {
+			signedURL, err := client.ProductFiles.ResolveSignedURL(context.Background(), productSlug, releaseID, productFileID)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(signedURL.ContentLength).To(Equal(int64(4096)))
+			Expect(rangeHeader).To(Equal("bytes=0-0"))
+		})
+	})
+
+	Context("when the EULA has not been accepted", func() {
+		JustBeforeEach(func() {
+			server.RouteToHandler("POST", fmt.Sprintf("%s/products/%s/product_files/%d/download", apiPrefix, productSlug, productFileID),
+				ghttp.RespondWith(http.StatusUnavailableForLegalReasons, `{"message":"EULA not accepted"}`),
+			)
+		})
+
+		It("returns the error", func() {
+			_, err := client.ProductFiles.ResolveSignedURL(context.Background(), productSlug, releaseID, productFileID)
+			Expect(err).To(BeAssignableToTypeOf(pivnet.ErrUnavailableForLegalReasons{}))
+
+			Expect(cloudfront.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+})
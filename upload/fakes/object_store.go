@@ -0,0 +1,327 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/pivotal-cf/go-pivnet/upload"
+)
+
+type ObjectStore struct {
+	CreateMultipartUploadStub        func(ctx context.Context, key string) (string, error)
+	createMultipartUploadMutex       sync.RWMutex
+	createMultipartUploadArgsForCall []struct {
+		ctx context.Context
+		key string
+	}
+	createMultipartUploadReturns struct {
+		result1 string
+		result2 error
+	}
+	ListMultipartUploadsStub        func(ctx context.Context, key string) ([]string, error)
+	listMultipartUploadsMutex       sync.RWMutex
+	listMultipartUploadsArgsForCall []struct {
+		ctx context.Context
+		key string
+	}
+	listMultipartUploadsReturns struct {
+		result1 []string
+		result2 error
+	}
+	ListPartsStub        func(ctx context.Context, key string, uploadID string) ([]upload.Part, error)
+	listPartsMutex       sync.RWMutex
+	listPartsArgsForCall []struct {
+		ctx      context.Context
+		key      string
+		uploadID string
+	}
+	listPartsReturns struct {
+		result1 []upload.Part
+		result2 error
+	}
+	UploadPartStub        func(ctx context.Context, key string, uploadID string, partNumber int, body io.ReadSeeker, size int64) (string, error)
+	uploadPartMutex       sync.RWMutex
+	uploadPartArgsForCall []struct {
+		ctx        context.Context
+		key        string
+		uploadID   string
+		partNumber int
+		body       io.ReadSeeker
+		size       int64
+	}
+	uploadPartReturns struct {
+		result1 string
+		result2 error
+	}
+	CompleteMultipartUploadStub        func(ctx context.Context, key string, uploadID string, parts []upload.Part) error
+	completeMultipartUploadMutex       sync.RWMutex
+	completeMultipartUploadArgsForCall []struct {
+		ctx      context.Context
+		key      string
+		uploadID string
+		parts    []upload.Part
+	}
+	completeMultipartUploadReturns struct {
+		result1 error
+	}
+	AbortMultipartUploadStub        func(ctx context.Context, key string, uploadID string) error
+	abortMultipartUploadMutex       sync.RWMutex
+	abortMultipartUploadArgsForCall []struct {
+		ctx      context.Context
+		key      string
+		uploadID string
+	}
+	abortMultipartUploadReturns struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *ObjectStore) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	fake.createMultipartUploadMutex.Lock()
+	fake.createMultipartUploadArgsForCall = append(fake.createMultipartUploadArgsForCall, struct {
+		ctx context.Context
+		key string
+	}{ctx, key})
+	fake.recordInvocation("CreateMultipartUpload", []interface{}{ctx, key})
+	fake.createMultipartUploadMutex.Unlock()
+	if fake.CreateMultipartUploadStub != nil {
+		return fake.CreateMultipartUploadStub(ctx, key)
+	} else {
+		return fake.createMultipartUploadReturns.result1, fake.createMultipartUploadReturns.result2
+	}
+}
+
+func (fake *ObjectStore) CreateMultipartUploadCallCount() int {
+	fake.createMultipartUploadMutex.RLock()
+	defer fake.createMultipartUploadMutex.RUnlock()
+	return len(fake.createMultipartUploadArgsForCall)
+}
+
+func (fake *ObjectStore) CreateMultipartUploadArgsForCall(i int) (context.Context, string) {
+	fake.createMultipartUploadMutex.RLock()
+	defer fake.createMultipartUploadMutex.RUnlock()
+	return fake.createMultipartUploadArgsForCall[i].ctx, fake.createMultipartUploadArgsForCall[i].key
+}
+
+func (fake *ObjectStore) CreateMultipartUploadReturns(result1 string, result2 error) {
+	fake.CreateMultipartUploadStub = nil
+	fake.createMultipartUploadReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ObjectStore) ListMultipartUploads(ctx context.Context, key string) ([]string, error) {
+	fake.listMultipartUploadsMutex.Lock()
+	fake.listMultipartUploadsArgsForCall = append(fake.listMultipartUploadsArgsForCall, struct {
+		ctx context.Context
+		key string
+	}{ctx, key})
+	fake.recordInvocation("ListMultipartUploads", []interface{}{ctx, key})
+	fake.listMultipartUploadsMutex.Unlock()
+	if fake.ListMultipartUploadsStub != nil {
+		return fake.ListMultipartUploadsStub(ctx, key)
+	} else {
+		return fake.listMultipartUploadsReturns.result1, fake.listMultipartUploadsReturns.result2
+	}
+}
+
+func (fake *ObjectStore) ListMultipartUploadsCallCount() int {
+	fake.listMultipartUploadsMutex.RLock()
+	defer fake.listMultipartUploadsMutex.RUnlock()
+	return len(fake.listMultipartUploadsArgsForCall)
+}
+
+func (fake *ObjectStore) ListMultipartUploadsArgsForCall(i int) (context.Context, string) {
+	fake.listMultipartUploadsMutex.RLock()
+	defer fake.listMultipartUploadsMutex.RUnlock()
+	return fake.listMultipartUploadsArgsForCall[i].ctx, fake.listMultipartUploadsArgsForCall[i].key
+}
+
+func (fake *ObjectStore) ListMultipartUploadsReturns(result1 []string, result2 error) {
+	fake.ListMultipartUploadsStub = nil
+	fake.listMultipartUploadsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ObjectStore) ListParts(ctx context.Context, key string, uploadID string) ([]upload.Part, error) {
+	fake.listPartsMutex.Lock()
+	fake.listPartsArgsForCall = append(fake.listPartsArgsForCall, struct {
+		ctx      context.Context
+		key      string
+		uploadID string
+	}{ctx, key, uploadID})
+	fake.recordInvocation("ListParts", []interface{}{ctx, key, uploadID})
+	fake.listPartsMutex.Unlock()
+	if fake.ListPartsStub != nil {
+		return fake.ListPartsStub(ctx, key, uploadID)
+	} else {
+		return fake.listPartsReturns.result1, fake.listPartsReturns.result2
+	}
+}
+
+func (fake *ObjectStore) ListPartsCallCount() int {
+	fake.listPartsMutex.RLock()
+	defer fake.listPartsMutex.RUnlock()
+	return len(fake.listPartsArgsForCall)
+}
+
+func (fake *ObjectStore) ListPartsArgsForCall(i int) (context.Context, string, string) {
+	fake.listPartsMutex.RLock()
+	defer fake.listPartsMutex.RUnlock()
+	return fake.listPartsArgsForCall[i].ctx, fake.listPartsArgsForCall[i].key, fake.listPartsArgsForCall[i].uploadID
+}
+
+func (fake *ObjectStore) ListPartsReturns(result1 []upload.Part, result2 error) {
+	fake.ListPartsStub = nil
+	fake.listPartsReturns = struct {
+		result1 []upload.Part
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ObjectStore) UploadPart(ctx context.Context, key string, uploadID string, partNumber int, body io.ReadSeeker, size int64) (string, error) {
+	fake.uploadPartMutex.Lock()
+	fake.uploadPartArgsForCall = append(fake.uploadPartArgsForCall, struct {
+		ctx        context.Context
+		key        string
+		uploadID   string
+		partNumber int
+		body       io.ReadSeeker
+		size       int64
+	}{ctx, key, uploadID, partNumber, body, size})
+	fake.recordInvocation("UploadPart", []interface{}{ctx, key, uploadID, partNumber, body, size})
+	fake.uploadPartMutex.Unlock()
+	if fake.UploadPartStub != nil {
+		return fake.UploadPartStub(ctx, key, uploadID, partNumber, body, size)
+	} else {
+		return fake.uploadPartReturns.result1, fake.uploadPartReturns.result2
+	}
+}
+
+func (fake *ObjectStore) UploadPartCallCount() int {
+	fake.uploadPartMutex.RLock()
+	defer fake.uploadPartMutex.RUnlock()
+	return len(fake.uploadPartArgsForCall)
+}
+
+func (fake *ObjectStore) UploadPartArgsForCall(i int) (context.Context, string, string, int, io.ReadSeeker, int64) {
+	fake.uploadPartMutex.RLock()
+	defer fake.uploadPartMutex.RUnlock()
+	return fake.uploadPartArgsForCall[i].ctx, fake.uploadPartArgsForCall[i].key, fake.uploadPartArgsForCall[i].uploadID, fake.uploadPartArgsForCall[i].partNumber, fake.uploadPartArgsForCall[i].body, fake.uploadPartArgsForCall[i].size
+}
+
+func (fake *ObjectStore) UploadPartReturns(result1 string, result2 error) {
+	fake.UploadPartStub = nil
+	fake.uploadPartReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ObjectStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []upload.Part) error {
+	fake.completeMultipartUploadMutex.Lock()
+	fake.completeMultipartUploadArgsForCall = append(fake.completeMultipartUploadArgsForCall, struct {
+		ctx      context.Context
+		key      string
+		uploadID string
+		parts    []upload.Part
+	}{ctx, key, uploadID, parts})
+	fake.recordInvocation("CompleteMultipartUpload", []interface{}{ctx, key, uploadID, parts})
+	fake.completeMultipartUploadMutex.Unlock()
+	if fake.CompleteMultipartUploadStub != nil {
+		return fake.CompleteMultipartUploadStub(ctx, key, uploadID, parts)
+	} else {
+		return fake.completeMultipartUploadReturns.result1
+	}
+}
+
+func (fake *ObjectStore) CompleteMultipartUploadCallCount() int {
+	fake.completeMultipartUploadMutex.RLock()
+	defer fake.completeMultipartUploadMutex.RUnlock()
+	return len(fake.completeMultipartUploadArgsForCall)
+}
+
+func (fake *ObjectStore) CompleteMultipartUploadArgsForCall(i int) (context.Context, string, string, []upload.Part) {
+	fake.completeMultipartUploadMutex.RLock()
+	defer fake.completeMultipartUploadMutex.RUnlock()
+	return fake.completeMultipartUploadArgsForCall[i].ctx, fake.completeMultipartUploadArgsForCall[i].key, fake.completeMultipartUploadArgsForCall[i].uploadID, fake.completeMultipartUploadArgsForCall[i].parts
+}
+
+func (fake *ObjectStore) CompleteMultipartUploadReturns(result1 error) {
+	fake.CompleteMultipartUploadStub = nil
+	fake.completeMultipartUploadReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *ObjectStore) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	fake.abortMultipartUploadMutex.Lock()
+	fake.abortMultipartUploadArgsForCall = append(fake.abortMultipartUploadArgsForCall, struct {
+		ctx      context.Context
+		key      string
+		uploadID string
+	}{ctx, key, uploadID})
+	fake.recordInvocation("AbortMultipartUpload", []interface{}{ctx, key, uploadID})
+	fake.abortMultipartUploadMutex.Unlock()
+	if fake.AbortMultipartUploadStub != nil {
+		return fake.AbortMultipartUploadStub(ctx, key, uploadID)
+	} else {
+		return fake.abortMultipartUploadReturns.result1
+	}
+}
+
+func (fake *ObjectStore) AbortMultipartUploadCallCount() int {
+	fake.abortMultipartUploadMutex.RLock()
+	defer fake.abortMultipartUploadMutex.RUnlock()
+	return len(fake.abortMultipartUploadArgsForCall)
+}
+
+func (fake *ObjectStore) AbortMultipartUploadArgsForCall(i int) (context.Context, string, string) {
+	fake.abortMultipartUploadMutex.RLock()
+	defer fake.abortMultipartUploadMutex.RUnlock()
+	return fake.abortMultipartUploadArgsForCall[i].ctx, fake.abortMultipartUploadArgsForCall[i].key, fake.abortMultipartUploadArgsForCall[i].uploadID
+}
+
+func (fake *ObjectStore) AbortMultipartUploadReturns(result1 error) {
+	fake.AbortMultipartUploadStub = nil
+	fake.abortMultipartUploadReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *ObjectStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.createMultipartUploadMutex.RLock()
+	defer fake.createMultipartUploadMutex.RUnlock()
+	fake.listMultipartUploadsMutex.RLock()
+	defer fake.listMultipartUploadsMutex.RUnlock()
+	fake.listPartsMutex.RLock()
+	defer fake.listPartsMutex.RUnlock()
+	fake.uploadPartMutex.RLock()
+	defer fake.uploadPartMutex.RUnlock()
+	fake.completeMultipartUploadMutex.RLock()
+	defer fake.completeMultipartUploadMutex.RUnlock()
+	fake.abortMultipartUploadMutex.RLock()
+	defer fake.abortMultipartUploadMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *ObjectStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
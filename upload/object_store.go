@@ -0,0 +1,33 @@
+package upload
+
+import (
+	"context"
+	"io"
+)
+
+// Part is a part of a multipart upload received by the object store.
+type Part struct {
+	Number int
+	ETag   string
+	Size   int64
+}
+
+// ObjectStore is the subset of the S3 multipart upload API used to upload
+// product files. S3 implements it for AWS and S3-compatible stores.
+//
+//go:generate counterfeiter -o ./fakes/object_store.go --fake-name ObjectStore . ObjectStore
+type ObjectStore interface {
+	CreateMultipartUpload(ctx context.Context, key string) (string, error)
+
+	// ListMultipartUploads returns the IDs of the incomplete uploads of key,
+	// oldest first.
+	ListMultipartUploads(ctx context.Context, key string) ([]string, error)
+
+	ListParts(ctx context.Context, key string, uploadID string) ([]Part, error)
+
+	// UploadPart returns the ETag of the part
+	UploadPart(ctx context.Context, key string, uploadID string, partNumber int, body io.ReadSeeker, size int64) (string, error)
+
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []Part) error
+	AbortMultipartUpload(ctx context.Context, key string, uploadID string) error
+}
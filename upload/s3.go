@@ -0,0 +1,349 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type S3Config struct {
+	// Endpoint, when set, is the base URL of an S3-compatible store, which
+	// is addressed path-style. Defaults to the AWS endpoint for Region,
+	// addressed virtual-hosted-style.
+	Endpoint string
+
+	Region string
+	Bucket string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// S3 is an ObjectStore for a single bucket, signing requests with AWS
+// Signature Version 4.
+type S3 struct {
+	config     S3Config
+	httpClient *http.Client
+	creds      credentials
+}
+
+func NewS3(config S3Config) S3 {
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	return S3{
+		config:     config,
+		httpClient: httpClient,
+		creds: credentials{
+			accessKeyID:     config.AccessKeyID,
+			secretAccessKey: config.SecretAccessKey,
+			sessionToken:    config.SessionToken,
+		},
+	}
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type listMultipartUploadsResult struct {
+	IsTruncated        bool   `xml:"IsTruncated"`
+	NextKeyMarker      string `xml:"NextKeyMarker"`
+	NextUploadIDMarker string `xml:"NextUploadIdMarker"`
+	Uploads            []struct {
+		Key       string    `xml:"Key"`
+		UploadID  string    `xml:"UploadId"`
+		Initiated time.Time `xml:"Initiated"`
+	} `xml:"Upload"`
+}
+
+type listPartsResult struct {
+	IsTruncated          bool `xml:"IsTruncated"`
+	NextPartNumberMarker int  `xml:"NextPartNumberMarker"`
+	Parts                []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+		Size       int64  `xml:"Size"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name       `xml:"CompleteMultipartUpload"`
+	Parts   []completePart `xml:"Part"`
+}
+
+type completePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (s S3) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	var result initiateMultipartUploadResult
+	_, err := s.do(ctx, "POST", key, url.Values{"uploads": {""}}, nil, &result)
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload of %s: %s", key, err)
+	}
+
+	return result.UploadID, nil
+}
+
+func (s S3) ListMultipartUploads(ctx context.Context, key string) ([]string, error) {
+	type upload struct {
+		id        string
+		initiated time.Time
+	}
+
+	var uploads []upload
+	query := url.Values{"uploads": {""}, "prefix": {key}}
+	for {
+		var result listMultipartUploadsResult
+		_, err := s.do(ctx, "GET", "", query, nil, &result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list multipart uploads of %s: %s", key, err)
+		}
+
+		for _, u := range result.Uploads {
+			if u.Key == key {
+				uploads = append(uploads, upload{id: u.UploadID, initiated: u.Initiated})
+			}
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+
+		query.Set("key-marker", result.NextKeyMarker)
+		query.Set("upload-id-marker", result.NextUploadIDMarker)
+	}
+
+	sort.SliceStable(uploads, func(i, j int) bool {
+		return uploads[i].initiated.Before(uploads[j].initiated)
+	})
+
+	ids := make([]string, 0, len(uploads))
+	for _, u := range uploads {
+		ids = append(ids, u.id)
+	}
+
+	return ids, nil
+}
+
+func (s S3) ListParts(ctx context.Context, key string, uploadID string) ([]Part, error) {
+	var parts []Part
+	query := url.Values{"uploadId": {uploadID}}
+	for {
+		var result listPartsResult
+		_, err := s.do(ctx, "GET", key, query, nil, &result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parts of %s: %s", key, err)
+		}
+
+		for _, p := range result.Parts {
+			parts = append(parts, Part{Number: p.PartNumber, ETag: p.ETag, Size: p.Size})
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+
+		query.Set("part-number-marker", strconv.Itoa(result.NextPartNumberMarker))
+	}
+
+	return parts, nil
+}
+
+func (s S3) UploadPart(
+	ctx context.Context,
+	key string,
+	uploadID string,
+	partNumber int,
+	body io.ReadSeeker,
+	size int64,
+) (string, error) {
+	query := url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {uploadID},
+	}
+
+	resp, err := s.do(ctx, "PUT", key, query, &payload{body: body, size: size}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d of %s: %s", partNumber, key, err)
+	}
+
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s S3) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []Part) error {
+	body := completeMultipartUpload{}
+	for _, p := range parts {
+		body.Parts = append(body.Parts, completePart{PartNumber: p.Number, ETag: p.ETag})
+	}
+
+	b, err := xml.Marshal(body)
+	if err != nil {
+		// Untested as we cannot force an error because we are marshalling
+		// a known-good body
+		return err
+	}
+
+	_, err = s.do(ctx, "POST", key, url.Values{"uploadId": {uploadID}}, &payload{body: bytes.NewReader(b), size: int64(len(b))}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload of %s: %s", key, err)
+	}
+
+	return nil
+}
+
+func (s S3) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	_, err := s.do(ctx, "DELETE", key, url.Values{"uploadId": {uploadID}}, nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload of %s: %s", key, err)
+	}
+
+	return nil
+}
+
+type payload struct {
+	body io.ReadSeeker
+	size int64
+}
+
+// do makes a signed request for key, or for the bucket when key is empty,
+// decoding an XML response into result when it is not nil.
+func (s S3) do(
+	ctx context.Context,
+	method string,
+	key string,
+	query url.Values,
+	body *payload,
+	result interface{},
+) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = u
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		sha256Hash := sha256.New()
+		md5Hash := md5.New()
+
+		_, err = io.Copy(io.MultiWriter(sha256Hash, md5Hash), io.LimitReader(body.body, body.size))
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = body.body.Seek(0, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+
+		payloadHash = hex.EncodeToString(sha256Hash.Sum(nil))
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Hash.Sum(nil)))
+
+		if body.size > 0 {
+			req.Body = ioutil.NopCloser(io.LimitReader(body.body, body.size))
+			req.ContentLength = body.size
+		}
+	}
+
+	signV4(req, s.creds, s.config.Region, payloadHash, time.Now())
+
+	resp, err := s.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// CompleteMultipartUpload can fail after responding with 200 OK, in
+	// which case the body is an error.
+	if resp.StatusCode >= 300 || bytes.Contains(b, []byte("<Error>")) {
+		var errBody s3Error
+		xml.Unmarshal(b, &errBody)
+
+		if errBody.Code != "" {
+			return nil, fmt.Errorf("%s: %s", errBody.Code, errBody.Message)
+		}
+
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+	}
+
+	if result != nil {
+		err = xml.Unmarshal(b, result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse response: %s", err)
+		}
+	}
+
+	return resp, nil
+}
+
+func (s S3) objectURL(key string) (*url.URL, error) {
+	var base string
+	var objectPath string
+
+	if s.config.Endpoint != "" {
+		base = strings.TrimSuffix(s.config.Endpoint, "/")
+		objectPath = "/" + s.config.Bucket + "/" + key
+	} else {
+		host := fmt.Sprintf("s3.%s.amazonaws.com", s.config.Region)
+		if s.config.Region == "us-east-1" {
+			host = "s3.amazonaws.com"
+		}
+
+		base = fmt.Sprintf("https://%s.%s", s.config.Bucket, host)
+		objectPath = "/" + key
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path += objectPath
+	u.RawPath = canonicalPath(u.Path)
+
+	return u, nil
+}
@@ -0,0 +1,396 @@
+package upload_test
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	standInBucket          = "some-bucket"
+	standInRegion          = "eu-west-1"
+	standInAccessKeyID     = "some-access-key-id"
+	standInSecretAccessKey = "some-secret-access-key"
+	standInSessionToken    = "some-session-token"
+)
+
+// s3StandIn is an in-memory S3-compatible server for a single bucket, serving
+// the multipart upload API with path-style addressing. It rejects requests
+// that are not signed with the stand-in credentials.
+type s3StandIn struct {
+	server *httptest.Server
+
+	mutex      sync.Mutex
+	nextID     int
+	objects    map[string][]byte
+	uploads    map[string]*standInUpload
+	partPuts   int
+	inFlight   int
+	maxFlight  int
+	failPart   func(partNumber int) bool
+	holdParts  int
+	errorCode  string
+	lastHeader http.Header
+}
+
+type standInUpload struct {
+	key       string
+	initiated time.Time
+	parts     map[int][]byte
+}
+
+func newS3StandIn() *s3StandIn {
+	s := &s3StandIn{
+		objects: make(map[string][]byte),
+		uploads: make(map[string]*standInUpload),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serve))
+
+	return s
+}
+
+func (s *s3StandIn) Close() {
+	s.server.Close()
+}
+
+func (s *s3StandIn) Object(key string) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	object, ok := s.objects[key]
+	return object, ok
+}
+
+func (s *s3StandIn) IncompleteUploads() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.uploads)
+}
+
+func (s *s3StandIn) PartPuts() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.partPuts
+}
+
+func (s *s3StandIn) MaxInFlight() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.maxFlight
+}
+
+func (s *s3StandIn) fail(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>some message</Message></Error>`, code)
+}
+
+func (s *s3StandIn) serve(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	if code := s.verify(req, body); code != "" {
+		s.fail(w, http.StatusForbidden, code)
+		return
+	}
+
+	s.mutex.Lock()
+	s.lastHeader = req.Header
+	errorCode := s.errorCode
+	s.mutex.Unlock()
+
+	if errorCode != "" {
+		s.fail(w, http.StatusInternalServerError, errorCode)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/"+standInBucket)
+	key := strings.TrimPrefix(path, "/")
+	query := req.URL.Query()
+
+	switch {
+	case req.Method == "POST" && query["uploads"] != nil:
+		s.createUpload(w, key)
+	case req.Method == "GET" && key == "" && query["uploads"] != nil:
+		s.listUploads(w, query.Get("prefix"))
+	case req.Method == "GET" && query.Get("uploadId") != "":
+		s.listParts(w, query.Get("uploadId"))
+	case req.Method == "PUT" && query.Get("uploadId") != "":
+		s.uploadPart(w, query.Get("uploadId"), query.Get("partNumber"), body)
+	case req.Method == "POST" && query.Get("uploadId") != "":
+		s.completeUpload(w, query.Get("uploadId"), body)
+	case req.Method == "DELETE" && query.Get("uploadId") != "":
+		s.abortUpload(w, query.Get("uploadId"))
+	default:
+		s.fail(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *s3StandIn) createUpload(w http.ResponseWriter, key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nextID++
+	id := fmt.Sprintf("upload-%d", s.nextID)
+	s.uploads[id] = &standInUpload{
+		key:       key,
+		initiated: time.Now().Add(time.Duration(s.nextID) * time.Second),
+		parts:     make(map[int][]byte),
+	}
+
+	fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>`, standInBucket, key, id)
+}
+
+func (s *s3StandIn) listUploads(w http.ResponseWriter, prefix string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fmt.Fprint(w, `<ListMultipartUploadsResult><IsTruncated>false</IsTruncated>`)
+	for id, upload := range s.uploads {
+		if strings.HasPrefix(upload.key, prefix) {
+			fmt.Fprintf(w, `<Upload><Key>%s</Key><UploadId>%s</UploadId><Initiated>%s</Initiated></Upload>`,
+				upload.key, id, upload.initiated.UTC().Format(time.RFC3339))
+		}
+	}
+	fmt.Fprint(w, `</ListMultipartUploadsResult>`)
+}
+
+func (s *s3StandIn) listParts(w http.ResponseWriter, uploadID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	upload, ok := s.uploads[uploadID]
+	if !ok {
+		s.fail(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	var numbers []int
+	for number := range upload.parts {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	fmt.Fprint(w, `<ListPartsResult><IsTruncated>false</IsTruncated>`)
+	for _, number := range numbers {
+		part := upload.parts[number]
+		fmt.Fprintf(w, `<Part><PartNumber>%d</PartNumber><ETag>"%x"</ETag><Size>%d</Size></Part>`, number, md5.Sum(part), len(part))
+	}
+	fmt.Fprint(w, `</ListPartsResult>`)
+}
+
+func (s *s3StandIn) uploadPart(w http.ResponseWriter, uploadID string, partNumber string, body []byte) {
+	number, err := strconv.Atoi(partNumber)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+
+	s.mutex.Lock()
+	s.partPuts++
+	s.inFlight++
+	if s.inFlight > s.maxFlight {
+		s.maxFlight = s.inFlight
+	}
+	failPart := s.failPart
+	holdParts := s.holdParts
+	s.mutex.Unlock()
+
+	// Hold each part until holdParts are in flight at once, so that the
+	// concurrency of the client can be observed.
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		s.mutex.Lock()
+		inFlight := s.inFlight
+		s.mutex.Unlock()
+
+		if inFlight >= holdParts {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.inFlight--
+
+	if failPart != nil && failPart(number) {
+		s.fail(w, http.StatusInternalServerError, "InternalError")
+		return
+	}
+
+	upload, ok := s.uploads[uploadID]
+	if !ok {
+		s.fail(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	upload.parts[number] = body
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(body)))
+}
+
+func (s *s3StandIn) completeUpload(w http.ResponseWriter, uploadID string, body []byte) {
+	var complete struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+
+	err := xml.Unmarshal(body, &complete)
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	upload, ok := s.uploads[uploadID]
+	if !ok {
+		s.fail(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	var object []byte
+	for i, p := range complete.Parts {
+		part, ok := upload.parts[p.PartNumber]
+		if !ok || p.PartNumber != i+1 || p.ETag != fmt.Sprintf(`"%x"`, md5.Sum(part)) {
+			// S3 reports this failure in the body of a successful response
+			fmt.Fprint(w, `<Error><Code>InvalidPart</Code><Message>some message</Message></Error>`)
+			return
+		}
+
+		object = append(object, part...)
+	}
+
+	s.objects[upload.key] = object
+	delete(s.uploads, uploadID)
+
+	fmt.Fprintf(w, `<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>`, upload.key)
+}
+
+func (s *s3StandIn) abortUpload(w http.ResponseWriter, uploadID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.uploads, uploadID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verify checks the AWS Signature Version 4 of req, returning an error code
+// when it is invalid.
+func (s *s3StandIn) verify(req *http.Request, body []byte) string {
+	if req.Header.Get("X-Amz-Security-Token") != standInSessionToken {
+		return "InvalidToken"
+	}
+
+	payloadHash := sha256.Sum256(body)
+	if req.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(payloadHash[:]) {
+		return "XAmzContentSHA256Mismatch"
+	}
+
+	if contentMD5 := req.Header.Get("Content-MD5"); contentMD5 != "" {
+		sum := md5.Sum(body)
+		if contentMD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+			return "BadDigest"
+		}
+	}
+
+	var credential, signedHeaders, signature string
+	for _, field := range strings.Split(strings.TrimPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), ", ") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return "AuthorizationHeaderMalformed"
+		}
+
+		switch kv[0] {
+		case "Credential":
+			credential = kv[1]
+		case "SignedHeaders":
+			signedHeaders = kv[1]
+		case "Signature":
+			signature = kv[1]
+		}
+	}
+
+	amzDate := req.Header.Get("X-Amz-Date")
+	if len(amzDate) < 8 {
+		return "AuthorizationHeaderMalformed"
+	}
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", amzDate[:8], standInRegion)
+	if credential != standInAccessKeyID+"/"+scope {
+		return "InvalidAccessKeyId"
+	}
+
+	var canonicalHeaders string
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+		}
+		canonicalHeaders += name + ":" + value + "\n"
+	}
+
+	query := req.URL.Query()
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		pairs = append(pairs, escape(key)+"="+escape(query.Get(key)))
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Join(pairs, "&"),
+		canonicalHeaders,
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	key := []byte("AWS4" + standInSecretAccessKey)
+	for _, data := range []string{amzDate[:8], standInRegion, "s3", "aws4_request", stringToSign} {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		key = h.Sum(nil)
+	}
+
+	if signature != hex.EncodeToString(key) {
+		return "SignatureDoesNotMatch"
+	}
+
+	return ""
+}
+
+func escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
@@ -0,0 +1,124 @@
+package upload_test
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pivotal-cf/go-pivnet/upload"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("S3", func() {
+	var (
+		standIn *s3StandIn
+		store   upload.S3
+		ctx     context.Context
+		key     string
+	)
+
+	BeforeEach(func() {
+		standIn = newS3StandIn()
+		store = upload.NewS3(upload.S3Config{
+			Endpoint:        standIn.server.URL,
+			Region:          standInRegion,
+			Bucket:          standInBucket,
+			AccessKeyID:     standInAccessKeyID,
+			SecretAccessKey: standInSecretAccessKey,
+			SessionToken:    standInSessionToken,
+		})
+
+		ctx = context.Background()
+		key = "product-files/some-product/some file+1.0 (beta).pivotal"
+	})
+
+	AfterEach(func() {
+		standIn.Close()
+	})
+
+	It("uploads an object in parts with signed requests", func() {
+		uploadID, err := store.CreateMultipartUpload(ctx, key)
+		Expect(err).NotTo(HaveOccurred())
+
+		etag1, err := store.UploadPart(ctx, key, uploadID, 1, bytes.NewReader([]byte("some ")), 5)
+		Expect(err).NotTo(HaveOccurred())
+
+		etag2, err := store.UploadPart(ctx, key, uploadID, 2, bytes.NewReader([]byte("contents")), 8)
+		Expect(err).NotTo(HaveOccurred())
+
+		uploadIDs, err := store.ListMultipartUploads(ctx, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(uploadIDs).To(Equal([]string{uploadID}))
+
+		parts, err := store.ListParts(ctx, key, uploadID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parts).To(Equal([]upload.Part{
+			{Number: 1, ETag: etag1, Size: 5},
+			{Number: 2, ETag: etag2, Size: 8},
+		}))
+
+		err = store.CompleteMultipartUpload(ctx, key, uploadID, parts)
+		Expect(err).NotTo(HaveOccurred())
+
+		object, ok := standIn.Object(key)
+		Expect(ok).To(BeTrue())
+		Expect(string(object)).To(Equal("some contents"))
+		Expect(standIn.IncompleteUploads()).To(Equal(0))
+	})
+
+	It("uploads an empty part", func() {
+		uploadID, err := store.CreateMultipartUpload(ctx, key)
+		Expect(err).NotTo(HaveOccurred())
+
+		etag, err := store.UploadPart(ctx, key, uploadID, 1, bytes.NewReader(nil), 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = store.CompleteMultipartUpload(ctx, key, uploadID, []upload.Part{{Number: 1, ETag: etag}})
+		Expect(err).NotTo(HaveOccurred())
+
+		object, ok := standIn.Object(key)
+		Expect(ok).To(BeTrue())
+		Expect(object).To(BeEmpty())
+	})
+
+	It("aborts an upload", func() {
+		uploadID, err := store.CreateMultipartUpload(ctx, key)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = store.AbortMultipartUpload(ctx, key, uploadID)
+		Expect(err).NotTo(HaveOccurred())
+
+		uploadIDs, err := store.ListMultipartUploads(ctx, key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(uploadIDs).To(BeEmpty())
+	})
+
+	Context("when completing the upload fails after a successful response", func() {
+		It("returns the error in the body", func() {
+			uploadID, err := store.CreateMultipartUpload(ctx, key)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = store.CompleteMultipartUpload(ctx, key, uploadID, []upload.Part{{Number: 1, ETag: `"some-etag"`}})
+			Expect(err).To(MatchError(ContainSubstring("InvalidPart: some message")))
+		})
+	})
+
+	Context("when the credentials are wrong", func() {
+		BeforeEach(func() {
+			store = upload.NewS3(upload.S3Config{
+				Endpoint:        standIn.server.URL,
+				Region:          standInRegion,
+				Bucket:          standInBucket,
+				AccessKeyID:     standInAccessKeyID,
+				SecretAccessKey: "wrong-secret",
+				SessionToken:    standInSessionToken,
+			})
+		})
+
+		It("returns the error from the store", func() {
+			_, err := store.CreateMultipartUpload(ctx, key)
+			Expect(err).To(MatchError(ContainSubstring("failed to create multipart upload of " + key + ": SignatureDoesNotMatch")))
+		})
+	})
+})
@@ -0,0 +1,284 @@
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MinPartSize is the smallest part S3 accepts, other than the last.
+	MinPartSize = 5 * 1024 * 1024
+
+	DefaultPartSize     = 64 * 1024 * 1024
+	DefaultConcurrency  = 4
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = time.Second
+
+	maxParts = 10000
+)
+
+// Uploader uploads files to an ObjectStore in parts, several at a time.
+//
+// A failed or cancelled upload is left incomplete in the object store so
+// that uploading the same key again resumes it, skipping the parts that were
+// already received intact. Only the most recent incomplete upload of a key is
+// resumed; any older ones are aborted.
+type Uploader struct {
+	store ObjectStore
+
+	// PartSize is raised to MinPartSize, and as far as needed to fit the file
+	// in the 10,000 parts S3 allows. Defaults to DefaultPartSize.
+	PartSize int64
+
+	// Concurrency is the number of parts uploaded at the same time.
+	// Defaults to DefaultConcurrency.
+	Concurrency int
+
+	// MaxRetries is the number of times a part is retried after it fails to
+	// upload. Defaults to DefaultMaxRetries.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry of a part. It doubles
+	// with every subsequent retry.
+	RetryBackoff time.Duration
+}
+
+func New(store ObjectStore) Uploader {
+	return Uploader{
+		store:        store,
+		PartSize:     DefaultPartSize,
+		Concurrency:  DefaultConcurrency,
+		MaxRetries:   DefaultMaxRetries,
+		RetryBackoff: DefaultRetryBackoff,
+	}
+}
+
+// Upload uploads size bytes read from r to key.
+func (u Uploader) Upload(ctx context.Context, key string, r io.ReaderAt, size int64) error {
+	partSize := u.partSize(size)
+
+	numParts := int((size + partSize - 1) / partSize)
+	if numParts == 0 {
+		// A multipart upload needs at least one part, which may be empty
+		numParts = 1
+	}
+
+	uploadID, received, err := u.resume(ctx, key, r, size, partSize, numParts)
+	if err != nil {
+		return err
+	}
+
+	if uploadID == "" {
+		uploadID, err = u.store.CreateMultipartUpload(ctx, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	var mutex sync.Mutex
+	parts := make([]Part, 0, numParts)
+	var missing []int
+	for number := 1; number <= numParts; number++ {
+		if part, ok := received[number]; ok {
+			parts = append(parts, part)
+			continue
+		}
+
+		missing = append(missing, number)
+	}
+
+	concurrency := u.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	// After a part fails no more are started, but those in flight are left
+	// to finish so that resuming does not have to upload them again
+	var wg sync.WaitGroup
+	var firstErr error
+	for _, n := range missing {
+		number := n
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-semaphore }()
+
+			mutex.Lock()
+			failed := firstErr != nil
+			mutex.Unlock()
+
+			if failed {
+				return
+			}
+
+			offset := int64(number-1) * partSize
+			length := partSize
+			if offset+length > size {
+				length = size - offset
+			}
+
+			etag, err := u.uploadPart(ctx, key, uploadID, number, io.NewSectionReader(r, offset, length), length)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+
+			parts = append(parts, Part{Number: number, ETag: etag, Size: length})
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+
+	return u.store.CompleteMultipartUpload(ctx, key, uploadID, parts)
+}
+
+func (u Uploader) partSize(size int64) int64 {
+	partSize := u.PartSize
+	if partSize < 1 {
+		partSize = DefaultPartSize
+	}
+
+	if partSize < MinPartSize {
+		partSize = MinPartSize
+	}
+
+	if minimum := (size + maxParts - 1) / maxParts; partSize < minimum {
+		partSize = minimum
+	}
+
+	return partSize
+}
+
+// resume finds the most recent incomplete upload of key, returning its ID and
+// the parts that match the corresponding bytes of r. It returns an empty ID
+// when there is no upload to resume. Older incomplete uploads of key are
+// aborted, since they would otherwise never be completed and their parts
+// would be kept, and billed, indefinitely.
+func (u Uploader) resume(
+	ctx context.Context,
+	key string,
+	r io.ReaderAt,
+	size int64,
+	partSize int64,
+	numParts int,
+) (string, map[int]Part, error) {
+	uploadIDs, err := u.store.ListMultipartUploads(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(uploadIDs) == 0 {
+		return "", nil, nil
+	}
+
+	uploadID := uploadIDs[len(uploadIDs)-1]
+
+	for _, superseded := range uploadIDs[:len(uploadIDs)-1] {
+		err = u.store.AbortMultipartUpload(ctx, key, superseded)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to abort superseded upload %s: %s", superseded, err)
+		}
+	}
+
+	parts, err := u.store.ListParts(ctx, key, uploadID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	received := make(map[int]Part)
+	for _, part := range parts {
+		offset := int64(part.Number-1) * partSize
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+
+		// The part was uploaded with a different part size or no longer
+		// belongs to the file
+		if part.Number > numParts || part.Size != length {
+			continue
+		}
+
+		h := md5.New()
+		_, err = io.Copy(h, io.NewSectionReader(r, offset, length))
+		if err != nil {
+			return "", nil, err
+		}
+
+		if strings.Trim(part.ETag, `"`) == hex.EncodeToString(h.Sum(nil)) {
+			received[part.Number] = part
+		}
+	}
+
+	return uploadID, received, nil
+}
+
+func (u Uploader) uploadPart(
+	ctx context.Context,
+	key string,
+	uploadID string,
+	number int,
+	body io.ReadSeeker,
+	size int64,
+) (string, error) {
+	backoff := u.RetryBackoff
+
+	for retries := 0; ; retries++ {
+		etag, err := u.store.UploadPart(ctx, key, uploadID, number, body, size)
+		if err == nil {
+			return etag, nil
+		}
+
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		if retries >= u.MaxRetries {
+			return "", fmt.Errorf("giving up after %d retries: %s", retries, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		_, err = body.Seek(0, io.SeekStart)
+		if err != nil {
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package upload_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+
+	"github.com/pivotal-cf/go-pivnet/upload"
+	"github.com/pivotal-cf/go-pivnet/upload/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Uploader", func() {
+	const key = "product-files/some-product/some-file.pivotal"
+
+	var (
+		standIn  *s3StandIn
+		uploader upload.Uploader
+		content  []byte
+	)
+
+	BeforeEach(func() {
+		standIn = newS3StandIn()
+		uploader = upload.New(upload.NewS3(upload.S3Config{
+			Endpoint:        standIn.server.URL,
+			Region:          standInRegion,
+			Bucket:          standInBucket,
+			AccessKeyID:     standInAccessKeyID,
+			SecretAccessKey: standInSecretAccessKey,
+			SessionToken:    standInSessionToken,
+		}))
+		uploader.PartSize = upload.MinPartSize
+		uploader.MaxRetries = 0
+
+		content = make([]byte, 2*upload.MinPartSize+1024)
+		rand.New(rand.NewSource(1)).Read(content)
+	})
+
+	AfterEach(func() {
+		standIn.Close()
+	})
+
+	It("uploads the file in parts, several at a time", func() {
+		err := uploader.Upload(context.Background(), key, bytes.NewReader(content), int64(len(content)))
+		Expect(err).NotTo(HaveOccurred())
+
+		object, ok := standIn.Object(key)
+		Expect(ok).To(BeTrue())
+		Expect(object).To(Equal(content))
+
+		Expect(standIn.PartPuts()).To(Equal(3))
+		Expect(standIn.IncompleteUploads()).To(Equal(0))
+	})
+
+	It("uploads no more parts at once than its concurrency", func() {
+		standIn.holdParts = 3
+		uploader.Concurrency = 2
+
+		err := uploader.Upload(context.Background(), key, bytes.NewReader(content), int64(len(content)))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(standIn.MaxInFlight()).To(Equal(2))
+	})
+
+	It("uploads an empty file as a single empty part", func() {
+		err := uploader.Upload(context.Background(), key, bytes.NewReader(nil), 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		object, ok := standIn.Object(key)
+		Expect(ok).To(BeTrue())
+		Expect(object).To(BeEmpty())
+	})
+
+	Context("when a part fails to upload", func() {
+		BeforeEach(func() {
+			standIn.failPart = func(partNumber int) bool {
+				return partNumber == 2
+			}
+
+			// Every part is in flight before part 2 fails, so the others
+			// are received and only part 2 needs to be uploaded again
+			standIn.holdParts = 3
+		})
+
+		It("leaves the upload incomplete so that it can be resumed", func() {
+			err := uploader.Upload(context.Background(), key, bytes.NewReader(content), int64(len(content)))
+			Expect(err).To(MatchError(ContainSubstring("failed to upload part 2")))
+
+			Expect(standIn.IncompleteUploads()).To(Equal(1))
+			_, ok := standIn.Object(key)
+			Expect(ok).To(BeFalse())
+
+			standIn.mutex.Lock()
+			standIn.failPart = nil
+			standIn.holdParts = 0
+			puts := standIn.partPuts
+			standIn.mutex.Unlock()
+
+			err = uploader.Upload(context.Background(), key, bytes.NewReader(content), int64(len(content)))
+			Expect(err).NotTo(HaveOccurred())
+
+			object, ok := standIn.Object(key)
+			Expect(ok).To(BeTrue())
+			Expect(object).To(Equal(content))
+
+			Expect(standIn.PartPuts() - puts).To(Equal(1))
+			Expect(standIn.IncompleteUploads()).To(Equal(0))
+		})
+
+		Context("when the file has changed before resuming", func() {
+			It("uploads the parts that no longer match again", func() {
+				err := uploader.Upload(context.Background(), key, bytes.NewReader(content), int64(len(content)))
+				Expect(err).To(HaveOccurred())
+
+				standIn.mutex.Lock()
+				standIn.failPart = nil
+				standIn.holdParts = 0
+				puts := standIn.partPuts
+				standIn.mutex.Unlock()
+
+				content[0] ^= 0xff
+
+				err = uploader.Upload(context.Background(), key, bytes.NewReader(content), int64(len(content)))
+				Expect(err).NotTo(HaveOccurred())
+
+				object, _ := standIn.Object(key)
+				Expect(object).To(Equal(content))
+
+				Expect(standIn.PartPuts() - puts).To(Equal(2))
+			})
+		})
+	})
+
+	Context("when a part fails transiently", func() {
+		var store *fakes.ObjectStore
+
+		BeforeEach(func() {
+			store = &fakes.ObjectStore{}
+			store.CreateMultipartUploadReturns("some-upload-id", nil)
+
+			attempts := 0
+			store.UploadPartStub = func(ctx context.Context, key string, uploadID string, partNumber int, body io.ReadSeeker, size int64) (string, error) {
+				attempts++
+
+				b, err := ioutil.ReadAll(body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(b).To(Equal(content))
+
+				if attempts == 1 {
+					return "", errors.New("connection reset")
+				}
+
+				return `"some-etag"`, nil
+			}
+
+			uploader = upload.New(store)
+			uploader.MaxRetries = 1
+			uploader.RetryBackoff = 0
+
+			content = []byte("some contents")
+		})
+
+		It("retries the part from its start", func() {
+			err := uploader.Upload(context.Background(), key, bytes.NewReader(content), int64(len(content)))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(store.UploadPartCallCount()).To(Equal(2))
+
+			_, _, uploadID, parts := store.CompleteMultipartUploadArgsForCall(0)
+			Expect(uploadID).To(Equal("some-upload-id"))
+			Expect(parts).To(Equal([]upload.Part{{Number: 1, ETag: `"some-etag"`, Size: int64(len(content))}}))
+		})
+	})
+
+	Context("when there are several incomplete uploads", func() {
+		It("resumes the most recent and aborts the others", func() {
+			store := &fakes.ObjectStore{}
+			store.ListMultipartUploadsReturns([]string{"oldest-upload-id", "older-upload-id", "newest-upload-id"}, nil)
+			store.UploadPartReturns(`"some-etag"`, nil)
+
+			err := upload.New(store).Upload(context.Background(), key, bytes.NewReader(content), int64(len(content)))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(store.AbortMultipartUploadCallCount()).To(Equal(2))
+			for i, expected := range []string{"oldest-upload-id", "older-upload-id"} {
+				_, abortedKey, uploadID := store.AbortMultipartUploadArgsForCall(i)
+				Expect(abortedKey).To(Equal(key))
+				Expect(uploadID).To(Equal(expected))
+			}
+
+			Expect(store.CreateMultipartUploadCallCount()).To(Equal(0))
+
+			_, _, uploadID, _ := store.CompleteMultipartUploadArgsForCall(0)
+			Expect(uploadID).To(Equal("newest-upload-id"))
+		})
+	})
+
+	Context("when listing incomplete uploads fails", func() {
+		It("returns the error without uploading", func() {
+			store := &fakes.ObjectStore{}
+			store.ListMultipartUploadsReturns(nil, errors.New("some error"))
+
+			err := upload.New(store).Upload(context.Background(), key, bytes.NewReader(content), int64(len(content)))
+			Expect(err).To(MatchError("some error"))
+
+			Expect(store.CreateMultipartUploadCallCount()).To(Equal(0))
+			Expect(store.UploadPartCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,149 @@
+package pivnet
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// DateFormat is the layout of the dates accepted for releases and product
+// files.
+const DateFormat = "2006-01-02"
+
+var (
+	md5Pattern    = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+	sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+)
+
+// Validate checks a product file config before it is sent, so that mistakes
+// are reported before a file is uploaded rather than by the server after.
+func (c CreateProductFileConfig) Validate() error {
+	var v validator
+
+	v.required("product slug", c.ProductSlug)
+	v.required("AWS object key", c.AWSObjectKey)
+	v.required("name", c.Name)
+	v.fileType(c.FileType)
+	v.checksum("MD5", c.MD5, md5Pattern)
+	v.checksum("SHA256", c.SHA256, sha256Pattern)
+	v.url("docs URL", c.DocsURL)
+	v.date("released at", c.ReleasedAt)
+
+	return v.err("product file config")
+}
+
+// Validate checks a release config before it is sent. The release type is
+// only checked to be set; use ReleaseTypesService.ValidateReleaseType to
+// check it against the release types known to the server.
+func (c CreateReleaseConfig) Validate() error {
+	var v validator
+
+	v.required("product slug", c.ProductSlug)
+	v.required("version", c.Version)
+	v.required("release type", string(c.ReleaseType))
+	v.required("EULA slug", c.EULASlug)
+	v.date("release date", c.ReleaseDate)
+	v.date("end of support date", c.EndOfSupportDate)
+	v.date("end of guidance date", c.EndOfGuidanceDate)
+	v.date("end of availability date", c.EndOfAvailabilityDate)
+	v.url("release notes URL", c.ReleaseNotesURL)
+	v.controlled(c.Controlled, c.ECCN, c.LicenseException)
+
+	return v.err("release config")
+}
+
+// Validate checks a release before it is sent as an update.
+func (r Release) Validate() error {
+	var v validator
+
+	v.required("version", r.Version)
+	v.required("release type", string(r.ReleaseType))
+	v.date("release date", r.ReleaseDate)
+	v.date("end of support date", r.EndOfSupportDate)
+	v.date("end of guidance date", r.EndOfGuidanceDate)
+	v.date("end of availability date", r.EndOfAvailabilityDate)
+	v.url("release notes URL", r.ReleaseNotesURL)
+	v.controlled(r.Controlled, r.ECCN, r.LicenseException)
+
+	return v.err("release")
+}
+
+// validator collects the problems found in a config.
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(field string, value string) {
+	if value == "" {
+		v.add("%s must be set", field)
+	}
+}
+
+func (v *validator) fileType(fileType FileType) {
+	if fileType == "" {
+		return
+	}
+
+	for _, known := range FileTypes {
+		if fileType == known {
+			return
+		}
+	}
+
+	v.add("file type %q must be one of %q", fileType, FileTypes)
+}
+
+func (v *validator) checksum(field string, value string, pattern *regexp.Regexp) {
+	if value != "" && !pattern.MatchString(value) {
+		v.add("%s %q is not a hex encoded %s checksum", field, value, field)
+	}
+}
+
+func (v *validator) url(field string, value string) {
+	if value == "" {
+		return
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add("%s %q is not an http or https URL", field, value)
+	}
+}
+
+func (v *validator) date(field string, value string) {
+	if value == "" {
+		return
+	}
+
+	_, err := time.Parse(DateFormat, value)
+	if err != nil {
+		v.add("%s %q is not a date of the form YYYY-MM-DD", field, value)
+	}
+}
+
+func (v *validator) controlled(controlled bool, eccn string, licenseException string) {
+	if !controlled {
+		return
+	}
+
+	if eccn == "" {
+		v.add("ECCN must be set for a controlled release")
+	}
+
+	if licenseException == "" {
+		v.add("license exception must be set for a controlled release")
+	}
+}
+
+func (v *validator) err(kind string) error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+
+	return ErrInvalidConfig{Kind: kind, Problems: v.problems}
+}
@@ -0,0 +1,131 @@
+package pivnet_test
+
+import (
+	"github.com/pivotal-cf/go-pivnet"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validation", func() {
+	Describe("CreateProductFileConfig", func() {
+		var config pivnet.CreateProductFileConfig
+
+		BeforeEach(func() {
+			config = pivnet.CreateProductFileConfig{
+				ProductSlug:  productSlug,
+				AWSObjectKey: "product-files/some-product/some-file",
+				Name:         "Some File",
+				FileType:     pivnet.FileTypeSoftware,
+				MD5:          "9893532233caff98cd083a116b013c0b",
+				SHA256:       "290f493c44f5d63d06b374d0a5abd292fae38b92cab2fae5efefe1b0e9347f56",
+				DocsURL:      "https://docs.example.com/some-product",
+				ReleasedAt:   "2017-01-31",
+			}
+		})
+
+		It("accepts a valid config", func() {
+			Expect(config.Validate()).To(Succeed())
+		})
+
+		It("reports every problem found", func() {
+			config.ProductSlug = ""
+			config.Name = ""
+			config.FileType = "Sofware"
+			config.MD5 = "not-an-md5"
+			config.DocsURL = "docs.example.com"
+			config.ReleasedAt = "01/31/2017"
+
+			err := config.Validate()
+			Expect(err).To(Equal(pivnet.ErrInvalidConfig{
+				Kind: "product file config",
+				Problems: []string{
+					"product slug must be set",
+					"name must be set",
+					`file type "Sofware" must be one of ["Software" "Documentation" "Open Source License"]`,
+					`MD5 "not-an-md5" is not a hex encoded MD5 checksum`,
+					`docs URL "docs.example.com" is not an http or https URL`,
+					`released at "01/31/2017" is not a date of the form YYYY-MM-DD`,
+				},
+			}))
+			Expect(err.Error()).To(HavePrefix("invalid product file config: product slug must be set; name must be set; "))
+		})
+	})
+
+	Describe("CreateReleaseConfig", func() {
+		var config pivnet.CreateReleaseConfig
+
+		BeforeEach(func() {
+			config = pivnet.CreateReleaseConfig{
+				ProductSlug:      productSlug,
+				Version:          "1.2.3",
+				ReleaseType:      pivnet.ReleaseTypeMinor,
+				EULASlug:         "some-eula",
+				ReleaseDate:      "2017-01-31",
+				EndOfSupportDate: "2018-01-31",
+				ReleaseNotesURL:  "https://docs.example.com/release-notes",
+			}
+		})
+
+		It("accepts a valid config", func() {
+			Expect(config.Validate()).To(Succeed())
+		})
+
+		It("reports every problem found", func() {
+			config.Version = ""
+			config.ReleaseType = ""
+			config.EndOfGuidanceDate = "2018-13-01"
+			config.ReleaseNotesURL = "ftp://docs.example.com/release-notes"
+
+			err := config.Validate()
+			Expect(err).To(Equal(pivnet.ErrInvalidConfig{
+				Kind: "release config",
+				Problems: []string{
+					"version must be set",
+					"release type must be set",
+					`end of guidance date "2018-13-01" is not a date of the form YYYY-MM-DD`,
+					`release notes URL "ftp://docs.example.com/release-notes" is not an http or https URL`,
+				},
+			}))
+		})
+
+		Context("when the release is controlled", func() {
+			BeforeEach(func() {
+				config.Controlled = true
+			})
+
+			It("requires the ECCN and license exception", func() {
+				err := config.Validate()
+				Expect(err).To(MatchError("invalid release config: ECCN must be set for a controlled release; license exception must be set for a controlled release"))
+
+				config.ECCN = "5D002"
+				config.LicenseException = "ENC Unrestricted"
+				Expect(config.Validate()).To(Succeed())
+			})
+		})
+	})
+
+	Describe("Release", func() {
+		It("accepts a valid release", func() {
+			release := pivnet.Release{
+				Version:     "1.2.3",
+				ReleaseType: pivnet.ReleaseTypeMajor,
+				ReleaseDate: "2017-01-31",
+			}
+
+			Expect(release.Validate()).To(Succeed())
+		})
+
+		It("reports every problem found", func() {
+			release := pivnet.Release{
+				ReleaseType:           pivnet.ReleaseTypeMajor,
+				EndOfAvailabilityDate: "soon",
+				Controlled:            true,
+				ECCN:                  "5D002",
+			}
+
+			err := release.Validate()
+			Expect(err).To(MatchError(`invalid release: version must be set; end of availability date "soon" is not a date of the form YYYY-MM-DD; license exception must be set for a controlled release`))
+		})
+	})
+})